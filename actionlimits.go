@@ -0,0 +1,45 @@
+package main
+
+import "fmt"
+
+// SetActionLimit caps single commands of one action on the account, so for
+// example withdrawals can be held to 5000 while deposits stay unlimited. It
+// applies on top of SetMaxTransactionAmount: a command must fit both. A
+// limit of zero removes the cap for that action, and actions without one
+// are unlimited.
+func (account *BankAccount) SetActionLimit(action Action, limit float64) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	if NewMoney(limit) == 0 {
+		delete(account.limits, action)
+		return
+	}
+	if account.limits == nil {
+		account.limits = map[Action]Money{}
+	}
+	account.limits[action] = NewMoney(limit)
+}
+
+// ActionLimit returns the cap set for action, and false if there is none.
+func (account *BankAccount) ActionLimit(action Action) (float64, bool) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	limit, ok := account.limits[action]
+	return limit.Float64(), ok
+}
+
+// ActionLimits rejects commands above the cap set for their action with
+// SetActionLimit, with ErrAmountTooLarge. The caps apply to commands, not to
+// Undo: the deposit that returns an undone withdrawal isn't held to the
+// deposit cap.
+type ActionLimits struct{}
+
+func (ActionLimits) Validate(account *BankAccount, cmd *BankAccountCommand) error {
+	if cmd.reversal {
+		return nil
+	}
+	if limit, ok := account.limits[cmd.action]; ok && cmd.amount > limit {
+		return fmt.Errorf("%w: %s %s over %s", ErrAmountTooLarge, cmd.action, cmd.amount, limit)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// Withdrawals capped at 5000 with deposits left unlimited.
+func TestActionLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  Action
+		amount  float64
+		wantErr error
+	}{
+		{"deposit far above the withdrawal cap", Deposit, 1_000_000, nil},
+		{"withdrawal at the cap", Withdraw, 5000, nil},
+		{"withdrawal one cent over", Withdraw, 5000.01, ErrAmountTooLarge},
+		{"uncapped action", Fee, 6000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := NewBankAccount(10_000, 0)
+			account.SetActionLimit(Withdraw, 5000)
+			err := call(NewBankAccountCommand(account, tt.action, tt.amount))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A transfer's withdrawal leg is held to the source's withdrawal cap.
+func TestActionLimitsOnTransfer(t *testing.T) {
+	from, to := NewBankAccount(10_000, 0), NewBankAccount(0, 0)
+	from.SetActionLimit(Withdraw, 5000)
+	transfer, err := NewMoneyTransferCommand(from, to, 6000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := call(transfer); !errors.Is(err, ErrAmountTooLarge) {
+		t.Fatalf("got %v, want ErrAmountTooLarge", err)
+	}
+	if from.Balance() != 10_000 || to.Balance() != 0 {
+		t.Fatalf("balances %v, %v; want 10000, 0", from.Balance(), to.Balance())
+	}
+}
+
+func TestActionLimitsAlongsideMaxTransactionAmount(t *testing.T) {
+	account := NewBankAccount(10_000, 0)
+	account.SetActionLimit(Withdraw, 5000)
+	account.SetMaxTransactionAmount(1000)
+	if err := call(NewBankAccountCommand(account, Withdraw, 2000)); !errors.Is(err, ErrAmountTooLarge) {
+		t.Fatalf("withdrawal under the action limit but over the transaction cap: %v", err)
+	}
+	if err := call(NewBankAccountCommand(account, Deposit, 2000)); !errors.Is(err, ErrAmountTooLarge) {
+		t.Fatalf("unlimited deposit over the transaction cap: %v", err)
+	}
+}
+
+func TestSetActionLimitZeroRemovesCap(t *testing.T) {
+	account := NewBankAccount(10_000, 0)
+	account.SetActionLimit(Withdraw, 5000)
+	if limit, ok := account.ActionLimit(Withdraw); !ok || limit != 5000 {
+		t.Fatalf("ActionLimit = %v, %v; want 5000, true", limit, ok)
+	}
+	account.SetActionLimit(Withdraw, 0)
+	if _, ok := account.ActionLimit(Withdraw); ok {
+		t.Fatal("cap still set after SetActionLimit(Withdraw, 0)")
+	}
+	if err := call(NewBankAccountCommand(account, Withdraw, 6000)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Undo moves money the other way, but isn't held to that direction's cap.
+func TestActionLimitsSkipUndo(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  Action
+		limited Action
+	}{
+		{"deposit undone past the withdrawal cap", Deposit, Withdraw},
+		{"withdrawal undone past the deposit cap", Withdraw, Deposit},
+		{"release undone past the authorization cap", Release, Authorize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := NewBankAccount(1000, 0)
+			if tt.action == Release {
+				if err := call(NewBankAccountCommand(account, Authorize, 500)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cmd := NewBankAccountCommand(account, tt.action, 500)
+			if err := call(cmd); err != nil {
+				t.Fatal(err)
+			}
+			account.SetActionLimit(tt.limited, 50)
+			if err := cmd.Undo(); err != nil {
+				t.Fatalf("Undo = %v, want nil", err)
+			}
+			if err := call(NewBankAccountCommand(account, tt.limited, 500)); !errors.Is(err, ErrAmountTooLarge) {
+				t.Fatalf("new %s over the cap = %v, want ErrAmountTooLarge", tt.limited, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "fmt"
+
+// SetActor records who initiated the command, a person or a system such as
+// "teller-07" or "payroll-batch". Compliance workflows expect every money
+// movement to be attributed. The actor shows up in Describe, the transaction
+// log, statement lines, JSON, gob and clones, and an overdraft fee the
+// command triggers is attributed to the same actor.
+func (c *BankAccountCommand) SetActor(actor string) {
+	c.actor = actor
+}
+
+func (c *BankAccountCommand) Actor() string {
+	return c.actor
+}
+
+// SetReason records why the command was issued, such as "loan
+// disbursement". It travels everywhere the actor does.
+func (c *BankAccountCommand) SetReason(reason string) {
+	c.reason = reason
+}
+
+func (c *BankAccountCommand) Reason() string {
+	return c.reason
+}
+
+// attribution is the suffix Describe adds for the actor and reason, or ""
+// when neither is set.
+func (c *BankAccountCommand) attribution() string {
+	switch {
+	case c.actor != "" && c.reason != "":
+		return fmt.Sprintf(" by %s (%s)", c.actor, c.reason)
+	case c.actor != "":
+		return " by " + c.actor
+	case c.reason != "":
+		return fmt.Sprintf(" (%s)", c.reason)
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCommandFailed is reported for a command that failed without giving a
+// more specific error.
+var ErrCommandFailed = errors.New("command failed")
+
+// ExecuteAll calls independent commands in order with all-or-nothing
+// semantics: if one fails, those that already succeeded are undone in
+// reverse order and the failing index is returned with its error. It returns
+// -1 and a nil error when every command succeeds.
+func ExecuteAll(cmds []Command) (int, error) {
+	for i, cmd := range cmds {
+		cmd.Call()
+		if cmd.Succeeded() {
+			continue
+		}
+		errs := []error{fmt.Errorf("command %d (%s): %w", i, cmd.Describe(), commandErr(cmd))}
+		for j := i - 1; j >= 0; j-- {
+			if err := cmds[j].Undo(); err != nil {
+				errs = append(errs, fmt.Errorf("rolling back command %d: %w", j, err))
+			}
+		}
+		return i, errors.Join(errs...)
+	}
+	return -1, nil
+}
+
+// ExecuteAllBestEffort calls every command regardless of failures and
+// returns one error per command, nil for those that succeeded.
+func ExecuteAllBestEffort(cmds []Command) []error {
+	errs := make([]error, len(cmds))
+	for i, cmd := range cmds {
+		cmd.Call()
+		if !cmd.Succeeded() {
+			errs[i] = commandErr(cmd)
+		}
+	}
+	return errs
+}
+
+func commandErr(cmd Command) error {
+	if err := cmd.Err(); err != nil {
+		return err
+	}
+	return ErrCommandFailed
+}
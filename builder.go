@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNilAccount = errors.New("account must not be nil")
+
+// CommandBuilder assembles a CompositeBankAccountCommand step by step:
+//
+//	cmd, err := NewCommandBuilder().
+//		Withdraw(a, 100).
+//		Transfer(a, b, 50).
+//		Build()
+//
+// Each step is validated as it is added; Build reports every invalid step.
+type CommandBuilder struct {
+	commands []Command
+	errs     []error
+}
+
+func NewCommandBuilder() *CommandBuilder {
+	return &CommandBuilder{}
+}
+
+func (b *CommandBuilder) Deposit(account *BankAccount, amount float64) *CommandBuilder {
+	return b.add(b.check(amount, account), func() Command {
+		return NewBankAccountCommand(account, Deposit, amount)
+	})
+}
+
+func (b *CommandBuilder) Withdraw(account *BankAccount, amount float64) *CommandBuilder {
+	return b.add(b.check(amount, account), func() Command {
+		return NewBankAccountCommand(account, Withdraw, amount)
+	})
+}
+
+func (b *CommandBuilder) Transfer(from, to *BankAccount, amount float64, opts ...TransferOption) *CommandBuilder {
+	err := b.check(amount, from, to)
+	var transfer *MoneyTransferCommand
+	if err == nil {
+		transfer, err = NewMoneyTransferCommand(from, to, amount, opts...)
+	}
+	return b.add(err, func() Command {
+		return transfer
+	})
+}
+
+// Build returns the composite of all steps, or the invalid steps' errors
+// joined together. A composite over MaxChildren fails with
+// ErrCompositeTooLarge.
+func (b *CommandBuilder) Build() (Command, error) {
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+	composite := &CompositeBankAccountCommand{commands: b.commands}
+	if err := checkCommandLimits(composite); err != nil {
+		return nil, err
+	}
+	return composite, nil
+}
+
+func (b *CommandBuilder) check(amount float64, accounts ...*BankAccount) error {
+	for _, account := range accounts {
+		if account == nil {
+			return ErrNilAccount
+		}
+	}
+	if NewMoney(amount) == invalidMoney {
+		return ErrInvalidAmount
+	}
+	if amount < 0 {
+		return ErrNegativeAmount
+	}
+	return nil
+}
+
+func (b *CommandBuilder) add(err error, build func() Command) *CommandBuilder {
+	step := len(b.commands) + len(b.errs)
+	if err != nil {
+		b.errs = append(b.errs, fmt.Errorf("step %d: %w", step, err))
+		return b
+	}
+	b.commands = append(b.commands, build())
+	return b
+}
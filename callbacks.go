@@ -0,0 +1,37 @@
+package main
+
+// OnSuccess sets a callback that runs once Call has applied the command.
+// Inside a composite or transfer it runs when this leg does, and a later
+// rollback of the whole doesn't take it back. Clones don't inherit it, so a
+// DryRun doesn't fire it.
+func (c *BankAccountCommand) OnSuccess(fn func(Command)) {
+	c.onSuccess = fn
+}
+
+// OnFailure sets a callback that runs when Call fails, with the error. A
+// transfer leg that is rolled back or skipped because another leg failed
+// fires neither callback.
+func (c *BankAccountCommand) OnFailure(fn func(Command, error)) {
+	c.onFailure = fn
+}
+
+// notifyOutcome runs the callback matching c's outcome. It must be called
+// without the account lock held, so callbacks can read the account.
+func (c *BankAccountCommand) notifyOutcome() {
+	switch {
+	case c.err != nil:
+		if c.onFailure != nil {
+			c.onFailure(c, c.err)
+		}
+	case c.succeeded:
+		if c.onSuccess != nil {
+			c.onSuccess(c)
+		}
+	}
+}
+
+func (c *MoneyTransferCommand) notifyLegs() {
+	for _, cmd := range c.commands {
+		cmd.(*BankAccountCommand).notifyOutcome()
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+// CanUndo reports whether Undo would reverse anything: false for a command
+// that hasn't run, failed, was a balance inquiry, or has been undone. It
+// describes the command's own state, not whether the accounts would allow
+// the reversal; Undo can still fail, for example on a frozen account.
+func (c *BankAccountCommand) CanUndo() bool {
+	return c.succeeded && c.executed
+}
+
+// CanUndo is true if any child can be undone, so a composite that partly
+// applied can still be reversed.
+func (c *CompositeBankAccountCommand) CanUndo() bool {
+	for _, cmd := range c.commands {
+		if cmd.CanUndo() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *InterestCommand) CanUndo() bool {
+	return c.leg != nil && c.leg.CanUndo()
+}
+
+func (c *ConditionalCommand) CanUndo() bool {
+	return c.ran && c.inner.CanUndo()
+}
+
+func (c *CloseAccountCommand) CanUndo() bool {
+	return c.executed
+}
+
+func (c *RetryCommand) CanUndo() bool {
+	return c.inner.CanUndo()
+}
+
+func (c *ParallelCommand) CanUndo() bool {
+	return c.group.CanUndo()
+}
@@ -0,0 +1,71 @@
+package main
+
+import "slices"
+
+// SetCategory files the command under a reporting category such as
+// "salary" or "rent". It carries through to statement lines, JSON and
+// clones.
+func (c *BankAccountCommand) SetCategory(category string) {
+	c.category = category
+}
+
+func (c *BankAccountCommand) Category() string {
+	return c.category
+}
+
+// SetTags replaces the command's free-form tags.
+func (c *BankAccountCommand) SetTags(tags ...string) {
+	c.tags = slices.Clone(tags)
+}
+
+func (c *BankAccountCommand) Tags() []string {
+	return slices.Clone(c.tags)
+}
+
+// FilterCategory returns a copy of the statement holding only the lines
+// in category. The opening and closing balances, and each line's running
+// balance, still describe the whole account.
+func (s Statement) FilterCategory(category string) Statement {
+	return s.filter(func(line StatementLine) bool {
+		return line.Category == category
+	})
+}
+
+// FilterTag is FilterCategory for lines carrying tag.
+func (s Statement) FilterTag(tag string) Statement {
+	return s.filter(func(line StatementLine) bool {
+		return slices.Contains(line.Tags, tag)
+	})
+}
+
+func (s Statement) filter(keep func(StatementLine) bool) Statement {
+	filtered := s
+	filtered.Lines = nil
+	for _, line := range s.Lines {
+		if keep(line) {
+			filtered.Lines = append(filtered.Lines, line)
+		}
+	}
+	return filtered
+}
+
+// CategoryTotal sums the debits and credits filed under one category.
+type CategoryTotal struct {
+	Debit  Money
+	Credit Money
+	Lines  int
+}
+
+// ByCategory totals the statement per category. Uncategorized lines are
+// grouped under "".
+func (s Statement) ByCategory() map[string]CategoryTotal {
+	totals := make(map[string]CategoryTotal)
+	for _, line := range s.Lines {
+		total := totals[line.Category]
+		total.Debit += line.Debit
+		total.Credit += line.Credit
+		total.Lines++
+		totals[line.Category] = total
+	}
+	return totals
+}
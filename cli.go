@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const cliHelp = `commands:
+  open <id> [balance] [overdraft-limit]
+  deposit <id> <amount>
+  withdraw <id> <amount>
+  transfer <from> <to> <amount>
+  balance <id>
+  undo
+  redo
+  help
+  quit`
+
+// RunCLI reads one command per line from r, runs it against the accounts in
+// reg through manager, and writes the outcome to w. It is the engine behind
+// the -repl flag. A bad line prints an error and the loop carries on;
+// RunCLI only returns at "quit", at the end of input, or when reading fails.
+func RunCLI(r io.Reader, w io.Writer, reg *Registry, manager *CommandManager) error {
+	scanner := bufio.NewScanner(r)
+	for {
+		fmt.Fprint(w, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(w)
+			return scanner.Err()
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "exit" {
+			return nil
+		}
+		out, err := runCLILine(fields, reg, manager)
+		if err != nil {
+			fmt.Fprintln(w, "error:", err)
+			continue
+		}
+		fmt.Fprintln(w, out)
+	}
+}
+
+func runCLILine(fields []string, reg *Registry, manager *CommandManager) (string, error) {
+	verb, args := strings.ToLower(fields[0]), fields[1:]
+	switch verb {
+	case "help":
+		return cliHelp, nil
+	case "open":
+		if len(args) < 1 || len(args) > 3 {
+			return "", fmt.Errorf("usage: open <id> [balance] [overdraft-limit]")
+		}
+		amounts, err := parseCLIAmounts(args[1:])
+		if err != nil {
+			return "", err
+		}
+		amounts = append(amounts, 0, 0)
+		account := NewBankAccount(amounts[0].Float64(), amounts[1].Float64())
+		account.ID = args[0]
+		if err := reg.Add(account); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("opened %s with %s", account.ID, FormatAmount(account.Balance(), account.Currency)), nil
+	case "balance":
+		if len(args) != 1 {
+			return "", fmt.Errorf("usage: balance <id>")
+		}
+		account, ok := reg.Get(args[0])
+		if !ok {
+			return "", fmt.Errorf("%w %q", ErrUnknownAccount, args[0])
+		}
+		return fmt.Sprintf("%s: %s", account.ID, FormatAmount(account.Balance(), account.Currency)), nil
+	case "undo":
+		if err := manager.Undo(); err != nil {
+			return "", err
+		}
+		return "undone", nil
+	case "redo":
+		if err := manager.Redo(); err != nil {
+			return "", err
+		}
+		return "redone", nil
+	}
+
+	spec := CommandSpec{Action: verb}
+	var amount string
+	switch {
+	case verb == TransferAction && len(args) == 3:
+		spec.From, spec.To, amount = args[0], args[1], args[2]
+	case verb != TransferAction && len(args) == 2:
+		spec.From, amount = args[0], args[1]
+	case verb == TransferAction:
+		return "", fmt.Errorf("usage: transfer <from> <to> <amount>")
+	default:
+		if _, err := ParseAction(verb); err != nil {
+			return "", fmt.Errorf("%w (try help)", err)
+		}
+		return "", fmt.Errorf("usage: %s <id> <amount>", verb)
+	}
+	amounts, err := parseCLIAmounts([]string{amount})
+	if err != nil {
+		return "", err
+	}
+	spec.Amount = amounts[0]
+	cmd, err := BuildCommand(spec, reg)
+	if err != nil {
+		return "", err
+	}
+	manager.Execute(cmd)
+	if err := cmd.Err(); err != nil {
+		return "", err
+	}
+	return "ok: " + cmd.Describe(), nil
+}
+
+func parseCLIAmounts(args []string) ([]Money, error) {
+	amounts := make([]Money, len(args))
+	for i, arg := range args {
+		amount, err := ParseMoney(arg)
+		if err != nil {
+			return nil, err
+		}
+		amounts[i] = amount
+	}
+	return amounts, nil
+}
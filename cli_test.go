@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func runScript(t *testing.T, script string) (string, *Registry) {
+	t.Helper()
+	reg := NewRegistry()
+	var out strings.Builder
+	if err := RunCLI(strings.NewReader(script), &out, reg, NewCommandManager()); err != nil {
+		t.Fatalf("RunCLI: %v", err)
+	}
+	return out.String(), reg
+}
+
+func TestRunCLI(t *testing.T) {
+	out, reg := runScript(t, `open acct1 100
+open acct2
+transfer acct1 acct2 50
+balance acct2
+undo
+balance acct2
+redo
+deposit acct1 5
+withdraw acct2 20
+`)
+	for _, want := range []string{
+		"opened acct1 with 100.00",
+		"ok: Transfer 50.00 from acct1 to acct2",
+		"acct2: 50.00",
+		"undone",
+		"acct2: 0.00",
+		"redone",
+		"ok: Deposit 5.00 to acct1",
+		"ok: Withdraw 20.00 from acct2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	acct1, _ := reg.Get("acct1")
+	acct2, _ := reg.Get("acct2")
+	if acct1.Balance() != 55 || acct2.Balance() != 30 {
+		t.Fatalf("balances %v, %v; want 55, 30", acct1.Balance(), acct2.Balance())
+	}
+}
+
+// Every bad line gets an error, and the lines after it still run.
+func TestRunCLIBadInput(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"balance nobody", "error: unknown account"},
+		{"deposit acct1 lots", "error:"},
+		{"deposit acct1", "error: usage: deposit <id> <amount>"},
+		{"transfer acct1 50", "error: usage: transfer <from> <to> <amount>"},
+		{"frobnicate acct1", "error: unknown action"},
+		{"withdraw acct1 500", "error: overdraft limit exceeded"},
+		{"open acct1", "error:"},
+		{"redo", "error: nothing to redo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			out, reg := runScript(t, "open acct1 100\n"+tt.line+"\ndeposit acct1 1\n")
+			if !strings.Contains(out, tt.want) {
+				t.Fatalf("output lacks %q:\n%s", tt.want, out)
+			}
+			if account, _ := reg.Get("acct1"); account.Balance() != 101 {
+				t.Fatalf("balance %v, want 101: the line after the error didn't run", account.Balance())
+			}
+		})
+	}
+}
+
+func TestRunCLIQuit(t *testing.T) {
+	out, reg := runScript(t, "open acct1 10\n\nquit\ndeposit acct1 5\n")
+	if strings.Contains(out, "Deposit") {
+		t.Fatalf("a line after quit ran:\n%s", out)
+	}
+	if account, _ := reg.Get("acct1"); account.Balance() != 10 {
+		t.Fatalf("balance %v, want 10", account.Balance())
+	}
+}
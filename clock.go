@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. Command timestamps, balance history, threshold
+// crossings, daily withdrawal limits, dedup retention, manager events and
+// the scheduler all read it through a Clock rather than calling time.Now,
+// so a test can pin it with a FakeClock. Waits and measured durations, such
+// as retry backoff, rate-limiter refills and metrics, stay on real time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// DefaultClock is the clock of every account and scheduler not given one of
+// its own, and of what belongs to no single account, such as manager events.
+// It reads the system time. Set it at start-up.
+var DefaultClock Clock = systemClock{}
+
+func now() time.Time {
+	return DefaultClock.Now()
+}
+
+// now reads the account's clock. A nil account reads DefaultClock, so a
+// command built for one gets its timestamps before Call fails it with
+// ErrNilAccount.
+func (account *BankAccount) now() time.Time {
+	if account == nil || account.Clock == nil {
+		return now()
+	}
+	return account.Clock.Now()
+}
+
+// FakeClock is a Clock that only moves when Set or Advance moves it. A
+// scheduler using one fires entries as soon as the fake time reaches them.
+// It is safe for concurrent use.
+type FakeClock struct {
+	mu       sync.Mutex
+	now      time.Time
+	watchers []chan struct{}
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to t, which may be in the past.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+	c.notifyLocked()
+}
+
+// Advance moves the clock forward by d and returns the new time.
+func (c *FakeClock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.notifyLocked()
+	return c.now
+}
+
+// notifyLocked expects the caller to hold c.mu.
+func (c *FakeClock) notifyLocked() {
+	for _, ch := range c.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch has ch signalled, without blocking, whenever the time is moved.
+func (c *FakeClock) watch(ch chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watchers = append(c.watchers, ch)
+}
+
+// clockWatcher is a Clock that can tell a scheduler the time has moved, so
+// it needn't wait for a real timer.
+type clockWatcher interface {
+	watch(ch chan struct{})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeDefaultClock puts DefaultClock on a FakeClock for the rest of the test.
+func fakeDefaultClock(t *testing.T, now time.Time) *FakeClock {
+	t.Helper()
+	clock := NewFakeClock(now)
+	saved := DefaultClock
+	DefaultClock = clock
+	t.Cleanup(func() { DefaultClock = saved })
+	return clock
+}
+
+func TestFakeClockStampsCommands(t *testing.T) {
+	created := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(created)
+	account := NewBankAccount(100, 0)
+	account.Clock = clock
+	cmd := NewBankAccountCommand(account, Deposit, 1)
+	ran := clock.Advance(time.Hour)
+	cmd.Call()
+	if !cmd.CreatedAt().Equal(created) || !cmd.ExecutedAt().Equal(ran) {
+		t.Fatalf("created %s, executed %s; want %s, %s", cmd.CreatedAt(), cmd.ExecutedAt(), created, ran)
+	}
+}
+
+// The daily allowance resets when the account's clock crosses midnight.
+func TestFakeClockDailyLimit(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC))
+	account := NewBankAccount(1000, 0)
+	account.Clock = clock
+	account.SetDailyWithdrawLimit(100)
+	if err := call(NewBankAccountCommand(account, Withdraw, 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(NewBankAccountCommand(account, Withdraw, 1)); !errors.Is(err, ErrDailyLimitExceeded) {
+		t.Fatalf("second withdrawal the same day = %v, want ErrDailyLimitExceeded", err)
+	}
+	clock.Advance(2 * time.Hour)
+	if err := call(NewBankAccountCommand(account, Withdraw, 100)); err != nil {
+		t.Fatalf("withdrawal the next day: %v", err)
+	}
+}
+
+// A command without an account, such as one decoded and never replayed, is
+// stamped by DefaultClock and fails with ErrNilAccount instead of panicking.
+func TestNilAccountCommandFails(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	fakeDefaultClock(t, now)
+	cmd := NewBankAccountCommand(nil, Deposit, 1)
+	if !cmd.CreatedAt().Equal(now) {
+		t.Fatalf("created %s, want %s from DefaultClock", cmd.CreatedAt(), now)
+	}
+	var failed error
+	cmd.OnFailure(func(_ Command, err error) { failed = err })
+	if err := call(cmd); !errors.Is(err, ErrNilAccount) || cmd.Succeeded() {
+		t.Fatalf("succeeded %v, err %v; want ErrNilAccount", cmd.Succeeded(), err)
+	}
+	if !errors.Is(failed, ErrNilAccount) {
+		t.Fatalf("OnFailure saw %v, want ErrNilAccount", failed)
+	}
+	if err := cmd.Undo(); err != nil {
+		t.Fatalf("Undo = %v, want nil", err)
+	}
+	if err := cmd.Prepare(); !errors.Is(err, ErrNilAccount) {
+		t.Fatalf("Prepare = %v, want ErrNilAccount", err)
+	}
+	cmd.Commit()
+	cmd.Rollback()
+	if _, err := NewMoneyTransferCommand(nil, NewBankAccount(0, 0), 1); !errors.Is(err, ErrNilAccount) {
+		t.Fatalf("NewMoneyTransferCommand = %v, want ErrNilAccount", err)
+	}
+}
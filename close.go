@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrAccountClosed    = errors.New("account is closed")
+	ErrOverdrawnClosure = errors.New("overdrawn account needs a settlement to close")
+	ErrHoldsOutstanding = errors.New("account has outstanding holds")
+	ErrIrreversible     = errors.New("command has no reversal")
+)
+
+// usable reports whether account can take part in a deposit or withdrawal
+// at all. The caller must hold account.mu.
+func (account *BankAccount) usable() error {
+	if account.closed {
+		return ErrAccountClosed
+	}
+	if account.frozen {
+		return ErrAccountFrozen
+	}
+	return nil
+}
+
+func (account *BankAccount) Closed() bool {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return account.closed
+}
+
+// CloseAccountCommand ends an account's life: it pays in the settlement, if
+// any, sweeps the whole remaining balance to sweepTo and marks the account
+// closed. The sweep ignores the minimum balance, since nothing is left to
+// keep it for. Undo reopens the account and takes the sweep and settlement
+// back out.
+type CloseAccountCommand struct {
+	id         string
+	account    *BankAccount
+	sweepTo    *BankAccount
+	settlement Money
+	swept      Money
+	succeeded  bool
+	executed   bool
+	err        error
+	createdAt  time.Time
+	executedAt time.Time
+}
+
+type CloseOption func(*CloseAccountCommand)
+
+// WithSettlement pays amount into the account before it is closed, which an
+// overdrawn account needs to get back to zero or above.
+func WithSettlement(amount float64) CloseOption {
+	return func(c *CloseAccountCommand) {
+		c.settlement = NewMoney(amount)
+	}
+}
+
+// NewCloseAccountCommand closes account, sweeping what is left to sweepTo.
+// sweepTo may be nil for an account that will have nothing left.
+func NewCloseAccountCommand(account, sweepTo *BankAccount, opts ...CloseOption) *CloseAccountCommand {
+	c := &CloseAccountCommand{id: newCommandID(), account: account, sweepTo: sweepTo, createdAt: account.now()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Call fails without changing anything if the account is already closed or
+// frozen, still has holds, or would be left overdrawn after the settlement,
+// and with ErrNilAccount if there is no account to close.
+func (c *CloseAccountCommand) Call() {
+	if c.account == nil {
+		c.err, c.succeeded = ErrNilAccount, false
+		return
+	}
+	unlock := lockAccounts(c.lockSet()...)
+	defer unlock()
+	if c.executed {
+		return
+	}
+	c.executedAt = c.account.now()
+	c.err = c.closeLocked()
+	c.succeeded = c.err == nil
+	c.executed = c.succeeded
+}
+
+func (c *CloseAccountCommand) closeLocked() error {
+	remaining, err := c.checkLocked()
+	if err != nil {
+		return err
+	}
+	account := c.account
+	if c.settlement > 0 {
+		account.adjust(c.settlement)
+	}
+	c.swept = remaining
+	if remaining > 0 {
+		account.adjust(-remaining)
+		c.sweepTo.adjust(remaining)
+	}
+	account.closed = true
+	return nil
+}
+
+// checkLocked returns what closing the account would sweep, or why it
+// can't be closed.
+func (c *CloseAccountCommand) checkLocked() (Money, error) {
+	account := c.account
+	if c.settlement < 0 {
+		return 0, ErrNegativeAmount
+	}
+	if err := account.usable(); err != nil {
+		return 0, err
+	}
+	if account.held > 0 {
+		return 0, fmt.Errorf("%w: %s on hold", ErrHoldsOutstanding, account.held)
+	}
+	remaining := account.balance + c.settlement
+	if remaining < 0 {
+		return 0, fmt.Errorf("%w: balance %s, settlement %s", ErrOverdrawnClosure, account.balance, c.settlement)
+	}
+	if remaining > 0 {
+		if c.sweepTo == nil {
+			return 0, fmt.Errorf("%w: nowhere to sweep %s", ErrNilAccount, remaining)
+		}
+		if err := c.sweepTo.usable(); err != nil {
+			return 0, fmt.Errorf("sweep to %s: %w", accountLabel(c.sweepTo), err)
+		}
+	}
+	return remaining, nil
+}
+
+// Undo fails, leaving the account closed, if the swept amount can no longer
+// be withdrawn from sweepTo.
+func (c *CloseAccountCommand) Undo() error {
+	if c.account == nil {
+		return nil
+	}
+	unlock := lockAccounts(c.lockSet()...)
+	defer unlock()
+	if !c.executed {
+		return nil
+	}
+	if c.swept > 0 {
+		if err := c.sweepTo.reverseDeposit(c.swept); err != nil {
+			return fmt.Errorf("undo %s: %w", c.Describe(), err)
+		}
+		c.account.adjust(c.swept)
+	}
+	if c.settlement > 0 {
+		c.account.adjust(-c.settlement)
+	}
+	c.account.closed = false
+	c.executed = false
+	return nil
+}
+
+func (c *CloseAccountCommand) lockSet() []*BankAccount {
+	if c.sweepTo == nil {
+		return []*BankAccount{c.account}
+	}
+	return []*BankAccount{c.account, c.sweepTo}
+}
+
+// Swept returns the amount the last Call moved to the sweep account.
+func (c *CloseAccountCommand) Swept() float64 {
+	return c.swept.Float64()
+}
+
+// Prepare returns ErrPrepareUnsupported: a closure can't reserve its sweep
+// ahead of time, so it can't take part in two-phase execution.
+func (c *CloseAccountCommand) Prepare() error {
+	return fmt.Errorf("%w: %s", ErrPrepareUnsupported, c.Name())
+}
+
+func (c *CloseAccountCommand) Commit() {}
+
+func (c *CloseAccountCommand) Rollback() {}
+
+func (c *CloseAccountCommand) Succeeded() bool {
+	return c.succeeded
+}
+
+func (c *CloseAccountCommand) SetSucceeded(value bool) {
+	c.succeeded = value
+}
+
+func (c *CloseAccountCommand) Err() error {
+	return c.err
+}
+
+func (c *CloseAccountCommand) CreatedAt() time.Time {
+	return c.createdAt
+}
+
+func (c *CloseAccountCommand) ExecutedAt() time.Time {
+	return c.executedAt
+}
+
+func (c *CloseAccountCommand) CommandID() string {
+	if c.id == "" {
+		c.id = newCommandID()
+	}
+	return c.id
+}
+
+// Reverse returns a command that fails with ErrIrreversible. Moving the
+// sweep back would need the account open, and reopening a closed account
+// isn't an operation of its own; only Undo does that.
+func (c *CloseAccountCommand) Reverse() Command {
+	err := fmt.Errorf("%w: undo the closure to reopen %s", ErrIrreversible, accountLabel(c.account))
+	return &rejectedCommand{account: c.account, what: "reversal of " + c.Describe(), err: err}
+}
+
+func (c *CloseAccountCommand) Clone() Command {
+	return &CloseAccountCommand{
+		id:         newCommandID(),
+		account:    c.account,
+		sweepTo:    c.sweepTo,
+		settlement: c.settlement,
+		createdAt:  c.account.now(),
+	}
+}
+
+func (c *CloseAccountCommand) Name() string {
+	return "CloseAccount"
+}
+
+func (c *CloseAccountCommand) Describe() string {
+	description := fmt.Sprintf("Close %s", accountLabel(c.account))
+	if c.sweepTo != nil {
+		description += fmt.Sprintf(", sweeping to %s", accountLabel(c.sweepTo))
+	}
+	if c.settlement > 0 {
+		description += fmt.Sprintf(" (settlement %s)", c.settlement)
+	}
+	return description
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// A closure can only be undone. Its reversal fails up front rather than
+// trying to pay the sweep back into the closed account.
+func TestCloseAccountReverseIrreversible(t *testing.T) {
+	account, sweepTo := NewBankAccount(40, 0), NewBankAccount(0, 0)
+	closure := NewCloseAccountCommand(account, sweepTo)
+	if err := call(closure); err != nil {
+		t.Fatal(err)
+	}
+	reversal := closure.Reverse()
+	if err := reversal.Validate(); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("Validate = %v, want ErrIrreversible", err)
+	}
+	if err := call(reversal); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("reversal = %v, want ErrIrreversible", err)
+	}
+	if account.Balance() != 0 || sweepTo.Balance() != 40 || !account.Closed() {
+		t.Fatalf("balances %v, %v, closed %v after the reversal; want 0, 40 and closed", account.Balance(), sweepTo.Balance(), account.Closed())
+	}
+	if err := closure.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance() != 40 || sweepTo.Balance() != 0 || account.Closed() {
+		t.Fatalf("balances %v, %v, closed %v after Undo; want 40, 0 and open", account.Balance(), sweepTo.Balance(), account.Closed())
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConditionalCommand runs inner only if predicate holds for account at the
+// moment it is called, for rules such as "top up if below 100". When the
+// predicate is false the command succeeds without doing anything and Undo
+// has nothing to reverse. The predicate runs without any account lock held,
+// so it may call the account's methods, but the balance can still change
+// between the check and inner running.
+type ConditionalCommand struct {
+	id        string
+	account   *BankAccount
+	predicate func(*BankAccount) bool
+	inner     Command
+
+	// ran records whether the last Call passed the predicate and ran inner.
+	ran        bool
+	succeeded  bool
+	createdAt  time.Time
+	executedAt time.Time
+
+	// err is ErrNilAccount when there is no account to test the predicate
+	// on; any other failure is inner's.
+	err error
+}
+
+func NewConditionalCommand(account *BankAccount, predicate func(*BankAccount) bool, inner Command) *ConditionalCommand {
+	return &ConditionalCommand{id: newCommandID(), account: account, predicate: predicate, inner: inner, createdAt: account.now()}
+}
+
+func (c *ConditionalCommand) Call() {
+	if c.ran && c.inner.Succeeded() {
+		return
+	}
+	c.executedAt = c.account.now()
+	if c.account == nil {
+		c.err, c.ran, c.succeeded = ErrNilAccount, false, false
+		return
+	}
+	c.ran = c.predicate(c.account)
+	if c.ran {
+		c.inner.Call()
+	}
+	c.succeeded = !c.ran || c.inner.Succeeded()
+}
+
+func (c *ConditionalCommand) Undo() error {
+	if !c.ran {
+		return nil
+	}
+	if err := c.inner.Undo(); err != nil {
+		return err
+	}
+	c.ran = false
+	return nil
+}
+
+// Skipped reports whether the last Call found the predicate false.
+func (c *ConditionalCommand) Skipped() bool {
+	return !c.executedAt.IsZero() && !c.ran
+}
+
+func (c *ConditionalCommand) Prepare() error {
+	if c.account == nil {
+		return ErrNilAccount
+	}
+	c.executedAt = c.account.now()
+	c.ran = c.predicate(c.account)
+	if !c.ran {
+		return nil
+	}
+	return c.inner.Prepare()
+}
+
+func (c *ConditionalCommand) Commit() {
+	if c.ran {
+		c.inner.Commit()
+	}
+	c.succeeded = !c.ran || c.inner.Succeeded()
+}
+
+func (c *ConditionalCommand) Rollback() {
+	if c.ran {
+		c.inner.Rollback()
+		c.ran = false
+	}
+	c.succeeded = false
+}
+
+func (c *ConditionalCommand) Succeeded() bool {
+	if c.ran {
+		return c.inner.Succeeded()
+	}
+	return c.succeeded
+}
+
+func (c *ConditionalCommand) SetSucceeded(value bool) {
+	c.succeeded = value
+	if c.ran {
+		c.inner.SetSucceeded(value)
+	}
+}
+
+func (c *ConditionalCommand) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.ran {
+		return c.inner.Err()
+	}
+	return nil
+}
+
+func (c *ConditionalCommand) CreatedAt() time.Time {
+	return c.createdAt
+}
+
+func (c *ConditionalCommand) ExecutedAt() time.Time {
+	return c.executedAt
+}
+
+// Reverse reverses inner if the last Call ran it and is a no-op otherwise.
+func (c *ConditionalCommand) Reverse() Command {
+	if !c.ran {
+		return NewBankAccountCommand(c.account, Deposit, 0)
+	}
+	return c.inner.Reverse()
+}
+
+func (c *ConditionalCommand) Clone() Command {
+	return NewConditionalCommand(c.account, c.predicate, c.inner.Clone())
+}
+
+func (c *ConditionalCommand) Name() string {
+	return "Conditional"
+}
+
+func (c *ConditionalCommand) Describe() string {
+	if c.Skipped() {
+		return fmt.Sprintf("Skipped: %s", c.inner.Describe())
+	}
+	return fmt.Sprintf("If condition on %s holds: %s", accountLabel(c.account), c.inner.Describe())
+}
+
+func (c *ConditionalCommand) Priority() int {
+	return priorityOf(c.inner)
+}
+
+func (c *ConditionalCommand) children() []Command {
+	if !c.ran {
+		return nil
+	}
+	return []Command{c.inner}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// ContextCommand is a Command whose execution can be canceled.
+type ContextCommand interface {
+	Command
+	CallCtx(ctx context.Context) error
+}
+
+// CallCtx returns ErrAlreadyExecuted, rather than silently doing nothing, when
+// the command's effect is already applied.
+func (c *BankAccountCommand) CallCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.executed {
+		return ErrAlreadyExecuted
+	}
+	c.Call()
+	return c.Err()
+}
+
+// CallCtx checks ctx before each child. If ctx is canceled part-way through,
+// the children that already ran are undone in reverse order and every child
+// is marked as failed, so the accounts are back in their pre-call state and
+// ctx.Err() is returned. Otherwise it behaves like Call and returns Err().
+func (c *CompositeBankAccountCommand) CallCtx(ctx context.Context) error {
+	c.rollbackErr, c.suspended = nil, nil
+	if c.refusedErr = checkCommandLimits(c); c.refusedErr != nil {
+		return c.refusedErr
+	}
+	c.arrange()
+	for i := c.start(); i < len(c.commands); i++ {
+		cmd := c.child(i)
+		if err := ctx.Err(); err != nil {
+			return c.rollback(i, err)
+		}
+		if cc, ok := cmd.(ContextCommand); ok {
+			// A nested command that was canceled has already rolled itself
+			// back.
+			if err := cc.CallCtx(ctx); ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+				return c.rollback(i, err)
+			}
+		} else {
+			cmd.Call()
+		}
+		if c.stopOnFailure && !cmd.Succeeded() {
+			c.rollbackErr = c.rollback(i+1, nil)
+			return c.Err()
+		}
+	}
+	return c.Err()
+}
+
+// rollback undoes the children from the resume point up to n in reverse
+// order and marks every child as failed. Children that couldn't be undone are reported alongside
+// err, which may be nil. Deposits are booked against the suspense account
+// instead of undone, if there is one.
+func (c *CompositeBankAccountCommand) rollback(n int, err error) error {
+	errs := []error{err}
+	for i := n - 1; i >= c.start(); i-- {
+		if c.suspend(c.child(i)) {
+			continue
+		}
+		if undoErr := c.child(i).Undo(); undoErr != nil {
+			errs = append(errs, undoErr)
+		}
+	}
+	c.SetSucceeded(false)
+	return errors.Join(errs...)
+}
+
+// CallCtx checks ctx once up front; the transfer itself is atomic and is not
+// interrupted.
+func (c *MoneyTransferCommand) CallCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.Call()
+	return c.Err()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+type CurrencyConverter interface {
+	Rate(from, to string) (float64, error)
+}
+
+// WithConverter supplies the exchange rate for a transfer between accounts
+// holding different currencies.
+func WithConverter(converter CurrencyConverter) TransferOption {
+	return func(c *MoneyTransferCommand) {
+		c.converter = converter
+	}
+}
+
+// FixedRates is a CurrencyConverter backed by a table keyed "FROM/TO", for
+// example "USD/EUR".
+type FixedRates map[string]float64
+
+func (r FixedRates) Rate(from, to string) (float64, error) {
+	rate, ok := r[from+"/"+to]
+	if !ok {
+		return 0, fmt.Errorf("%s/%s not in table", from, to)
+	}
+	return rate, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// The amount credited is converted once, so a rate that changes between
+// Call and Undo doesn't change what Undo debits.
+func TestConvertedTransferUndoIgnoresRateChanges(t *testing.T) {
+	usd, eur := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	usd.Currency, eur.Currency = "USD", "EUR"
+	rates := FixedRates{"USD/EUR": 0.917}
+	transfer, err := NewMoneyTransferCommand(usd, eur, 33.33, WithConverter(rates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		transfer.Call()
+		if !transfer.Succeeded() {
+			t.Fatalf("cycle %d: %v", i, transfer.Err())
+		}
+		rates["USD/EUR"] = 0.5 + float64(i)
+		if got := transfer.AppliedAmount(); got != 30.56 {
+			t.Fatalf("cycle %d: AppliedAmount = %v, want 30.56", i, got)
+		}
+		if err := transfer.Undo(); err != nil {
+			t.Fatalf("cycle %d: Undo: %v", i, err)
+		}
+		if got := transfer.AppliedAmount(); got != 0 {
+			t.Fatalf("cycle %d: AppliedAmount after Undo = %v, want 0", i, got)
+		}
+		if usd.BalanceMoney() != NewMoney(100) || eur.BalanceMoney() != 0 {
+			t.Fatalf("cycle %d: balances %v, %v; want 100, 0", i, usd.Balance(), eur.Balance())
+		}
+	}
+}
+
+func TestConvertedTransferNeedsRate(t *testing.T) {
+	usd, eur := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	usd.Currency, eur.Currency = "USD", "EUR"
+	if _, err := NewMoneyTransferCommand(usd, eur, 10, WithConverter(FixedRates{})); !errors.Is(err, ErrNoExchangeRate) {
+		t.Fatalf("NewMoneyTransferCommand = %v, want ErrNoExchangeRate", err)
+	}
+}
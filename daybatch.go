@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DayBatch groups a day's commands, such as an end-of-day run, into one
+// unit that UndoAll can back out wholesale when the run turns out to be
+// wrong. It only records commands; they run as they normally would, through
+// Execute or elsewhere. It is safe for concurrent use.
+type DayBatch struct {
+	mu       sync.Mutex
+	day      time.Time
+	commands []Command
+}
+
+// UndoFailure reports a command UndoAll couldn't reverse. Index is its
+// position in the batch, in the order it was recorded.
+type UndoFailure struct {
+	Index   int
+	Command Command
+	Err     error
+}
+
+func (f UndoFailure) Error() string {
+	return fmt.Sprintf("command %d (%s): %v", f.Index, f.Command.Describe(), f.Err)
+}
+
+func (f UndoFailure) Unwrap() error {
+	return f.Err
+}
+
+// NewDayBatch starts the batch for the calendar day containing day, in
+// day's location.
+func NewDayBatch(day time.Time) *DayBatch {
+	y, m, d := day.Date()
+	return &DayBatch{day: time.Date(y, m, d, 0, 0, 0, 0, day.Location())}
+}
+
+// Day returns midnight at the start of the batch's day.
+func (b *DayBatch) Day() time.Time {
+	return b.day
+}
+
+// Execute calls cmd and records it, returning its error.
+func (b *DayBatch) Execute(cmd Command) error {
+	cmd.Call()
+	b.Add(cmd)
+	return cmd.Err()
+}
+
+// Add records a command that has been or will be called elsewhere, for
+// example by a CommandManager or a Scheduler.
+func (b *DayBatch) Add(cmd Command) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.commands = append(b.commands, cmd)
+}
+
+// Commands returns the recorded commands in the order they were added.
+func (b *DayBatch) Commands() []Command {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Command(nil), b.commands...)
+}
+
+// UndoAll reverses the day, latest command first by execution time, so a
+// withdrawal of deposited funds is put back before the deposit is taken
+// out. Each command's own Undo decides whether it can be reversed; those
+// with nothing to reverse, such as failed ones, are skipped. It carries on
+// past a command that refuses, for example a deposit a later command
+// outside the batch has spent, and returns one UndoFailure for each, latest
+// first. Nil means the whole day was backed out. Calling UndoAll again
+// retries only the commands that are still applied.
+func (b *DayBatch) UndoAll() []UndoFailure {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	order := make([]int, len(b.commands))
+	for i := range order {
+		order[i] = i
+	}
+	// Commands executed at the same instant are undone latest recorded
+	// first.
+	sort.Slice(order, func(i, j int) bool {
+		ti, tj := b.commands[order[i]].ExecutedAt(), b.commands[order[j]].ExecutedAt()
+		if ti.Equal(tj) {
+			return order[i] > order[j]
+		}
+		return ti.After(tj)
+	})
+	var failures []UndoFailure
+	for _, i := range order {
+		cmd := b.commands[i]
+		if !cmd.CanUndo() {
+			continue
+		}
+		if err := cmd.Undo(); err != nil {
+			failures = append(failures, UndoFailure{Index: i, Command: cmd, Err: err})
+		}
+	}
+	return failures
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewDayBatch(t *testing.T) {
+	zone := time.FixedZone("BRT", -3*60*60)
+	batch := NewDayBatch(time.Date(2026, 3, 1, 23, 30, 0, 0, zone))
+	if want := time.Date(2026, 3, 1, 0, 0, 0, 0, zone); !batch.Day().Equal(want) || batch.Day().Location() != zone {
+		t.Fatalf("Day = %v, want %v", batch.Day(), want)
+	}
+}
+
+// UndoAll goes by execution time, not by the order commands were added,
+// so the withdrawal is put back before the deposit it spent is taken out.
+func TestDayBatchUndoAll(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	account := NewBankAccount(0, 0)
+	account.Clock = clock
+	deposit := NewBankAccountCommand(account, Deposit, 50)
+	withdrawal := NewBankAccountCommand(account, Withdraw, 40)
+	runHourly(clock, deposit, withdrawal)
+
+	batch := NewDayBatch(clock.Now())
+	batch.Add(withdrawal)
+	batch.Add(deposit)
+	if err := batch.Execute(NewBankAccountCommand(account, Withdraw, 1000)); !errors.Is(err, ErrOverdraftExceeded) {
+		t.Fatalf("Execute = %v, want ErrOverdraftExceeded", err)
+	}
+	if len(batch.Commands()) != 3 {
+		t.Fatalf("%d commands recorded, want 3", len(batch.Commands()))
+	}
+	if failures := batch.UndoAll(); failures != nil {
+		t.Fatalf("UndoAll = %v, want the whole day backed out", failures)
+	}
+	if account.Balance() != 0 || deposit.CanUndo() || withdrawal.CanUndo() {
+		t.Fatalf("balance %v after UndoAll, want 0 with nothing left applied", account.Balance())
+	}
+}
+
+// A deposit spent outside the batch can't be taken back yet; UndoAll
+// reports it, reverses the rest and can be retried.
+func TestDayBatchUndoAllRetries(t *testing.T) {
+	account := NewBankAccount(0, 0)
+	batch := NewDayBatch(time.Now())
+	if err := batch.Execute(NewBankAccountCommand(account, Deposit, 50)); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Execute(NewBankAccountCommand(account, Deposit, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := account.Withdraw(40); err != nil {
+		t.Fatal(err)
+	}
+
+	failures := batch.UndoAll()
+	if len(failures) != 1 || failures[0].Index != 0 || failures[0].Command != batch.Commands()[0] || !errors.Is(failures[0], ErrOverdraftExceeded) {
+		t.Fatalf("UndoAll = %v, want the first deposit failing with ErrOverdraftExceeded", failures)
+	}
+	if account.Balance() != 10 {
+		t.Fatalf("balance %v, want 10 with the second deposit undone", account.Balance())
+	}
+
+	if err := account.Deposit(40); err != nil {
+		t.Fatal(err)
+	}
+	if failures := batch.UndoAll(); failures != nil {
+		t.Fatalf("retried UndoAll = %v, want success", failures)
+	}
+	if account.Balance() != 0 {
+		t.Fatalf("balance %v after the retry, want 0", account.Balance())
+	}
+}
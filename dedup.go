@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrDuplicateCommand = errors.New("duplicate command")
+
+var commandSeq atomic.Uint64
+
+// newCommandID returns a process-wide unique, increasing ID such as "cmd-42".
+func newCommandID() string {
+	return fmt.Sprintf("cmd-%d", commandSeq.Add(1))
+}
+
+// CommandID identifies the command. Constructors assign it; a command built
+// as a struct literal is given one the first time it is asked for. Clones
+// get a new ID, while JSON and gob round trips keep the original.
+func (c *BankAccountCommand) CommandID() string {
+	if c.id == "" {
+		c.id = newCommandID()
+	}
+	return c.id
+}
+
+func (c *CompositeBankAccountCommand) CommandID() string {
+	if c.id == "" {
+		c.id = newCommandID()
+	}
+	return c.id
+}
+
+func (c *InterestCommand) CommandID() string {
+	if c.id == "" {
+		c.id = newCommandID()
+	}
+	return c.id
+}
+
+func (c *ConditionalCommand) CommandID() string {
+	if c.id == "" {
+		c.id = newCommandID()
+	}
+	return c.id
+}
+
+// DedupExecutor passes commands to a CommandManager at most once per ID, so
+// a pipeline with at-least-once delivery can't apply the same transfer
+// twice. Only commands that succeeded are remembered, so a failed one can be
+// delivered again and retried. IDs are forgotten once retention has passed;
+// a retention of zero remembers them forever. It is safe for concurrent use,
+// and can share its manager with a RateLimiter, but the manager mustn't also
+// be used directly; see CommandManager.
+type DedupExecutor struct {
+	manager   *CommandManager
+	retention time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// running holds IDs being executed, so a duplicate arriving meanwhile
+	// is refused too.
+	running map[string]bool
+}
+
+func NewDedupExecutor(manager *CommandManager, retention time.Duration) *DedupExecutor {
+	return &DedupExecutor{
+		manager:   manager,
+		retention: retention,
+		seen:      make(map[string]time.Time),
+		running:   make(map[string]bool),
+	}
+}
+
+// Execute runs cmd through the manager unless a command with the same ID
+// succeeded within the retention window or is running right now, in which
+// case it returns ErrDuplicateCommand.
+func (d *DedupExecutor) Execute(cmd Command) error {
+	id := cmd.CommandID()
+	if err := d.claim(id, now()); err != nil {
+		return err
+	}
+	d.manager.executeSerialized(cmd)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.running, id)
+	if cmd.Succeeded() {
+		d.seen[id] = now()
+	}
+	return nil
+}
+
+// Seen reports whether a command with id is remembered as executed.
+func (d *DedupExecutor) Seen(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune(now())
+	_, ok := d.seen[id]
+	return ok
+}
+
+func (d *DedupExecutor) claim(id string, now time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune(now)
+	if _, ok := d.seen[id]; ok || d.running[id] {
+		return fmt.Errorf("%w: %s", ErrDuplicateCommand, id)
+	}
+	d.running[id] = true
+	return nil
+}
+
+// prune forgets IDs older than the retention window. The caller must hold
+// d.mu.
+func (d *DedupExecutor) prune(now time.Time) {
+	if d.retention <= 0 {
+		return
+	}
+	for id, at := range d.seen {
+		if now.Sub(at) > d.retention {
+			delete(d.seen, id)
+		}
+	}
+}
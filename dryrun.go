@@ -0,0 +1,23 @@
+package main
+
+// DryRun reports whether cmd would succeed, the balances it would leave on
+// accounts (in the same order) and the error it would fail with. It runs a
+// clone of cmd, so cmd itself stays un-executed, and then restores a
+// snapshot of each account, so they end up exactly as they were even if the
+// clone only partially applied. The accounts must include every account cmd
+// touches. Other goroutines can see the projected balances while the dry
+// run is in progress.
+func DryRun(cmd Command, accounts ...*BankAccount) (bool, []float64, error) {
+	saved := make([]AccountSnapshot, len(accounts))
+	for i, account := range accounts {
+		saved[i] = account.Snapshot()
+	}
+	rehearsal := cmd.Clone()
+	rehearsal.Call()
+	projected := make([]float64, len(accounts))
+	for i, account := range accounts {
+		projected[i] = account.Balance()
+		account.Restore(saved[i])
+	}
+	return rehearsal.Succeeded(), projected, rehearsal.Err()
+}
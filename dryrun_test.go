@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	from, to := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	from.SetDailyWithdrawLimit(40)
+	transfer, err := NewMoneyTransferCommand(from, to, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, balances, err := DryRun(transfer, from, to)
+	if !ok || err != nil {
+		t.Fatalf("DryRun = %v, %v; want a success", ok, err)
+	}
+	if balances[0] != 60 || balances[1] != 40 {
+		t.Fatalf("projected balances %v, want [60 40]", balances)
+	}
+	if from.Balance() != 100 || to.Balance() != 0 || len(from.History()) != 0 {
+		t.Fatalf("balances %v, %v with %d history entries after the dry run; want 100, 0 and none", from.Balance(), to.Balance(), len(from.History()))
+	}
+	if transfer.CanUndo() || !transfer.ExecutedAt().IsZero() {
+		t.Fatal("the dry run executed the command itself")
+	}
+	// The rehearsal's withdrawal didn't use up the day's allowance.
+	if err := call(transfer); err != nil {
+		t.Fatalf("the real transfer after the dry run: %v", err)
+	}
+}
+
+// A command that fails partway is reported with its error, and the legs
+// it did apply are wiped out with the rest.
+func TestDryRunPartialFailure(t *testing.T) {
+	a, b := NewBankAccount(100, 0), NewBankAccount(10, 0)
+	composite := NewCompositeCommand(false,
+		NewBankAccountCommand(a, Deposit, 25),
+		NewBankAccountCommand(b, Withdraw, 50),
+	)
+	ok, balances, err := DryRun(composite, a, b)
+	if ok || !errors.Is(err, ErrOverdraftExceeded) {
+		t.Fatalf("DryRun = %v, %v; want a failure with ErrOverdraftExceeded", ok, err)
+	}
+	if balances[0] != 125 || balances[1] != 10 {
+		t.Fatalf("projected balances %v, want [125 10]", balances)
+	}
+	if a.Balance() != 100 || b.Balance() != 10 {
+		t.Fatalf("balances %v, %v after the dry run; want 100, 10", a.Balance(), b.Balance())
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "errors"
+
+// errorCodes gives the errors a command or request can fail with a stable
+// code that clients and scenarios can switch on. The first match wins.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrUnknownAccount, "unknown_account"},
+	{ErrDuplicateAccount, "duplicate_account"},
+	{ErrMissingAccountID, "missing_account_id"},
+	{ErrNegativeAmount, "negative_amount"},
+	{ErrInvalidAmount, "invalid_amount"},
+	{ErrSelfTransfer, "self_transfer"},
+	{ErrZeroAmount, "zero_amount"},
+	{ErrAccountFrozen, "account_frozen"},
+	{ErrAccountClosed, "account_closed"},
+	{ErrVetoed, "vetoed"},
+	{ErrOverdraftExceeded, "overdraft_exceeded"},
+	{ErrBelowMinimumBalance, "below_minimum_balance"},
+	{ErrDailyLimitExceeded, "daily_limit_exceeded"},
+	{ErrAmountTooLarge, "amount_too_large"},
+	{ErrNoExchangeRate, "no_exchange_rate"},
+	{ErrUnknownAction, "unknown_action"},
+	{ErrInvalidSpec, "invalid_request"},
+	{ErrIdempotencyConflict, "idempotency_conflict"},
+}
+
+// ErrorCode returns the stable code for err, or "internal_error" if it
+// wraps none of the errors listed in errorCodes.
+func ErrorCode(err error) string {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return "internal_error"
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// call runs cmd and returns the error it failed with.
+func call(cmd Command) error {
+	cmd.Call()
+	return cmd.Err()
+}
+
+// Every exported sentinel comes back, through whatever wrapping the failure
+// path adds, as something errors.Is recognises.
+func TestFailuresWrapSentinels(t *testing.T) {
+	tests := []struct {
+		sentinel error
+		fail     func(t *testing.T) error
+	}{
+		{ErrOverdraftExceeded, func(t *testing.T) error {
+			return call(NewBankAccountCommand(NewBankAccount(10, 0), Withdraw, 20))
+		}},
+		{ErrNegativeAmount, func(t *testing.T) error {
+			return call(NewBankAccountCommand(NewBankAccount(10, 0), Deposit, -1))
+		}},
+		{ErrInvalidAmount, func(t *testing.T) error {
+			_, err := NewMoneyTransferCommand(NewBankAccount(10, 0), NewBankAccount(0, 0), math.NaN())
+			return err
+		}},
+		{ErrZeroAmount, func(t *testing.T) error {
+			rejectZeroAmounts(t)
+			return call(NewBankAccountCommand(NewBankAccount(10, 0), Deposit, 0))
+		}},
+		{ErrSelfTransfer, func(t *testing.T) error {
+			account := NewBankAccount(10, 0)
+			_, err := NewMoneyTransferCommand(account, account, 1)
+			return err
+		}},
+		{ErrAccountFrozen, func(t *testing.T) error {
+			account := NewBankAccount(10, 0)
+			account.Freeze()
+			return call(NewBankAccountCommand(account, Deposit, 1))
+		}},
+		{ErrAccountClosed, func(t *testing.T) error {
+			account := NewBankAccount(0, 0)
+			if err := call(NewCloseAccountCommand(account, nil)); err != nil {
+				t.Fatal(err)
+			}
+			return call(NewBankAccountCommand(account, Deposit, 1))
+		}},
+		{ErrOverdrawnClosure, func(t *testing.T) error {
+			return call(NewCloseAccountCommand(NewBankAccount(-5, -10), NewBankAccount(0, 0)))
+		}},
+		{ErrHoldsOutstanding, func(t *testing.T) error {
+			account := NewBankAccount(10, 0)
+			if err := account.Authorize(5); err != nil {
+				t.Fatal(err)
+			}
+			return call(NewCloseAccountCommand(account, NewBankAccount(0, 0)))
+		}},
+		{ErrInsufficientHold, func(t *testing.T) error {
+			return NewBankAccount(10, 0).Capture(5)
+		}},
+		{ErrDailyLimitExceeded, func(t *testing.T) error {
+			account := NewBankAccount(100, 0)
+			account.SetDailyWithdrawLimit(10)
+			return call(NewBankAccountCommand(account, Withdraw, 20))
+		}},
+		{ErrBelowMinimumBalance, func(t *testing.T) error {
+			account := NewBankAccount(100, 0)
+			account.SetMinimumBalance(50)
+			return call(NewBankAccountCommand(account, Withdraw, 60))
+		}},
+		{ErrAmountTooLarge, func(t *testing.T) error {
+			account := NewBankAccount(100, 0)
+			account.SetMaxTransactionAmount(10)
+			return call(NewBankAccountCommand(account, Deposit, 20))
+		}},
+		{ErrVetoed, func(t *testing.T) error {
+			account := NewBankAccount(100, 0)
+			account.BeforeExecute = append(account.BeforeExecute, func(Command) error { return errors.New("no") })
+			return call(NewBankAccountCommand(account, Deposit, 1))
+		}},
+		{ErrVersionConflict, func(t *testing.T) error {
+			cmd := NewBankAccountCommand(NewBankAccount(100, 0), Deposit, 1)
+			cmd.ExpectVersion(5)
+			return call(cmd)
+		}},
+		{ErrAlreadyExecuted, func(t *testing.T) error {
+			cmd := NewBankAccountCommand(NewBankAccount(100, 0), Deposit, 1)
+			cmd.Call()
+			return cmd.Prepare()
+		}},
+		{ErrNoExchangeRate, func(t *testing.T) error {
+			usd, eur := NewBankAccount(100, 0), NewBankAccount(0, 0)
+			usd.Currency, eur.Currency = "USD", "EUR"
+			_, err := NewMoneyTransferCommand(usd, eur, 1)
+			return err
+		}},
+		{ErrUnknownAction, func(t *testing.T) error {
+			_, err := ParseAction("borrow")
+			return err
+		}},
+		{ErrUnknownAccount, func(t *testing.T) error {
+			_, err := queueRegistry(t).Transfer("a", "nobody", 1, "")
+			return err
+		}},
+		{ErrDuplicateAccount, func(t *testing.T) error {
+			account := NewBankAccount(0, 0)
+			account.ID = "a"
+			return queueRegistry(t).Add(account)
+		}},
+		{ErrMissingAccountID, func(t *testing.T) error {
+			return NewRegistry().Add(NewBankAccount(0, 0))
+		}},
+		{ErrIdempotencyConflict, func(t *testing.T) error {
+			reg := queueRegistry(t)
+			if _, err := reg.Transfer("a", "b", 1, "key"); err != nil {
+				t.Fatal(err)
+			}
+			_, err := reg.Transfer("a", "b", 2, "key")
+			return err
+		}},
+		{ErrNothingToUndo, func(t *testing.T) error {
+			return NewCommandManager().Undo()
+		}},
+		{ErrNothingToRedo, func(t *testing.T) error {
+			return NewCommandManager().Redo()
+		}},
+		{ErrNilAccount, func(t *testing.T) error {
+			_, err := NewCommandBuilder().Deposit(nil, 1).Build()
+			return err
+		}},
+		{ErrNoRecipients, func(t *testing.T) error {
+			_, err := NewSplitTransferCommand(NewBankAccount(10, 0), nil)
+			return err
+		}},
+		{ErrSharedAccount, func(t *testing.T) error {
+			account := NewBankAccount(10, 0)
+			_, err := NewParallelCommand(NewBankAccountCommand(account, Deposit, 1), NewBankAccountCommand(account, Deposit, 1))
+			return err
+		}},
+		{ErrInvalidDay, func(t *testing.T) error {
+			s := NewScheduler(nil)
+			defer s.Stop()
+			_, err := NewStandingOrder(s, NewBankAccount(0, 0), NewBankAccount(0, 0), 1, 0, SkipPayment)
+			return err
+		}},
+		{ErrQueueFull, func(t *testing.T) error {
+			s := NewScheduler(nil)
+			defer s.Stop()
+			s.SetCapacity(1)
+			at := time.Now().Add(time.Hour)
+			if _, err := s.Schedule(NewBankAccountCommand(NewBankAccount(0, 0), Deposit, 1), at); err != nil {
+				t.Fatal(err)
+			}
+			_, err := s.Schedule(NewBankAccountCommand(NewBankAccount(0, 0), Deposit, 1), at)
+			return err
+		}},
+		{ErrSchedulerStopped, func(t *testing.T) error {
+			s := NewScheduler(nil)
+			s.Stop()
+			_, err := s.Schedule(NewBankAccountCommand(NewBankAccount(0, 0), Deposit, 1), time.Now())
+			return err
+		}},
+		{ErrMalformedCommand, func(t *testing.T) error {
+			var cmd BankAccountCommand
+			return cmd.UnmarshalJSON([]byte(`{}`))
+		}},
+		{ErrInvalidMoney, func(t *testing.T) error {
+			var cmd BankAccountCommand
+			return cmd.UnmarshalJSON([]byte(`{"action":"deposit","amount":"NaN"}`))
+		}},
+		{ErrUnsupportedVersion, func(t *testing.T) error {
+			var cmd BankAccountCommand
+			return cmd.UnmarshalJSON([]byte(`{"version":99,"action":"deposit","amount":1}`))
+		}},
+		{ErrTransactionDone, func(t *testing.T) error {
+			tx := Begin()
+			if err := tx.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			return tx.Commit()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sentinel.Error(), func(t *testing.T) {
+			if err := tt.fail(t); !errors.Is(err, tt.sentinel) {
+				t.Fatalf("got %v, want an error wrapping %q", err, tt.sentinel)
+			}
+		})
+	}
+}
+
+// Every command type without its account fails with ErrNilAccount when
+// called or validated, describes itself, and has nothing to undo.
+func TestNilAccountEveryCommand(t *testing.T) {
+	leaf := func() Command { return NewBankAccountCommand(nil, Deposit, 1) }
+	parallel, err := NewParallelCommand(leaf())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cmd  Command
+	}{
+		{"BankAccountCommand", leaf()},
+		{"MoneyTransferCommand", &MoneyTransferCommand{}},
+		{"CompositeBankAccountCommand", NewCompositeCommand(true, leaf())},
+		{"InterestCommand", NewInterestCommand(nil, 0.01, SkipNegative)},
+		{"CloseAccountCommand", NewCloseAccountCommand(nil, nil)},
+		{"ConditionalCommand", NewConditionalCommand(nil, func(a *BankAccount) bool { return a.Balance() > 0 }, leaf())},
+		{"RetryCommand", NewRetryCommand(leaf(), 3)},
+		{"ParallelCommand", parallel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cmd.Describe() == "" {
+				t.Fatal("Describe returned nothing")
+			}
+			if err := tt.cmd.Validate(); !errors.Is(err, ErrNilAccount) {
+				t.Fatalf("Validate = %v, want ErrNilAccount", err)
+			}
+			if err := call(tt.cmd); !errors.Is(err, ErrNilAccount) || tt.cmd.Succeeded() {
+				t.Fatalf("succeeded %v, err %v; want ErrNilAccount", tt.cmd.Succeeded(), err)
+			}
+			if tt.cmd.CanUndo() {
+				t.Fatal("CanUndo after a failed Call")
+			}
+			if err := tt.cmd.Undo(); err != nil {
+				t.Fatalf("Undo = %v, want nil with nothing applied", err)
+			}
+			tt.cmd.Describe()
+		})
+	}
+}
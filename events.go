@@ -0,0 +1,104 @@
+package main
+
+import "time"
+
+// EventBufferSize is how many events a CommandManager holds for a slow
+// consumer before it starts dropping them.
+const EventBufferSize = 64
+
+type EventKind int
+
+const (
+	EventExecuted EventKind = iota
+	EventUndone
+	EventRedone
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventExecuted:
+		return "executed"
+	case EventUndone:
+		return "undone"
+	case EventRedone:
+		return "redone"
+	}
+	return "unknown"
+}
+
+// CommandEvent describes one command the manager executed, undid or redid.
+// Amount is the amount moved by bank account commands and transfers, the
+// interest for interest commands, and zero otherwise.
+type CommandEvent struct {
+	Kind        EventKind
+	Command     Command
+	Name        string
+	Description string
+	Amount      Money
+	Succeeded   bool
+	Time        time.Time
+}
+
+// Events returns a channel streaming an event for every command the manager
+// executes, undoes or redoes from now on. Sending never blocks: once
+// EventBufferSize events are waiting, newer ones are dropped and counted in
+// DroppedEvents. The channel is closed by Close.
+func (m *CommandManager) Events() <-chan CommandEvent {
+	if m.events == nil {
+		m.events = make(chan CommandEvent, EventBufferSize)
+		if m.closed {
+			close(m.events)
+		}
+	}
+	return m.events
+}
+
+// DroppedEvents reports how many events were discarded because the Events
+// channel was full.
+func (m *CommandManager) DroppedEvents() int {
+	return m.dropped
+}
+
+// Close closes the Events channel. The manager keeps working afterwards but
+// no longer emits events. Closing twice is a no-op.
+func (m *CommandManager) Close() {
+	if m.closed {
+		return
+	}
+	m.closed = true
+	if m.events != nil {
+		close(m.events)
+	}
+}
+
+func (m *CommandManager) emit(kind EventKind, cmd Command) {
+	if m.events == nil || m.closed {
+		return
+	}
+	event := CommandEvent{
+		Kind:        kind,
+		Command:     cmd,
+		Name:        cmd.Name(),
+		Description: cmd.Describe(),
+		Amount:      commandAmount(cmd),
+		Succeeded:   cmd.Succeeded(),
+		Time:        now(),
+	}
+	select {
+	case m.events <- event:
+	default:
+		m.dropped++
+	}
+}
+
+func commandAmount(cmd Command) Money {
+	switch c := cmd.(type) {
+	case *BankAccountCommand:
+		return c.amount
+	case *MoneyTransferCommand:
+		return c.amount
+	case *InterestCommand:
+		return c.interest
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var exportStatement = Statement{
+	AccountID:      "acct",
+	Holder:         &Holder{Name: "Acme Ltd", Type: Business, Opened: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	OpeningBalance: NewMoney(100),
+	ClosingBalance: NewMoney(124.5),
+	Lines: []StatementLine{
+		{Time: time.Date(2026, 3, 1, 10, 0, 0, 0, time.FixedZone("EET", 2*60*60)), Action: Deposit, Credit: NewMoney(50), Balance: NewMoney(150), Category: "salary", Tags: []string{"monthly", "payroll"}, Actor: "hr", Reason: "March, net"},
+		{Time: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), Action: Withdraw, Debit: NewMoney(20.5), Balance: NewMoney(129.5)},
+		{Time: time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC), Action: Fee, Debit: NewMoney(5), Balance: NewMoney(124.5)},
+	},
+}
+
+func TestExportCSV(t *testing.T) {
+	var out strings.Builder
+	if err := exportStatement.ExportCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `time,action,debit,credit,balance,category,tags,actor,reason
+,opening_balance,,,100.00,,,,
+2026-03-01T08:00:00Z,deposit,0.00,50.00,150.00,salary,monthly;payroll,hr,"March, net"
+2026-03-02T09:00:00Z,withdraw,20.50,0.00,129.50,,,,
+2026-03-03T12:00:00Z,fee,5.00,0.00,124.50,,,,
+,closing_balance,,,124.50,,,,
+`
+	if out.String() != want {
+		t.Fatalf("ExportCSV wrote\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	var out strings.Builder
+	period := exportStatement.Between(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC))
+	if err := period.ExportJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "account": "acct",
+  "holder": {
+    "name": "Acme Ltd",
+    "type": "business",
+    "opened": "2024-03-01"
+  },
+  "opening_balance": 150.00,
+  "transactions": [
+    {
+      "time": "2026-03-02T09:00:00Z",
+      "action": "withdraw",
+      "debit": 20.50,
+      "credit": 0.00,
+      "balance": 129.50
+    }
+  ],
+  "closing_balance": 129.50
+}
+`
+	if out.String() != want {
+		t.Fatalf("ExportJSON wrote\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestStatementBetween(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name             string
+		from, to         time.Time
+		lines            int
+		opening, closing Money
+	}{
+		{"open at both ends", time.Time{}, time.Time{}, 3, NewMoney(100), NewMoney(124.5)},
+		{"from a day", day(2), time.Time{}, 2, NewMoney(150), NewMoney(124.5)},
+		{"up to a day", time.Time{}, day(2), 1, NewMoney(100), NewMoney(150)},
+		{"a line's own time is included", exportStatement.Lines[1].Time, day(3), 1, NewMoney(150), NewMoney(129.5)},
+		{"a line's time is excluded as the end", day(2), exportStatement.Lines[2].Time, 1, NewMoney(150), NewMoney(129.5)},
+		{"no lines in the period", day(10), day(11), 0, NewMoney(124.5), NewMoney(124.5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			period := exportStatement.Between(tt.from, tt.to)
+			if len(period.Lines) != tt.lines || period.OpeningBalance != tt.opening || period.ClosingBalance != tt.closing {
+				t.Fatalf("%d lines from %s to %s, want %d from %s to %s", len(period.Lines), period.OpeningBalance, period.ClosingBalance, tt.lines, tt.opening, tt.closing)
+			}
+			if period.AccountID != "acct" || period.Holder != exportStatement.Holder {
+				t.Fatalf("period for %q held by %v, want the statement's account and holder", period.AccountID, period.Holder)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+//go:build faults
+
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// Fault injection is for tests only and is compiled in with -tags faults,
+// so it can't reach a production build.
+
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultInjector is a Validator that fails one chosen deposit or withdrawal,
+// so rollback paths can be exercised deterministically: the nth such
+// operation it sees, counting from 1, fails with its error and every other
+// one passes. It counts every validation, including those made by Validate,
+// DryRun and Undo, and it is safe to share between accounts.
+type FaultInjector struct {
+	mu    sync.Mutex
+	n     int
+	calls int
+	err   error
+}
+
+// InjectFault makes the nth deposit or withdrawal on account fail with err,
+// or with ErrInjectedFault if err is nil. The injector goes first in the
+// account's validation chain, so it sees every operation.
+func InjectFault(account *BankAccount, n int, err error) *FaultInjector {
+	if err == nil {
+		err = ErrInjectedFault
+	}
+	f := &FaultInjector{n: n, err: err}
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	account.validators = append([]Validator{f}, account.chain()...)
+	return f
+}
+
+func (f *FaultInjector) Validate(account *BankAccount, cmd *BankAccountCommand) error {
+	switch cmd.action {
+	case Deposit, Withdraw, WithdrawAll, WithdrawUpTo:
+	default:
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls == f.n {
+		return f.err
+	}
+	return nil
+}
+
+// Calls reports how many deposits and withdrawals the injector has seen.
+func (f *FaultInjector) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownLocale = errors.New("unknown locale")
+
+// Formatter renders amounts for people: with a currency symbol, grouped
+// thousands and the decimal separator of a locale, such as "$1,234.50" or
+// "1.234,50 €". The zero Formatter prints plain digits and the currency code.
+type Formatter struct {
+	Thousands string
+	Decimal   string
+
+	// SymbolAfter puts the symbol after the number, separated by a space.
+	SymbolAfter bool
+
+	// Symbols maps currency codes to symbols. A code without a symbol is
+	// printed as it is.
+	Symbols map[string]string
+}
+
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+}
+
+var locales = map[string]Formatter{
+	"en-US": {Thousands: ",", Decimal: ".", Symbols: currencySymbols},
+	"en-GB": {Thousands: ",", Decimal: ".", Symbols: currencySymbols},
+	"de-DE": {Thousands: ".", Decimal: ",", SymbolAfter: true, Symbols: currencySymbols},
+	"fr-FR": {Thousands: " ", Decimal: ",", SymbolAfter: true, Symbols: currencySymbols},
+}
+
+// defaultFormatter is what FormatAmount uses. It is meant to be set once at
+// start-up, not while amounts are being formatted.
+var defaultFormatter = locales["en-US"]
+
+// NewFormatter returns the Formatter for a locale: "en-US", "en-GB",
+// "de-DE" or "fr-FR".
+func NewFormatter(locale string) (Formatter, error) {
+	f, ok := locales[locale]
+	if !ok {
+		return Formatter{}, fmt.Errorf("%w %q", ErrUnknownLocale, locale)
+	}
+	return f, nil
+}
+
+// SetDefaultFormatter changes how FormatAmount renders amounts. It is not
+// safe to call while other goroutines are formatting.
+func SetDefaultFormatter(f Formatter) {
+	defaultFormatter = f
+}
+
+// FormatAmount renders amount, rounded to the cent, in currency with the
+// default formatter, which follows en-US conventions unless changed with
+// SetDefaultFormatter. An empty currency prints the number alone.
+func FormatAmount(amount float64, currency string) string {
+	return defaultFormatter.Format(NewMoney(amount), currency)
+}
+
+// Format renders m in currency.
+func (f Formatter) Format(m Money, currency string) string {
+	cents := int64(m)
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	decimal := f.Decimal
+	if decimal == "" {
+		decimal = "."
+	}
+	number := fmt.Sprintf("%s%s%02d", groupThousands(cents/100, f.Thousands), decimal, cents%100)
+	symbol, ok := f.Symbols[currency]
+	switch {
+	case currency == "":
+		return sign + number
+	case !ok:
+		if f.SymbolAfter {
+			return sign + number + " " + currency
+		}
+		return sign + currency + " " + number
+	case f.SymbolAfter:
+		return sign + number + " " + symbol
+	}
+	return sign + symbol + number
+}
+
+func groupThousands(units int64, separator string) string {
+	digits := fmt.Sprint(units)
+	if separator == "" || len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	lead := len(digits) % 3
+	if lead > 0 {
+		b.WriteString(digits[:lead])
+	}
+	for i := lead; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(separator)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
@@ -0,0 +1,3 @@
+module github.com/ricardoferrari/go-command
+
+go 1.22
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrUnsupportedCommand = errors.New("command type cannot be serialized")
+
+// gobCommand is the wire form of a command. It keeps the command's ID, so a
+// redelivered command can be recognised. Accounts are stored by ID and
+// composites carry their children, so a whole queue encodes as a flat list
+// of trees.
+type gobCommand struct {
+	Version       int
+	ID            string
+	Kind          gobKind
+	Action        Action
+	Amount        Money
+	Account       string
+	StopOnFailure bool
+	Order         OrderStrategy
+	Fee           Money
+	Rate          float64
+	Credited      Money
+	Category      string
+	Tags          []string
+	Actor         string
+	Reason        string
+	Children      []gobCommand
+}
+
+type gobKind int
+
+const (
+	gobBankAccount gobKind = iota
+	gobComposite
+	gobTransfer
+)
+
+func (c *BankAccountCommand) GobEncode() ([]byte, error) {
+	return encodeGob(c)
+}
+
+// GobDecode restores an un-executed command whose account stays unresolved
+// until it is re-linked, for example by LoadQueue.
+func (c *BankAccountCommand) GobDecode(data []byte) error {
+	decoded, err := decodeGob(data, gobBankAccount)
+	if err != nil {
+		return err
+	}
+	*c = *decoded.(*BankAccountCommand)
+	return nil
+}
+
+func (c *CompositeBankAccountCommand) GobEncode() ([]byte, error) {
+	return encodeGob(c)
+}
+
+func (c *CompositeBankAccountCommand) GobDecode(data []byte) error {
+	decoded, err := decodeGob(data, gobComposite)
+	if err != nil {
+		return err
+	}
+	*c = *decoded.(*CompositeBankAccountCommand)
+	return nil
+}
+
+func (c *MoneyTransferCommand) GobEncode() ([]byte, error) {
+	return encodeGob(c)
+}
+
+func (c *MoneyTransferCommand) GobDecode(data []byte) error {
+	decoded, err := decodeGob(data, gobTransfer)
+	if err != nil {
+		return err
+	}
+	*c = *decoded.(*MoneyTransferCommand)
+	return nil
+}
+
+// SaveQueue writes cmds to w in gob format. Bank account commands,
+// composites and transfers can be saved, nested to any depth.
+func SaveQueue(w io.Writer, cmds []Command) error {
+	queue := make([]gobCommand, len(cmds))
+	for i, cmd := range cmds {
+		wire, err := toGob(cmd)
+		if err != nil {
+			return fmt.Errorf("save command %d: %w", i, err)
+		}
+		queue[i] = wire
+	}
+	return gob.NewEncoder(w).Encode(queue)
+}
+
+// LoadQueue reads commands written by SaveQueue and re-links them to the
+// live accounts in reg. The commands come back un-executed.
+func LoadQueue(r io.Reader, reg *Registry) ([]Command, error) {
+	var queue []gobCommand
+	if err := gob.NewDecoder(r).Decode(&queue); err != nil {
+		return nil, err
+	}
+	cmds := make([]Command, len(queue))
+	for i, wire := range queue {
+		if err := checkGobVersion(wire); err != nil {
+			return nil, fmt.Errorf("load command %d: %w", i, err)
+		}
+		if err := checkGobLimits(wire); err != nil {
+			return nil, fmt.Errorf("load command %d: %w", i, err)
+		}
+		cmd, err := fromGob(wire)
+		if err != nil {
+			return nil, fmt.Errorf("load command %d: %w", i, err)
+		}
+		if err := resolveAccounts(cmd, reg.Get); err != nil {
+			return nil, fmt.Errorf("load command %d: %w", i, err)
+		}
+		cmds[i] = cmd
+	}
+	return cmds, nil
+}
+
+func encodeGob(cmd Command) ([]byte, error) {
+	wire, err := toGob(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(wire); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeGob(data []byte, want gobKind) (Command, error) {
+	var wire gobCommand
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&wire); err != nil {
+		return nil, err
+	}
+	if err := checkGobVersion(wire); err != nil {
+		return nil, err
+	}
+	if wire.Kind != want {
+		return nil, fmt.Errorf("%w: wire kind %d, want %d", ErrUnsupportedCommand, wire.Kind, want)
+	}
+	if err := checkGobLimits(wire); err != nil {
+		return nil, err
+	}
+	return fromGob(wire)
+}
+
+func toGob(cmd Command) (gobCommand, error) {
+	switch c := cmd.(type) {
+	case *BankAccountCommand:
+		accountID := c.accountID
+		if c.account != nil {
+			accountID = c.account.ID
+		}
+		return gobCommand{Version: CommandSchemaVersion, ID: c.CommandID(), Kind: gobBankAccount, Action: c.action, Amount: c.amount, Account: accountID, Category: c.category, Tags: c.tags, Actor: c.actor, Reason: c.reason}, nil
+	case *MoneyTransferCommand:
+		children, err := childrenToGob(c.commands)
+		if err != nil {
+			return gobCommand{}, err
+		}
+		return gobCommand{Version: CommandSchemaVersion, ID: c.CommandID(), Kind: gobTransfer, Amount: c.amount, Fee: c.fee, Rate: c.rate, Credited: c.credited, Children: children}, nil
+	case *CompositeBankAccountCommand:
+		children, err := childrenToGob(c.commands)
+		if err != nil {
+			return gobCommand{}, err
+		}
+		return gobCommand{Version: CommandSchemaVersion, ID: c.CommandID(), Kind: gobComposite, StopOnFailure: c.stopOnFailure, Order: c.order, Children: children}, nil
+	}
+	return gobCommand{}, fmt.Errorf("%w: %T", ErrUnsupportedCommand, cmd)
+}
+
+func childrenToGob(cmds []Command) ([]gobCommand, error) {
+	children := make([]gobCommand, len(cmds))
+	for i, cmd := range cmds {
+		child, err := toGob(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("child %d: %w", i, err)
+		}
+		children[i] = child
+	}
+	return children, nil
+}
+
+// fromGob rebuilds the command wire describes. A transfer's legs are
+// checked against its amounts, since the rest of the package relies on a
+// transfer being exactly a withdrawal, a deposit and an optional fee.
+func fromGob(wire gobCommand) (Command, error) {
+	children := make([]Command, len(wire.Children))
+	for i, child := range wire.Children {
+		cmd, err := fromGob(child)
+		if err != nil {
+			return nil, fmt.Errorf("child %d: %w", i, err)
+		}
+		children[i] = cmd
+	}
+	switch wire.Kind {
+	case gobTransfer:
+		if err := checkGobTransfer(wire); err != nil {
+			return nil, err
+		}
+		return &MoneyTransferCommand{
+			CompositeBankAccountCommand: CompositeBankAccountCommand{id: wire.ID, commands: children},
+			amount:                      wire.Amount,
+			fee:                         wire.Fee,
+			rate:                        wire.Rate,
+			credited:                    wire.Credited,
+		}, nil
+	case gobComposite:
+		return &CompositeBankAccountCommand{id: wire.ID, commands: children, stopOnFailure: wire.StopOnFailure, order: wire.Order}, nil
+	case gobBankAccount:
+		if len(wire.Children) > 0 {
+			return nil, fmt.Errorf("%w: bank account command with %d children", ErrMalformedCommand, len(wire.Children))
+		}
+		return &BankAccountCommand{id: wire.ID, action: wire.Action, amount: wire.Amount, accountID: wire.Account, category: wire.Category, tags: wire.Tags, actor: wire.Actor, reason: wire.Reason}, nil
+	}
+	return nil, fmt.Errorf("%w: wire kind %d", ErrUnsupportedCommand, wire.Kind)
+}
+
+// checkGobTransfer reports whether wire has the legs buildLegs would give
+// a transfer of its amounts: a withdrawal of Amount, a deposit of Credited
+// to another account and, when Fee is set, a withdrawal of Fee from the
+// same account as the first.
+func checkGobTransfer(wire gobCommand) error {
+	legs := wire.Children
+	want := 2
+	if wire.Fee != 0 {
+		want = 3
+	}
+	if len(legs) != want {
+		return fmt.Errorf("%w: transfer with %d legs, want %d", ErrMalformedCommand, len(legs), want)
+	}
+	for i, leg := range legs {
+		if leg.Kind != gobBankAccount {
+			return fmt.Errorf("%w: transfer leg %d is not a bank account command", ErrMalformedCommand, i)
+		}
+	}
+	switch {
+	case legs[0].Action != Withdraw || legs[0].Amount != wire.Amount:
+		return fmt.Errorf("%w: transfer of %s doesn't start with its withdrawal", ErrMalformedCommand, wire.Amount)
+	case legs[1].Action != Deposit || legs[1].Amount != wire.Credited:
+		return fmt.Errorf("%w: transfer crediting %s doesn't deposit it", ErrMalformedCommand, wire.Credited)
+	case legs[0].Account == legs[1].Account:
+		return fmt.Errorf("%w: %w", ErrMalformedCommand, ErrSelfTransfer)
+	case want == 3 && (legs[2].Action != Withdraw || legs[2].Amount != wire.Fee || legs[2].Account != legs[0].Account):
+		return fmt.Errorf("%w: transfer fee of %s isn't charged to the source", ErrMalformedCommand, wire.Fee)
+	}
+	return nil
+}
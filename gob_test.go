@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"testing"
+)
+
+func queueRegistry(t testing.TB) *Registry {
+	t.Helper()
+	reg := NewRegistry()
+	for _, id := range []string{"a", "b"} {
+		account := NewBankAccount(100, 0)
+		account.ID = id
+		if err := reg.Add(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return reg
+}
+
+func encodeWire(t testing.TB, queue ...gobCommand) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(queue); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func wireTransfer(amount, fee Money, legs ...gobCommand) gobCommand {
+	return gobCommand{Version: CommandSchemaVersion, Kind: gobTransfer, Amount: amount, Fee: fee, Rate: 1, Credited: amount, Children: legs}
+}
+
+func wireLeg(action Action, amount Money, account string) gobCommand {
+	return gobCommand{Version: CommandSchemaVersion, Kind: gobBankAccount, Action: action, Amount: amount, Account: account}
+}
+
+func TestSaveLoadQueue(t *testing.T) {
+	reg := queueRegistry(t)
+	a, _ := reg.Get("a")
+	b, _ := reg.Get("b")
+	transfer, _ := NewMoneyTransferCommand(a, b, 30, WithFee(1))
+	var buf bytes.Buffer
+	if err := SaveQueue(&buf, []Command{NewBankAccountCommand(a, Deposit, 5), transfer}); err != nil {
+		t.Fatal(err)
+	}
+	cmds, err := LoadQueue(&buf, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range cmds {
+		cmd.Call()
+		if !cmd.Succeeded() {
+			t.Fatalf("%s: %v", cmd.Describe(), cmd.Err())
+		}
+	}
+	if a.Balance() != 74 || b.Balance() != 130 {
+		t.Fatalf("balances %v, %v; want 74, 130", a.Balance(), b.Balance())
+	}
+	if cmds[1].CommandID() != transfer.CommandID() {
+		t.Fatalf("loaded ID %s, want %s", cmds[1].CommandID(), transfer.CommandID())
+	}
+}
+
+func TestLoadQueueRejectsMalformedTransfers(t *testing.T) {
+	tests := []struct {
+		name string
+		wire gobCommand
+	}{
+		{"no legs", wireTransfer(10, 0)},
+		{"one leg", wireTransfer(10, 0, wireLeg(Withdraw, 10, "a"))},
+		{"composite leg", wireTransfer(10, 0,
+			wireLeg(Withdraw, 10, "a"),
+			gobCommand{Version: CommandSchemaVersion, Kind: gobComposite, Children: []gobCommand{wireLeg(Deposit, 10, "b")}})},
+		{"legs swapped", wireTransfer(10, 0, wireLeg(Deposit, 10, "b"), wireLeg(Withdraw, 10, "a"))},
+		{"withdrawal doesn't match the amount", wireTransfer(10, 0, wireLeg(Withdraw, 1, "a"), wireLeg(Deposit, 10, "b"))},
+		{"deposit doesn't match the credit", wireTransfer(10, 0, wireLeg(Withdraw, 10, "a"), wireLeg(Deposit, 1000, "b"))},
+		{"same account", wireTransfer(10, 0, wireLeg(Withdraw, 10, "a"), wireLeg(Deposit, 10, "a"))},
+		{"fee without its leg", wireTransfer(10, 1, wireLeg(Withdraw, 10, "a"), wireLeg(Deposit, 10, "b"))},
+		{"fee leg without a fee", wireTransfer(10, 0, wireLeg(Withdraw, 10, "a"), wireLeg(Deposit, 10, "b"), wireLeg(Withdraw, 1, "a"))},
+		{"fee charged to the payee", wireTransfer(10, 1, wireLeg(Withdraw, 10, "a"), wireLeg(Deposit, 10, "b"), wireLeg(Withdraw, 1, "b"))},
+		{"bank account command with children", gobCommand{Version: CommandSchemaVersion, Kind: gobBankAccount, Action: Deposit, Amount: 1, Account: "a", Children: []gobCommand{wireLeg(Deposit, 1, "a")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmds, err := LoadQueue(bytes.NewReader(encodeWire(t, tt.wire)), queueRegistry(t))
+			if !errors.Is(err, ErrMalformedCommand) {
+				t.Fatalf("LoadQueue = %v, %v; want ErrMalformedCommand", cmds, err)
+			}
+		})
+	}
+}
+
+func TestLoadQueueRejectsUnknownKind(t *testing.T) {
+	_, err := LoadQueue(bytes.NewReader(encodeWire(t, gobCommand{Version: CommandSchemaVersion, Kind: 42})), queueRegistry(t))
+	if !errors.Is(err, ErrUnsupportedCommand) {
+		t.Fatalf("LoadQueue = %v, want ErrUnsupportedCommand", err)
+	}
+}
+
+func TestTransferGobDecodeRejectsMalformed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(wireTransfer(10, 0)); err != nil {
+		t.Fatal(err)
+	}
+	var transfer MoneyTransferCommand
+	if err := transfer.GobDecode(buf.Bytes()); !errors.Is(err, ErrMalformedCommand) {
+		t.Fatalf("GobDecode = %v, want ErrMalformedCommand", err)
+	}
+}
+
+// FuzzLoadQueue checks that no payload makes LoadQueue, or the commands it
+// returns, panic.
+func FuzzLoadQueue(f *testing.F) {
+	reg := queueRegistry(f)
+	a, _ := reg.Get("a")
+	b, _ := reg.Get("b")
+	transfer, _ := NewMoneyTransferCommand(a, b, 10, WithFee(1))
+	var valid bytes.Buffer
+	if err := SaveQueue(&valid, []Command{NewBankAccountCommand(a, Deposit, 5), transfer, NewCompositeCommand(true, NewBankAccountCommand(b, Withdraw, 2))}); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(valid.Bytes())
+	f.Add(encodeWire(f, wireTransfer(10, 0)))
+	f.Add(encodeWire(f, wireTransfer(10, 0, wireLeg(Withdraw, 10, "a"),
+		gobCommand{Version: CommandSchemaVersion, Kind: gobComposite, Children: []gobCommand{wireLeg(Deposit, 10, "b")}})))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cmds, err := LoadQueue(bytes.NewReader(data), queueRegistry(t))
+		if err != nil {
+			return
+		}
+		for _, cmd := range cmds {
+			cmd.Describe()
+			cmd.Call()
+			cmd.Undo()
+			cmd.Reverse()
+		}
+	})
+}
+
+// A transfer decoded on its own has no accounts until something re-links
+// it, and neither it nor a zero value panics when used.
+func TestUnlinkedTransferFails(t *testing.T) {
+	wire := wireTransfer(30, 0, wireLeg(Withdraw, 30, "a"), wireLeg(Deposit, 30, "b"))
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(wire); err != nil {
+		t.Fatal(err)
+	}
+	var decoded MoneyTransferCommand
+	if err := decoded.GobDecode(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cmd  *MoneyTransferCommand
+	}{
+		{"decoded", &decoded},
+		{"zero value", &MoneyTransferCommand{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cmd.Describe(); got == "" {
+				t.Fatal("Describe returned nothing")
+			}
+			if err := tt.cmd.Validate(); !errors.Is(err, ErrNilAccount) {
+				t.Fatalf("Validate = %v, want ErrNilAccount", err)
+			}
+			tt.cmd.Call()
+			if tt.cmd.Succeeded() || !errors.Is(tt.cmd.Err(), ErrNilAccount) {
+				t.Fatalf("succeeded %v, err %v; want ErrNilAccount", tt.cmd.Succeeded(), tt.cmd.Err())
+			}
+			if err := tt.cmd.Undo(); err != nil {
+				t.Fatalf("Undo = %v, want nil with nothing applied", err)
+			}
+			if err := tt.cmd.Prepare(); !errors.Is(err, ErrNilAccount) {
+				t.Fatalf("Prepare = %v, want ErrNilAccount", err)
+			}
+			tt.cmd.Commit()
+			tt.cmd.Rollback()
+			if tt.cmd.AppliedAmount() != 0 {
+				t.Fatalf("AppliedAmount = %v, want 0", tt.cmd.AppliedAmount())
+			}
+		})
+	}
+}
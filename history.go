@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// BalanceChange is one entry in an account's balance history: the balance
+// moved by Delta at Time, leaving Balance.
+type BalanceChange struct {
+	Time    time.Time
+	Delta   Money
+	Balance Money
+}
+
+// adjust moves the balance by delta and appends the change to the history.
+// Every change to the balance goes through it, so undoing a command shows
+// up as a compensating entry rather than removing the original one. The
+// caller must hold account.mu.
+func (account *BankAccount) adjust(delta Money) {
+	account.balance += delta
+	account.version++
+	account.history = append(account.history, BalanceChange{Time: account.now(), Delta: delta, Balance: account.balance})
+	account.checkThresholds()
+}
+
+// History returns a copy of every balance change, oldest first.
+func (account *BankAccount) History() []BalanceChange {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return append([]BalanceChange(nil), account.history...)
+}
+
+// BalanceAt returns the balance as it stood at t, after any change made at
+// exactly t. Before the first recorded change it is the opening balance the
+// account was created with.
+func (account *BankAccount) BalanceAt(t time.Time) float64 {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	if len(account.history) == 0 {
+		return account.balance.Float64()
+	}
+	i := sort.Search(len(account.history), func(i int) bool {
+		return account.history[i].Time.After(t)
+	})
+	if i == 0 {
+		first := account.history[0]
+		return (first.Balance - first.Delta).Float64()
+	}
+	return account.history[i-1].Balance.Float64()
+}
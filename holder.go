@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// HolderType tells individual customers from businesses, for rules that
+// treat them differently.
+type HolderType int
+
+const (
+	Individual HolderType = iota
+	Business
+)
+
+func (t HolderType) String() string {
+	if t == Business {
+		return "business"
+	}
+	return "individual"
+}
+
+// Holder describes who owns an account.
+type Holder struct {
+	Name   string
+	Type   HolderType
+	Opened time.Time
+}
+
+// String gives the holder as it appears on statements, for example
+// "Acme Ltd (business, opened 2024-03-01)".
+func (h Holder) String() string {
+	if h.Opened.IsZero() {
+		return fmt.Sprintf("%s (%s)", h.Name, h.Type)
+	}
+	return fmt.Sprintf("%s (%s, opened %s)", h.Name, h.Type, h.Opened.Format("2006-01-02"))
+}
+
+// NewBankAccountWithHolder is NewBankAccount for an account whose holder is
+// known. A holder opened at the zero time is taken to open the account now.
+func NewBankAccountWithHolder(holder Holder, balance, overdraftLimit float64) *BankAccount {
+	if holder.Opened.IsZero() {
+		holder.Opened = now()
+	}
+	account := NewBankAccount(balance, overdraftLimit)
+	account.Holder = &holder
+	return account
+}
+
+// holderTransactionCaps are the caps set with SetHolderTransactionCap.
+var holderTransactionCaps = map[HolderType]Money{}
+
+// SetHolderTransactionCap caps single deposits and withdrawals on every
+// account whose holder is of type t, so business accounts can be allowed
+// larger payments than personal ones. An account's own
+// SetMaxTransactionAmount takes precedence, and accounts without a holder
+// are not affected. A cap of zero removes it. Set it at start-up.
+func SetHolderTransactionCap(t HolderType, limit float64) {
+	holderTransactionCaps[t] = NewMoney(limit)
+}
+
+// transactionCap returns the cap that applies to a single transaction on
+// the account, zero for none. The caller must hold account.mu.
+func (account *BankAccount) transactionCap() Money {
+	if account.maxTransactionAmount > 0 || account.Holder == nil {
+		return account.maxTransactionAmount
+	}
+	return holderTransactionCaps[account.Holder.Type]
+}
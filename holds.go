@@ -0,0 +1,98 @@
+package main
+
+import "errors"
+
+var ErrInsufficientHold = errors.New("amount exceeds funds on hold")
+
+// AvailableBalance is the balance minus the funds reserved by holds. It is
+// what withdrawals and new authorizations are checked against.
+func (account *BankAccount) AvailableBalance() float64 {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return (account.balance - account.held).Float64()
+}
+
+// Held returns the total of all outstanding holds.
+func (account *BankAccount) Held() float64 {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return account.held.Float64()
+}
+
+// Authorize places a hold of amount, reducing the available balance without
+// moving any money. Capture settles a hold and Release cancels it.
+func (account *BankAccount) Authorize(amount float64) error {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return account.authorize(NewMoney(amount))
+}
+
+func (account *BankAccount) Capture(amount float64) error {
+	account.mu.Lock()
+	defer account.unlock()
+	return account.capture(NewMoney(amount))
+}
+
+func (account *BankAccount) Release(amount float64) error {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return account.release(NewMoney(amount))
+}
+
+// authorize, capture, release and uncapture expect the caller to hold
+// account.mu.
+func (account *BankAccount) authorize(amount Money) error {
+	return account.authorizeFor(account.request(Authorize, amount))
+}
+
+func (account *BankAccount) authorizeFor(cmd *BankAccountCommand) error {
+	if err := account.usable(); err != nil {
+		return err
+	}
+	if err := account.validate(cmd); err != nil {
+		return err
+	}
+	account.held += cmd.amount
+	account.version++
+	return nil
+}
+
+// capture takes amount out of the balance and off hold at once, so the
+// available balance is unchanged.
+func (account *BankAccount) capture(amount Money) error {
+	if amount < 0 {
+		return ErrNegativeAmount
+	}
+	if err := account.usable(); err != nil {
+		return err
+	}
+	if amount > account.held {
+		return ErrInsufficientHold
+	}
+	account.adjust(-amount)
+	account.held -= amount
+	return nil
+}
+
+// release only frees reserved funds, so it is allowed on frozen accounts.
+func (account *BankAccount) release(amount Money) error {
+	if amount < 0 {
+		return ErrNegativeAmount
+	}
+	if amount > account.held {
+		return ErrInsufficientHold
+	}
+	account.held -= amount
+	account.version++
+	return nil
+}
+
+// uncapture puts a captured amount back into the balance and on hold.
+func (account *BankAccount) uncapture(amount Money) error {
+	if err := account.usable(); err != nil {
+		return err
+	}
+	account.adjust(amount)
+	account.held += amount
+	return nil
+}
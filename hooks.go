@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrVetoed = errors.New("vetoed by hook")
+
+// BeforeHook runs before a command touching the account and vetoes it by
+// returning an error. AfterHook runs once the command has been called,
+// whether or not it succeeded.
+type (
+	BeforeHook func(cmd Command) error
+	AfterHook  func(cmd Command)
+)
+
+// runBeforeHooks runs the BeforeExecute hooks of each distinct account in
+// turn and returns the first veto along with the account that raised it.
+// The hooks run without any account lock held, so they may read balances.
+func runBeforeHooks(cmd Command, accounts ...*BankAccount) (*BankAccount, error) {
+	for i, account := range accounts {
+		if account == nil || seenBefore(accounts[:i], account) {
+			continue
+		}
+		for _, hook := range account.BeforeExecute {
+			if err := hook(cmd); err != nil {
+				return account, fmt.Errorf("%w on %s: %w", ErrVetoed, accountLabel(account), err)
+			}
+		}
+	}
+	return nil, nil
+}
+
+func runAfterHooks(cmd Command, accounts ...*BankAccount) {
+	for i, account := range accounts {
+		if account == nil || seenBefore(accounts[:i], account) {
+			continue
+		}
+		for _, hook := range account.AfterExecute {
+			hook(cmd)
+		}
+	}
+}
+
+func seenBefore(accounts []*BankAccount, account *BankAccount) bool {
+	for _, earlier := range accounts {
+		if earlier == account {
+			return true
+		}
+	}
+	return false
+}
+
+// veto records err as the reason c didn't run and reports whether it did.
+// A command that has already been applied keeps its outcome.
+func (c *BankAccountCommand) veto(err error) bool {
+	unlock := lockAccounts(c.account)
+	defer unlock()
+	if c.executed {
+		return false
+	}
+	c.err = err
+	c.succeeded = false
+	return true
+}
+
+// veto records err on the first leg touching account and clears the others,
+// so Err reports the veto. A transfer with any leg applied is left alone
+// and veto returns false.
+func (c *MoneyTransferCommand) veto(account *BankAccount, err error) bool {
+	unlock := lockAccounts(c.from, c.to)
+	defer unlock()
+	for _, cmd := range c.commands {
+		if cmd.(*BankAccountCommand).executed {
+			return false
+		}
+	}
+	recorded := false
+	for _, cmd := range c.commands {
+		leg := cmd.(*BankAccountCommand)
+		leg.err = nil
+		leg.succeeded = false
+		if !recorded && leg.account == account {
+			leg.err = err
+			recorded = true
+		}
+	}
+	return true
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// maxRequestBody caps the size of a request body the HTTP API will read.
+const maxRequestBody = 1 << 20
+
+// HTTPAPI drives the accounts in a Registry over HTTP:
+//
+//	POST /accounts               {"id": "acct-1", "balance": 100, "overdraft_limit": -500}
+//	POST /accounts/{id}/deposit  {"amount": 25.50}
+//	POST /accounts/{id}/withdraw {"amount": 25.50}
+//	POST /transfers              {"from": "acct-1", "to": "acct-2", "amount": 10}
+//
+// Each request builds the matching command and calls it. Successful calls
+// answer with the outcome and the new balances; failures answer with a
+// status code chosen from the error and a body such as
+// {"error": {"code": "overdraft_exceeded", "message": "..."}}. A transfer
+// sent with an Idempotency-Key header runs once however often it is retried.
+//
+// HTTPAPI is a transport layer over the core, not part of it. It reaches
+// accounts and commands only through their exported API (Registry,
+// BuildCommand, the commands' accessors and the error sentinels), and no
+// other file refers to it, so it can move to its own package unchanged once
+// the library is split out of package main.
+type HTTPAPI struct {
+	registry *Registry
+	mux      *http.ServeMux
+}
+
+func NewHTTPAPI(registry *Registry) *HTTPAPI {
+	api := &HTTPAPI{registry: registry, mux: http.NewServeMux()}
+	api.mux.HandleFunc("POST /accounts", api.createAccount)
+	api.mux.HandleFunc("POST /accounts/{id}/deposit", api.accountCommand(Deposit))
+	api.mux.HandleFunc("POST /accounts/{id}/withdraw", api.accountCommand(Withdraw))
+	api.mux.HandleFunc("POST /transfers", api.transfer)
+	return api
+}
+
+func (api *HTTPAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mux.ServeHTTP(w, r)
+}
+
+type createAccountRequest struct {
+	ID             string `json:"id"`
+	Currency       string `json:"currency"`
+	Balance        Money  `json:"balance"`
+	OverdraftLimit Money  `json:"overdraft_limit"`
+}
+
+type amountRequest struct {
+	Amount Money `json:"amount"`
+}
+
+type transferRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount Money  `json:"amount"`
+}
+
+type accountResponse struct {
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Balance Money  `json:"balance"`
+}
+
+type transferResponse struct {
+	Success     bool  `json:"success"`
+	FromBalance Money `json:"from_balance"`
+	ToBalance   Money `json:"to_balance"`
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (api *HTTPAPI) createAccount(w http.ResponseWriter, r *http.Request) {
+	var req createAccountRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	account := NewBankAccount(req.Balance.Float64(), req.OverdraftLimit.Float64())
+	account.ID = req.ID
+	account.Currency = req.Currency
+	if err := api.registry.Add(account); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, accountResponse{ID: account.ID, Success: true, Balance: account.BalanceMoney()})
+}
+
+func (api *HTTPAPI) accountCommand(action Action) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if _, ok := api.registry.Get(id); !ok {
+			writeError(w, fmt.Errorf("%w %q", ErrUnknownAccount, id))
+			return
+		}
+		var req amountRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		built, err := BuildCommand(CommandSpec{Action: action.String(), Amount: req.Amount, From: id}, api.registry)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		cmd := built.(*BankAccountCommand)
+		cmd.Call()
+		if err := cmd.Err(); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, accountResponse{ID: id, Success: true, Balance: cmd.BalanceAfter()})
+	}
+}
+
+func (api *HTTPAPI) transfer(w http.ResponseWriter, r *http.Request) {
+	var req transferRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	if key != "" {
+		unlock := api.registry.LockKey(key)
+		defer unlock()
+	}
+	spec := CommandSpec{Action: TransferAction, Amount: req.Amount, From: req.From, To: req.To, IdempotencyKey: key}
+	built, err := BuildCommand(spec, api.registry)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	cmd := built.(*MoneyTransferCommand)
+	// A retried key brings back the command from the first request; only
+	// one that has never run is called. Holding the key keeps a concurrent
+	// retry from reading the command while this request calls it.
+	if !cmd.Succeeded() && cmd.Err() == nil {
+		cmd.Call()
+	}
+	if err := cmd.Err(); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transferResponse{
+		Success:     true,
+		FromBalance: cmd.From().BalanceMoney(),
+		ToBalance:   cmd.To().BalanceMoney(),
+	})
+}
+
+// decodeRequest reads a JSON body into v, answering 400 and returning false
+// if it can't.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{errorBody{Code: "invalid_request", Message: err.Error()}})
+		return false
+	}
+	return true
+}
+
+// errorStatuses maps the codes ErrorCode gives to a status code. Codes not
+// listed, including internal_error, answer with a 500.
+var errorStatuses = map[string]int{
+	"unknown_account":       http.StatusNotFound,
+	"duplicate_account":     http.StatusConflict,
+	"missing_account_id":    http.StatusBadRequest,
+	"negative_amount":       http.StatusBadRequest,
+	"invalid_amount":        http.StatusBadRequest,
+	"self_transfer":         http.StatusBadRequest,
+	"zero_amount":           http.StatusBadRequest,
+	"account_frozen":        http.StatusForbidden,
+	"account_closed":        http.StatusForbidden,
+	"vetoed":                http.StatusForbidden,
+	"overdraft_exceeded":    http.StatusUnprocessableEntity,
+	"below_minimum_balance": http.StatusUnprocessableEntity,
+	"daily_limit_exceeded":  http.StatusUnprocessableEntity,
+	"amount_too_large":      http.StatusUnprocessableEntity,
+	"no_exchange_rate":      http.StatusUnprocessableEntity,
+	"unknown_action":        http.StatusBadRequest,
+	"invalid_request":       http.StatusBadRequest,
+	"idempotency_conflict":  http.StatusConflict,
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status, code := errorStatus(err)
+	writeJSON(w, status, errorResponse{errorBody{Code: code, Message: err.Error()}})
+}
+
+// errorStatus returns err's code and the status it answers with.
+func errorStatus(err error) (status int, code string) {
+	code = ErrorCode(err)
+	if status, ok := errorStatuses[code]; ok {
+		return status, code
+	}
+	return http.StatusInternalServerError, code
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func post(t *testing.T, api *HTTPAPI, path, body, key string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHTTPTransfer(t *testing.T) {
+	reg := queueRegistry(t)
+	rec := post(t, NewHTTPAPI(reg), "/transfers", `{"from":"a","to":"b","amount":10}`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp transferResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success || resp.FromBalance != NewMoney(90) || resp.ToBalance != NewMoney(110) {
+		t.Fatalf("response %+v, want success with balances 90, 110", resp)
+	}
+}
+
+func TestHTTPErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"overdraft", "/transfers", `{"from":"a","to":"b","amount":1000}`, http.StatusUnprocessableEntity, "overdraft_exceeded"},
+		{"unknown account", "/accounts/nobody/deposit", `{"amount":1}`, http.StatusNotFound, "unknown_account"},
+		{"self transfer", "/transfers", `{"from":"a","to":"a","amount":1}`, http.StatusBadRequest, "self_transfer"},
+		{"unknown field", "/transfers", `{"from":"a","to":"b","amount":1,"note":"x"}`, http.StatusBadRequest, "invalid_request"},
+		{"duplicate account", "/accounts", `{"id":"a"}`, http.StatusConflict, "duplicate_account"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := post(t, NewHTTPAPI(queueRegistry(t)), tt.path, tt.body, "")
+			var resp errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.status || resp.Error.Code != tt.code {
+				t.Fatalf("status %d, code %q; want %d, %q", rec.Code, resp.Error.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestHTTPIdempotencyKey(t *testing.T) {
+	reg := queueRegistry(t)
+	api := NewHTTPAPI(reg)
+	for i := 0; i < 3; i++ {
+		if rec := post(t, api, "/transfers", `{"from":"a","to":"b","amount":10}`, "k1"); rec.Code != http.StatusOK {
+			t.Fatalf("attempt %d: status %d: %s", i, rec.Code, rec.Body)
+		}
+	}
+	a, _ := reg.Get("a")
+	if a.Balance() != 90 {
+		t.Fatalf("balance %v, want 90 after one transfer", a.Balance())
+	}
+	if rec := post(t, api, "/transfers", `{"from":"a","to":"b","amount":11}`, "k1"); rec.Code != http.StatusConflict {
+		t.Fatalf("reused key for another amount: status %d, want 409", rec.Code)
+	}
+}
+
+// A failed first attempt is answered again on retry, rather than run again
+// now that the source could cover it.
+func TestHTTPIdempotencyKeyRepeatsFailure(t *testing.T) {
+	reg := queueRegistry(t)
+	api := NewHTTPAPI(reg)
+	if rec := post(t, api, "/transfers", `{"from":"a","to":"b","amount":150}`, "k1"); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, want 422", rec.Code)
+	}
+	a, _ := reg.Get("a")
+	a.Deposit(100)
+	if rec := post(t, api, "/transfers", `{"from":"a","to":"b","amount":150}`, "k1"); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("retry: status %d, want the original 422", rec.Code)
+	}
+	if a.Balance() != 200 {
+		t.Fatalf("balance %v, want 200", a.Balance())
+	}
+}
+
+// Duplicate requests racing under one key apply the transfer exactly once
+// and all report it. Run with -race.
+func TestHTTPConcurrentIdempotentTransfers(t *testing.T) {
+	reg := queueRegistry(t)
+	api := NewHTTPAPI(reg)
+	const requests = 50
+	codes := make([]int, requests)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = post(t, api, "/transfers", `{"from":"a","to":"b","amount":10}`, "same-key").Code
+		}(i)
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d: status %d, want 200", i, code)
+		}
+	}
+	a, _ := reg.Get("a")
+	b, _ := reg.Get("b")
+	if a.Balance() != 90 || b.Balance() != 110 {
+		t.Fatalf("balances %v, %v; want 90, 110 from a single transfer", a.Balance(), b.Balance())
+	}
+	if len(reg.keyLocks) != 0 {
+		t.Fatalf("%d key locks left behind", len(reg.keyLocks))
+	}
+}
+
+// Every code the core can report has a status of its own rather than
+// falling through to a 500.
+func TestHTTPStatusForEveryErrorCode(t *testing.T) {
+	for _, e := range errorCodes {
+		if _, ok := errorStatuses[e.code]; !ok {
+			t.Errorf("code %q has no HTTP status", e.code)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrIdempotencyConflict = errors.New("idempotency key reused for a different transfer")
+
+// idempotencyCapacity bounds how many keys a Registry remembers. Past it the
+// oldest key is forgotten, so a retry has to arrive before that many newer
+// keyed transfers.
+const idempotencyCapacity = 10_000
+
+// keyedTransfer is what a Registry remembers about an idempotency key: the
+// request it was first used for and the command built for it.
+type keyedTransfer struct {
+	fromID string
+	toID   string
+	amount Money
+	cmd    *MoneyTransferCommand
+}
+
+// keyedTransfer returns the command remembered for key, if any.
+func (r *Registry) keyedTransfer(key string, request keyedTransfer) (*MoneyTransferCommand, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keyedTransferLocked(key, request)
+}
+
+// rememberTransfer records request under key and returns its command, or the
+// command of a racing call that recorded key first.
+func (r *Registry) rememberTransfer(key string, request keyedTransfer) (*MoneyTransferCommand, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cmd, ok, err := r.keyedTransferLocked(key, request); ok {
+		return cmd, err
+	}
+	if r.keys == nil {
+		r.keys = make(map[string]keyedTransfer)
+	}
+	if len(r.keyOrder) >= idempotencyCapacity {
+		delete(r.keys, r.keyOrder[0])
+		r.keyOrder = r.keyOrder[1:]
+	}
+	r.keys[key] = request
+	r.keyOrder = append(r.keyOrder, key)
+	return request.cmd, nil
+}
+
+// keyedTransferLocked fails with ErrIdempotencyConflict if key was first
+// used for a different request. The caller must hold r.mu.
+func (r *Registry) keyedTransferLocked(key string, request keyedTransfer) (*MoneyTransferCommand, bool, error) {
+	known, ok := r.keys[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if known.fromID != request.fromID || known.toID != request.toID || known.amount != request.amount {
+		return nil, true, fmt.Errorf("%w: key %q was used for %s from %q to %q", ErrIdempotencyConflict, key, known.amount, known.fromID, known.toID)
+	}
+	return known.cmd, true, nil
+}
+
+// keyLock is held by the request working on an idempotency key; holders
+// counts those holding or waiting for it.
+type keyLock struct {
+	mu      sync.Mutex
+	holders int
+}
+
+// LockKey blocks until no other caller holds the idempotency key and returns
+// the function releasing it. Requests retried concurrently under one key get the same
+// command from Transfer, and holding the key while building, calling and
+// reading it keeps them from calling it or reading its outcome while
+// another is still running it.
+func (r *Registry) LockKey(key string) func() {
+	r.mu.Lock()
+	if r.keyLocks == nil {
+		r.keyLocks = make(map[string]*keyLock)
+	}
+	l := r.keyLocks[key]
+	if l == nil {
+		l = &keyLock{}
+		r.keyLocks[key] = l
+	}
+	l.holders++
+	r.mu.Unlock()
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if l.holders--; l.holders == 0 {
+			delete(r.keyLocks, key)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// NegativeInterestPolicy decides what an InterestCommand does on an
+// overdrawn account.
+type NegativeInterestPolicy int
+
+const (
+	// SkipNegative accrues nothing while the balance is negative.
+	SkipNegative NegativeInterestPolicy = iota
+	// ChargeNegative withdraws balance * rate as interest owed.
+	ChargeNegative
+)
+
+// InterestCommand accrues balance * rate on an account. The interest is
+// computed when the command is called and applied through a leg that
+// remembers the exact amount, so Undo reverses what was applied even if the
+// balance has changed since.
+type InterestCommand struct {
+	id         string
+	account    *BankAccount
+	rate       float64
+	policy     NegativeInterestPolicy
+	rounding   RoundingMode
+	interest   Money
+	leg        *BankAccountCommand
+	succeeded  bool
+	createdAt  time.Time
+	executedAt time.Time
+
+	// err is ErrNilAccount for a command without an account; any other
+	// failure is the leg's.
+	err error
+}
+
+func NewInterestCommand(account *BankAccount, rate float64, policy NegativeInterestPolicy) *InterestCommand {
+	return &InterestCommand{id: newCommandID(), account: account, rate: rate, policy: policy, createdAt: account.now()}
+}
+
+// SetRounding sets how the accrued interest is rounded to cents. The
+// default is HalfEven.
+func (c *InterestCommand) SetRounding(mode RoundingMode) {
+	c.rounding = mode
+}
+
+func (c *InterestCommand) Call() {
+	if c.account == nil {
+		c.err, c.succeeded = ErrNilAccount, false
+		return
+	}
+	unlock := lockAccounts(c.account)
+	defer unlock()
+	if c.leg != nil && c.leg.executed {
+		return
+	}
+	c.accrueLocked()
+	if c.leg == nil {
+		c.succeeded = true
+		return
+	}
+	c.leg.callLocked()
+	c.succeeded = c.leg.succeeded
+}
+
+// accrueLocked computes the interest on the current balance and builds the
+// leg applying it, leaving leg nil when the policy skips the account or
+// nothing accrues. The caller must hold the account lock.
+func (c *InterestCommand) accrueLocked() {
+	c.executedAt = c.account.now()
+	c.interest = 0
+	c.leg = nil
+	balance := c.account.balance
+	if balance < 0 && c.policy == SkipNegative {
+		return
+	}
+	c.interest = c.rounding.Round(balance.Float64() * c.rate)
+	switch {
+	case c.interest == 0:
+		// Nothing to apply.
+	case c.interest > 0:
+		c.leg = newBankAccountCommand(c.account, Deposit, c.interest)
+	default:
+		c.leg = newBankAccountCommand(c.account, Withdraw, -c.interest)
+	}
+}
+
+func (c *InterestCommand) Undo() error {
+	if c.leg == nil {
+		return nil
+	}
+	return c.leg.Undo()
+}
+
+// Interest returns the amount accrued by the last Call: positive when it was
+// credited, negative when it was charged.
+func (c *InterestCommand) Interest() float64 {
+	return c.interest.Float64()
+}
+
+// AppliedAmount returns the interest currently applied to the account,
+// signed like Interest, or 0 if none is: the command hasn't run, failed, or
+// has been undone. It is the stored amount Undo will reverse, never a
+// recomputation from the balance.
+func (c *InterestCommand) AppliedAmount() float64 {
+	if c.leg == nil || !c.leg.executed {
+		return 0
+	}
+	return c.interest.Float64()
+}
+
+func (c *InterestCommand) Succeeded() bool {
+	return c.succeeded
+}
+
+func (c *InterestCommand) SetSucceeded(value bool) {
+	c.succeeded = value
+	if c.leg != nil {
+		c.leg.SetSucceeded(value)
+	}
+}
+
+func (c *InterestCommand) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.leg != nil {
+		return c.leg.Err()
+	}
+	return nil
+}
+
+func (c *InterestCommand) CreatedAt() time.Time {
+	return c.createdAt
+}
+
+func (c *InterestCommand) ExecutedAt() time.Time {
+	return c.executedAt
+}
+
+// Reverse returns a command taking back exactly the interest last applied.
+func (c *InterestCommand) Reverse() Command {
+	if c.leg == nil {
+		return NewBankAccountCommand(c.account, Deposit, 0)
+	}
+	return c.leg.Reverse()
+}
+
+func (c *InterestCommand) Clone() Command {
+	clone := NewInterestCommand(c.account, c.rate, c.policy)
+	clone.rounding = c.rounding
+	return clone
+}
+
+func (c *InterestCommand) Name() string {
+	return "Interest"
+}
+
+func (c *InterestCommand) Describe() string {
+	description := fmt.Sprintf("Interest at %v%% on %s", c.rate*100, accountLabel(c.account))
+	if c.leg != nil {
+		description += fmt.Sprintf(": %s", c.interest)
+	}
+	return description
+}
+
+func (c *InterestCommand) children() []Command {
+	if c.leg == nil {
+		return nil
+	}
+	return []Command{c.leg}
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+// However the balance moves between Call and Undo, Undo takes back exactly
+// the interest applied, so repeated cycles don't leak cents.
+func TestInterestUndoReversesAppliedAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance float64
+		rate    float64
+		policy  NegativeInterestPolicy
+		applied float64
+	}{
+		{"credit", 1000, 0.0333, SkipNegative, 33.3},
+		{"credit rounded to cents", 10.01, 0.035, SkipNegative, 0.35},
+		{"charge on an overdraft", -200, 0.015, ChargeNegative, -3},
+		{"skipped overdraft", -200, 0.015, SkipNegative, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := NewBankAccount(tt.balance, -1000)
+			want := account.BalanceMoney()
+			interest := NewInterestCommand(account, tt.rate, tt.policy)
+			for i := 0; i < 5; i++ {
+				interest.Call()
+				if !interest.Succeeded() {
+					t.Fatalf("cycle %d: %v", i, interest.Err())
+				}
+				if got := interest.AppliedAmount(); got != tt.applied {
+					t.Fatalf("cycle %d: AppliedAmount = %v, want %v", i, got, tt.applied)
+				}
+				account.Deposit(7.77)
+				if err := interest.Undo(); err != nil {
+					t.Fatalf("cycle %d: Undo: %v", i, err)
+				}
+				if got := interest.AppliedAmount(); got != 0 {
+					t.Fatalf("cycle %d: AppliedAmount after Undo = %v, want 0", i, got)
+				}
+				account.Withdraw(7.77)
+				if got := account.BalanceMoney(); got != want {
+					t.Fatalf("cycle %d: balance %s, want %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInterestAppliedAmountBeforeCall(t *testing.T) {
+	interest := NewInterestCommand(NewBankAccount(100, 0), 0.1, SkipNegative)
+	if got := interest.AppliedAmount(); got != 0 {
+		t.Fatalf("AppliedAmount = %v before Call, want 0", got)
+	}
+}
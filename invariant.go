@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrMoneyNotConserved = errors.New("money not conserved")
+
+// AssertConserved tolerates a difference of up to the larger of these: an
+// absolute floor well below a cent, and a relative bound that grows with the
+// totals, since float64 rounding error grows with magnitude. A leaked cent is
+// still caught on totals up to about ten billion.
+const (
+	absoluteEpsilon = 1e-6
+	relativeEpsilon = 1e-12
+)
+
+// TotalBalance sums the balances exactly, in cents, before converting the
+// total to float64.
+func TotalBalance(accounts ...*BankAccount) float64 {
+	var total Money
+	for _, account := range accounts {
+		total = total.Add(account.BalanceMoney())
+	}
+	return total.Float64()
+}
+
+// AssertConserved returns ErrMoneyNotConserved unless before and after are
+// equal within floating-point tolerance.
+func AssertConserved(before, after float64) error {
+	scale := math.Max(math.Abs(before), math.Abs(after))
+	if math.Abs(before-after) <= math.Max(absoluteEpsilon, relativeEpsilon*scale) {
+		return nil
+	}
+	return fmt.Errorf("%w: total went from %v to %v", ErrMoneyNotConserved, before, after)
+}
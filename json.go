@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownAccount   = errors.New("unknown account")
+	ErrMalformedCommand = errors.New("malformed command")
+)
+
+// MaxCommandAmount bounds the amount a deserialized command may carry, one
+// trillion either way. Anything larger is corrupt or hostile input.
+const MaxCommandAmount Money = 1_000_000_000_000_00
+
+var actionNames = map[Action]string{
+	Deposit:        "deposit",
+	Withdraw:       "withdraw",
+	BalanceInquiry: "balance_inquiry",
+	Authorize:      "authorize",
+	Capture:        "capture",
+	Release:        "release",
+	Fee:            "fee",
+	WithdrawAll:    "withdraw_all",
+	WithdrawUpTo:   "withdraw_up_to",
+}
+
+// MarshalText makes actions appear as "deposit"/"withdraw" in JSON rather
+// than as integers, so logs stay human-readable.
+func (a Action) MarshalText() ([]byte, error) {
+	name, ok := actionNames[a]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownAction, a)
+	}
+	return []byte(name), nil
+}
+
+func (a *Action) UnmarshalText(text []byte) error {
+	action, err := ParseAction(string(text))
+	if err != nil {
+		return err
+	}
+	*a = action
+	return nil
+}
+
+type bankAccountCommandJSON struct {
+	Version  int      `json:"version"`
+	ID       string   `json:"id,omitempty"`
+	Action   Action   `json:"action"`
+	Amount   Money    `json:"amount"`
+	Account  string   `json:"account"`
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Actor    string   `json:"actor,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// MarshalJSON serializes the account by its ID rather than by pointer.
+func (c *BankAccountCommand) MarshalJSON() ([]byte, error) {
+	accountID := c.accountID
+	if c.account != nil {
+		accountID = c.account.ID
+	}
+	return json.Marshal(bankAccountCommandJSON{
+		Version:  CommandSchemaVersion,
+		ID:       c.CommandID(),
+		Action:   c.action,
+		Amount:   c.amount,
+		Account:  accountID,
+		Category: c.category,
+		Tags:     c.tags,
+		Actor:    c.actor,
+		Reason:   c.reason,
+	})
+}
+
+// UnmarshalJSON restores an un-executed command with its original ID. Its
+// account stays unresolved until the command is passed to Replay. Payloads
+// written by an older schema are migrated first; see migrateJSON. The
+// payload must be an object with an action and an amount, and the amount
+// must lie within MaxCommandAmount, so a truncated or garbled record fails
+// with ErrMalformedCommand, ErrUnknownAction or ErrInvalidMoney instead of
+// decoding into a zero-value deposit.
+func (c *BankAccountCommand) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedCommand, err)
+	}
+	if fields == nil {
+		return fmt.Errorf("%w: not an object", ErrMalformedCommand)
+	}
+	for _, name := range []string{"action", "amount"} {
+		if raw, ok := fields[name]; !ok || string(raw) == "null" {
+			return fmt.Errorf("%w: missing %s", ErrMalformedCommand, name)
+		}
+	}
+	if err := migrateJSON(fields); err != nil {
+		return err
+	}
+	upgraded, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	var v bankAccountCommandJSON
+	if err := json.Unmarshal(upgraded, &v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%w: %v", ErrMalformedCommand, err)
+		}
+		return err
+	}
+	if v.Amount > MaxCommandAmount || v.Amount < -MaxCommandAmount {
+		return fmt.Errorf("%w: %s out of range", ErrInvalidMoney, v.Amount)
+	}
+	*c = BankAccountCommand{id: v.ID, action: v.Action, amount: v.Amount, accountID: v.Account, category: v.Category, tags: v.Tags, actor: v.Actor, reason: v.Reason}
+	return nil
+}
+
+// Replay resolves each command's account ID against accounts and calls the
+// commands in order. A command already bound to an account whose ID appears
+// in accounts is rebound to that account, so clones of live commands can be
+// replayed against shadow accounts; one whose ID is missing fails with
+// ErrUnknownAccount rather than touching its live account. It stops at the
+// first unknown account or failed command.
+func Replay(cmds []Command, accounts map[string]*BankAccount) error {
+	lookup := func(id string) (*BankAccount, bool) {
+		account, ok := accounts[id]
+		return account, ok
+	}
+	for i, cmd := range cmds {
+		if err := resolveAccounts(cmd, lookup); err != nil {
+			return fmt.Errorf("replay command %d: %w", i, err)
+		}
+		cmd.Call()
+		if !cmd.Succeeded() {
+			return fmt.Errorf("replay command %d: %w", i, cmd.Err())
+		}
+	}
+	return nil
+}
+
+// resolveAccounts binds the accounts of cmd, and of any commands inside it,
+// to the accounts lookup finds for their IDs.
+func resolveAccounts(cmd Command, lookup func(id string) (*BankAccount, bool)) error {
+	switch c := cmd.(type) {
+	case *BankAccountCommand:
+		id := c.accountID
+		if c.account != nil {
+			id = c.account.ID
+		}
+		account, ok := lookup(id)
+		if !ok {
+			return fmt.Errorf("%w %q", ErrUnknownAccount, id)
+		}
+		c.account = account
+	case *MoneyTransferCommand:
+		if err := resolveAccounts(&c.CompositeBankAccountCommand, lookup); err != nil {
+			return err
+		}
+		if len(c.commands) < 2 {
+			return fmt.Errorf("%w: transfer without its legs", ErrMalformedCommand)
+		}
+		c.from = c.commands[0].(*BankAccountCommand).account
+		c.to = c.commands[1].(*BankAccountCommand).account
+	case *CompositeBankAccountCommand:
+		for _, child := range c.commands {
+			if err := resolveAccounts(child, lookup); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// validCommandJSON and malformedCommandJSON are the fixtures the tests below
+// share with FuzzUnmarshalCommand as its seed corpus.
+var validCommandJSON = []string{
+	`{"version":2,"id":"cmd-1","action":"deposit","amount":"10.00","account":"a"}`,
+	`{"version":2,"action":"withdraw","amount":"12.5","account":"x","category":"rent","tags":["home"]}`,
+	`{"version":2,"action":"withdraw_up_to","amount":"-0.01","account":"a","actor":"ops","reason":"fix"}`,
+	`{"action":1,"amount":25,"account":"acct-1"}`,
+	`{"version":1,"action":0,"amount":1,"account":"a"}`,
+}
+
+var malformedCommandJSON = []struct {
+	payload string
+	want    error
+}{
+	{`null`, ErrMalformedCommand},
+	{`{}`, ErrMalformedCommand},
+	{`[1]`, ErrMalformedCommand},
+	{`7`, ErrMalformedCommand},
+	{`{"action":"deposit"}`, ErrMalformedCommand},
+	{`{"action":"deposit","amount":null}`, ErrMalformedCommand},
+	{`{"action":true,"amount":1}`, ErrMalformedCommand},
+	{`{"action":"deposit","amount":"NaN"}`, ErrInvalidMoney},
+	{`{"action":"deposit","amount":"Inf"}`, ErrInvalidMoney},
+	{`{"action":"deposit","amount":"2000000000000"}`, ErrInvalidMoney},
+	{`{"action":"deposit","amount":1e400}`, ErrInvalidMoney},
+	{`{"action":"nope","amount":1}`, ErrUnknownAction},
+	{`{"action":99,"amount":1}`, ErrUnknownAction},
+	{`{"version":3,"action":"deposit","amount":1}`, ErrUnsupportedVersion},
+}
+
+func TestUnmarshalCommand(t *testing.T) {
+	for _, payload := range validCommandJSON {
+		var cmd BankAccountCommand
+		if err := json.Unmarshal([]byte(payload), &cmd); err != nil {
+			t.Errorf("%s: %v", payload, err)
+		}
+	}
+	var cmd BankAccountCommand
+	if err := json.Unmarshal([]byte(validCommandJSON[1]), &cmd); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.action != Withdraw || cmd.amount != 1250 || cmd.accountID != "x" || cmd.category != "rent" {
+		t.Fatalf("decoded %s %s for %q in %q, want withdraw 12.50 for x in rent", cmd.action, cmd.amount, cmd.accountID, cmd.category)
+	}
+}
+
+func TestUnmarshalCommandRejectsMalformed(t *testing.T) {
+	for _, tt := range malformedCommandJSON {
+		var cmd BankAccountCommand
+		if err := cmd.UnmarshalJSON([]byte(tt.payload)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.payload, err, tt.want)
+		}
+	}
+}
+
+func TestMarshalCommandRoundTrip(t *testing.T) {
+	account := NewBankAccount(0, 0)
+	account.ID = "acct"
+	for action := range actionNames {
+		out, err := json.Marshal(NewBankAccountCommand(account, action, 12.34))
+		if err != nil {
+			t.Fatalf("%s: %v", action, err)
+		}
+		var cmd BankAccountCommand
+		if err := json.Unmarshal(out, &cmd); err != nil {
+			t.Fatalf("%s: %v", out, err)
+		}
+		if cmd.action != action || cmd.amount != 1234 || cmd.accountID != "acct" {
+			t.Fatalf("%s decoded as %s %s for %q", out, cmd.action, cmd.amount, cmd.accountID)
+		}
+	}
+}
+
+// FuzzUnmarshalCommand checks that no input panics the decoder, and that
+// whatever it accepts is a command with a known action and an amount in
+// range, which encodes and decodes back to itself.
+func FuzzUnmarshalCommand(f *testing.F) {
+	for _, payload := range validCommandJSON {
+		f.Add([]byte(payload))
+	}
+	for _, tt := range malformedCommandJSON {
+		f.Add([]byte(tt.payload))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var cmd BankAccountCommand
+		if err := cmd.UnmarshalJSON(data); err != nil {
+			return
+		}
+		if _, ok := actionNames[cmd.action]; !ok {
+			t.Fatalf("%q decoded to unknown action %d", data, cmd.action)
+		}
+		if cmd.amount > MaxCommandAmount || cmd.amount < -MaxCommandAmount {
+			t.Fatalf("%q decoded to out-of-range amount %s", data, cmd.amount)
+		}
+		out, err := json.Marshal(&cmd)
+		if err != nil {
+			t.Fatalf("re-encoding %q: %v", data, err)
+		}
+		var again BankAccountCommand
+		if err := json.Unmarshal(out, &again); err != nil {
+			t.Fatalf("decoding re-encoded %s: %v", out, err)
+		}
+		if again.action != cmd.action || again.amount != cmd.amount || again.accountID != cmd.accountID {
+			t.Fatalf("%s decoded as %s %s for %q, want %s %s for %q", out, again.action, again.amount, again.accountID, cmd.action, cmd.amount, cmd.accountID)
+		}
+	})
+}
+
+// A live command whose account isn't in the replay set fails rather than
+// falling back to the account it was built against.
+func TestReplayRejectsUnmappedLiveAccount(t *testing.T) {
+	live, other := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	live.ID, other.ID = "live", "other"
+	transfer, err := NewMoneyTransferCommand(live, other, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shadow := map[string]*BankAccount{"other": NewBankAccount(0, 0)}
+	if err := Replay([]Command{transfer.Clone()}, shadow); !errors.Is(err, ErrUnknownAccount) {
+		t.Fatalf("Replay = %v, want ErrUnknownAccount", err)
+	}
+	if live.Balance() != 100 || other.Balance() != 0 {
+		t.Fatalf("live balances %v, %v after Replay; want 100, 0", live.Balance(), other.Balance())
+	}
+	if err := Replay([]Command{NewBankAccountCommand(live, Deposit, 5)}, map[string]*BankAccount{}); !errors.Is(err, ErrUnknownAccount) {
+		t.Fatalf("Replay of a bound deposit = %v, want ErrUnknownAccount", err)
+	}
+	if live.Balance() != 100 {
+		t.Fatalf("live balance %v after Replay, want 100", live.Balance())
+	}
+}
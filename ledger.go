@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrUnbalancedLedger = errors.New("ledger does not balance")
+
+// Double-entry needs somewhere for money entering or leaving the bank to
+// come from or go to. ExternalAccountID is that contra account: cash
+// deposited and withdrawn, fees, interest and captured payments are all
+// booked against it. FXAccountID is the contra account of the currency
+// conversion inside a cross-currency transfer, debited in one currency and
+// credited in the other.
+const (
+	ExternalAccountID = "external"
+	FXAccountID       = "fx"
+)
+
+// LedgerEntry is one side of a booking. Exactly one of Debit and Credit is
+// non-zero. From the bank's point of view a customer account is a
+// liability, so a deposit credits it and a withdrawal debits it, matching
+// statement lines.
+type LedgerEntry struct {
+	// Transaction is the ID of the command passed to Record; CommandID is
+	// that of the leg that produced the entry.
+	Transaction string
+	CommandID   string
+	Time        time.Time
+	Account     string
+	Currency    string
+	Debit       Money
+	Credit      Money
+}
+
+// Ledger books executed commands as balanced debit and credit entries. It
+// is safe for concurrent use.
+type Ledger struct {
+	mu      sync.Mutex
+	entries []LedgerEntry
+}
+
+func NewLedger() *Ledger {
+	return &Ledger{}
+}
+
+// Record books the legs of cmd that are applied at the time of the call, so
+// it belongs after Call; a reversal is recorded as a command of its own.
+// Each leg of a transfer is booked from what the leg actually did rather
+// than from the transfer as a whole, so a leg that was dropped shows up as
+// an unbalanced transaction in Validate.
+func (l *Ledger) Record(cmd Command) {
+	j := journal{transaction: cmd.CommandID()}
+	j.post(cmd)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, j.entries...)
+}
+
+func (l *Ledger) Entries() []LedgerEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LedgerEntry(nil), l.entries...)
+}
+
+// Validate checks that, for every recorded transaction and currency, the
+// debits equal the credits, which also makes the ledger as a whole balance.
+// It returns ErrUnbalancedLedger describing each transaction that doesn't,
+// joined together.
+func (l *Ledger) Validate() error {
+	type key struct{ transaction, currency string }
+	var order []key
+	totals := make(map[key][2]Money)
+	for _, e := range l.Entries() {
+		k := key{e.Transaction, e.Currency}
+		total, seen := totals[k]
+		if !seen {
+			order = append(order, k)
+		}
+		total[0] += e.Debit
+		total[1] += e.Credit
+		totals[k] = total
+	}
+	var errs []error
+	for _, k := range order {
+		if total := totals[k]; total[0] != total[1] {
+			errs = append(errs, fmt.Errorf("%w: transaction %s%s debits %s, credits %s", ErrUnbalancedLedger, k.transaction, currencySuffix(k.currency), total[0], total[1]))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func currencySuffix(currency string) string {
+	if currency == "" {
+		return ""
+	}
+	return " in " + currency
+}
+
+// journal collects the entries of one recorded command.
+type journal struct {
+	transaction string
+	entries     []LedgerEntry
+}
+
+func (j *journal) book(cmdID string, at time.Time, account, currency string, debit, credit Money) {
+	j.entries = append(j.entries, LedgerEntry{
+		Transaction: j.transaction,
+		CommandID:   cmdID,
+		Time:        at,
+		Account:     account,
+		Currency:    currency,
+		Debit:       debit,
+		Credit:      credit,
+	})
+}
+
+func (j *journal) post(cmd Command) {
+	switch c := cmd.(type) {
+	case *BankAccountCommand:
+		j.postSingle(c)
+	case *MoneyTransferCommand:
+		j.postTransfer(c)
+	case *CloseAccountCommand:
+		j.postClose(c)
+	case parent:
+		for _, child := range c.children() {
+			j.post(child)
+		}
+	}
+}
+
+// postSingle books a leg that moves money between one account and the
+// outside world, followed by the overdraft fee it triggered, if any.
+func (j *journal) postSingle(c *BankAccountCommand) {
+	if c.executed && c.account != nil {
+		account, currency := accountLabel(c.account), c.account.Currency
+		switch c.action {
+		case Deposit:
+			j.book(c.CommandID(), c.executedAt, ExternalAccountID, currency, c.amount, 0)
+			j.book(c.CommandID(), c.executedAt, account, currency, 0, c.amount)
+		case Withdraw, WithdrawAll, WithdrawUpTo, Capture, Fee:
+			j.book(c.CommandID(), c.executedAt, account, currency, c.amount, 0)
+			j.book(c.CommandID(), c.executedAt, ExternalAccountID, currency, 0, c.amount)
+		}
+	}
+	if c.fee != nil {
+		j.postSingle(c.fee)
+	}
+}
+
+// postTransfer debits the source for the withdrawal leg and credits the
+// destination for the deposit leg, each only if that leg is applied. A
+// conversion is booked through FXAccountID alongside the withdrawal. Fee
+// legs go to the external account. A transfer missing an account never ran
+// and books nothing.
+func (j *journal) postTransfer(c *MoneyTransferCommand) {
+	if c.checkAccounts() != nil {
+		return
+	}
+	withdrawal := c.commands[0].(*BankAccountCommand)
+	deposit := c.commands[1].(*BankAccountCommand)
+	from, to := accountLabel(c.from), accountLabel(c.to)
+	if withdrawal.executed {
+		j.book(withdrawal.CommandID(), withdrawal.executedAt, from, c.from.Currency, withdrawal.amount, 0)
+		if c.from.Currency != c.to.Currency {
+			j.book(withdrawal.CommandID(), withdrawal.executedAt, FXAccountID, c.from.Currency, 0, withdrawal.amount)
+			j.book(withdrawal.CommandID(), withdrawal.executedAt, FXAccountID, c.to.Currency, c.credited, 0)
+		}
+	}
+	if deposit.executed {
+		j.book(deposit.CommandID(), deposit.executedAt, to, c.to.Currency, 0, deposit.amount)
+	}
+	// An overdraft fee rides on the leg that triggered it.
+	for _, leg := range []*BankAccountCommand{withdrawal, deposit} {
+		if leg.fee != nil {
+			j.postSingle(leg.fee)
+		}
+	}
+	for _, cmd := range c.commands[2:] {
+		j.postSingle(cmd.(*BankAccountCommand))
+	}
+}
+
+// postClose books a closure's settlement against the external account and
+// its sweep as a move between the two accounts.
+func (j *journal) postClose(c *CloseAccountCommand) {
+	if !c.executed {
+		return
+	}
+	account, currency := accountLabel(c.account), c.account.Currency
+	if c.settlement > 0 {
+		j.book(c.CommandID(), c.executedAt, ExternalAccountID, currency, c.settlement, 0)
+		j.book(c.CommandID(), c.executedAt, account, currency, 0, c.settlement)
+	}
+	if c.swept > 0 {
+		j.book(c.CommandID(), c.executedAt, account, currency, c.swept, 0)
+		j.book(c.CommandID(), c.executedAt, accountLabel(c.sweepTo), c.sweepTo.Currency, 0, c.swept)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// net returns the credits less the debits booked against account.
+func net(entries []LedgerEntry, account string) Money {
+	var total Money
+	for _, e := range entries {
+		if e.Account == account {
+			total += e.Credit - e.Debit
+		}
+	}
+	return total
+}
+
+func TestLedgerBalances(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	acct, other := statementAccounts(clock)
+	deposit := NewBankAccountCommand(acct, Deposit, 50)
+	transfer, err := NewMoneyTransferCommand(acct, other, 30, WithFee(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	composite := NewCompositeCommand(false,
+		NewBankAccountCommand(other, Withdraw, 10),
+		NewBankAccountCommand(acct, Deposit, 5),
+		NewBankAccountCommand(other, Withdraw, 1000),
+	)
+	ledger := NewLedger()
+	for _, cmd := range []Command{deposit, transfer, composite} {
+		cmd.Call()
+		ledger.Record(cmd)
+	}
+	if err := ledger.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := ledger.Entries()
+	// The overdrawing withdrawal failed and books nothing.
+	if len(entries) != 10 {
+		t.Fatalf("%d entries, want 10: %+v", len(entries), entries)
+	}
+	at := deposit.ExecutedAt()
+	want := []LedgerEntry{
+		{Transaction: deposit.CommandID(), CommandID: deposit.CommandID(), Time: at, Account: ExternalAccountID, Debit: NewMoney(50)},
+		{Transaction: deposit.CommandID(), CommandID: deposit.CommandID(), Time: at, Account: "acct", Credit: NewMoney(50)},
+	}
+	for i, w := range want {
+		if e := entries[i]; e.Transaction != w.Transaction || e.CommandID != w.CommandID || !e.Time.Equal(w.Time) || e.Account != w.Account || e.Debit != w.Debit || e.Credit != w.Credit {
+			t.Errorf("entry %d = %+v, want %+v", i, e, w)
+		}
+	}
+	for _, e := range entries[2:6] {
+		if e.Transaction != transfer.CommandID() {
+			t.Errorf("entry %+v isn't booked to the transfer %s", e, transfer.CommandID())
+		}
+	}
+	for _, tt := range []struct {
+		account string
+		want    Money
+	}{
+		{"acct", acct.BalanceMoney() - NewMoney(100)},
+		{"other", other.BalanceMoney()},
+		{ExternalAccountID, NewMoney(-43)},
+	} {
+		if got := net(entries, tt.account); got != tt.want {
+			t.Errorf("%s nets %s, want %s", tt.account, got, tt.want)
+		}
+	}
+}
+
+// A conversion balances in each currency through the FX account.
+func TestLedgerConvertedTransfer(t *testing.T) {
+	usd, eur := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	usd.ID, eur.ID = "usd", "eur"
+	usd.Currency, eur.Currency = "USD", "EUR"
+	transfer, err := NewMoneyTransferCommand(usd, eur, 33.33, WithConverter(FixedRates{"USD/EUR": 0.917}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := call(transfer); err != nil {
+		t.Fatal(err)
+	}
+	ledger := NewLedger()
+	ledger.Record(transfer)
+	if err := ledger.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := []LedgerEntry{
+		{Account: "usd", Currency: "USD", Debit: NewMoney(33.33)},
+		{Account: FXAccountID, Currency: "USD", Credit: NewMoney(33.33)},
+		{Account: FXAccountID, Currency: "EUR", Debit: NewMoney(30.56)},
+		{Account: "eur", Currency: "EUR", Credit: NewMoney(30.56)},
+	}
+	entries := ledger.Entries()
+	if len(entries) != len(want) {
+		t.Fatalf("%d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		if e := entries[i]; e.Account != w.Account || e.Currency != w.Currency || e.Debit != w.Debit || e.Credit != w.Credit {
+			t.Errorf("entry %d = %+v, want %+v", i, e, w)
+		}
+	}
+}
+
+// A transfer whose deposit leg was lost only books the debit, and Validate
+// names the transaction.
+func TestLedgerValidateDroppedLeg(t *testing.T) {
+	from, to := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	transfer, err := NewMoneyTransferCommand(from, to, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := call(transfer); err != nil {
+		t.Fatal(err)
+	}
+	transfer.commands[1].(*BankAccountCommand).executed = false
+	ledger := NewLedger()
+	ledger.Record(transfer)
+	ledger.Record(NewBankAccountCommand(from, Deposit, 10))
+	err = ledger.Validate()
+	if !errors.Is(err, ErrUnbalancedLedger) {
+		t.Fatalf("Validate = %v, want ErrUnbalancedLedger", err)
+	}
+	if want := "transaction " + transfer.CommandID() + " debits 30.00, credits 0.00"; !strings.Contains(err.Error(), want) {
+		t.Fatalf("Validate = %q, want it to report %q", err, want)
+	}
+	if len(ledger.Entries()) != 1 {
+		t.Fatalf("%d entries, want only the transfer's debit", len(ledger.Entries()))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrCompositeTooLarge = errors.New("composite command too large")
+
+// MaxChildren caps the number of commands in a composite, counting those
+// nested inside its children at every level, and MaxDepth caps how deeply
+// they may be nested. A composite beyond either fails with
+// ErrCompositeTooLarge when it is built, loaded, validated or called,
+// before any child runs. Zero removes a limit. Set them at start-up.
+var (
+	MaxChildren = 100_000
+	MaxDepth    = 64
+)
+
+// checkLimits walks the tree under root without recursing, so even a
+// pathologically deep one can't exhaust the stack, and stops as soon as it
+// finds root is over MaxChildren or MaxDepth.
+func checkLimits[T any](root T, children func(T) []T) error {
+	type node struct {
+		value T
+		depth int
+	}
+	stack := []node{{root, 0}}
+	count := 0
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if MaxDepth > 0 && n.depth > MaxDepth {
+			return fmt.Errorf("%w: nested deeper than %d", ErrCompositeTooLarge, MaxDepth)
+		}
+		kids := children(n.value)
+		count += len(kids)
+		if MaxChildren > 0 && count > MaxChildren {
+			return fmt.Errorf("%w: more than %d commands", ErrCompositeTooLarge, MaxChildren)
+		}
+		for _, kid := range kids {
+			stack = append(stack, node{kid, n.depth + 1})
+		}
+	}
+	return nil
+}
+
+func checkCommandLimits(cmd Command) error {
+	return checkLimits(cmd, func(cmd Command) []Command {
+		if p, ok := cmd.(parent); ok {
+			return p.children()
+		}
+		return nil
+	})
+}
+
+func checkGobLimits(wire gobCommand) error {
+	return checkLimits(wire, func(wire gobCommand) []gobCommand {
+		return wire.Children
+	})
+}
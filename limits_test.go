@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// setLimits sets MaxChildren and MaxDepth for the rest of the test.
+func setLimits(t *testing.T, children, depth int) {
+	t.Helper()
+	oldChildren, oldDepth := MaxChildren, MaxDepth
+	MaxChildren, MaxDepth = children, depth
+	t.Cleanup(func() { MaxChildren, MaxDepth = oldChildren, oldDepth })
+}
+
+// deeplyNested wraps leaf in depth composites, one inside the next.
+func deeplyNested(leaf Command, depth int) *CompositeBankAccountCommand {
+	cmd := NewCompositeCommand(false, leaf)
+	for i := 1; i < depth; i++ {
+		cmd = NewCompositeCommand(false, cmd)
+	}
+	return cmd
+}
+
+// A composite nested 100,000 deep is refused before its leaf runs, and
+// Validate reports the same.
+func TestDeepCompositeRefused(t *testing.T) {
+	account := NewBankAccount(0, 0)
+	top := deeplyNested(NewBankAccountCommand(account, Deposit, 1), 100_000)
+	top.Call()
+	if top.Succeeded() || !errors.Is(top.Err(), ErrCompositeTooLarge) {
+		t.Fatalf("succeeded %v, err %v; want ErrCompositeTooLarge", top.Succeeded(), top.Err())
+	}
+	if account.Balance() != 0 {
+		t.Fatalf("balance %v, want 0: the leaf ran", account.Balance())
+	}
+	if err := top.Validate(); !errors.Is(err, ErrCompositeTooLarge) {
+		t.Fatalf("Validate = %v, want ErrCompositeTooLarge", err)
+	}
+}
+
+// Succeeded, Flatten and Undo walk the tree without recursing, so they
+// reach a leaf 100,000 levels down.
+func TestDeepCompositeTraversal(t *testing.T) {
+	account := NewBankAccount(0, 0)
+	leaf := NewBankAccountCommand(account, Deposit, 1)
+	top := deeplyNested(leaf, 100_000)
+	leaf.Call()
+	if !top.Succeeded() {
+		t.Fatal("Succeeded missed the applied leaf")
+	}
+	if flat := top.Flatten(); len(flat) != 1 || flat[0] != leaf {
+		t.Fatalf("Flatten returned %d commands, want the one leaf", len(flat))
+	}
+	if err := top.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance() != 0 {
+		t.Fatalf("balance %v after Undo, want 0", account.Balance())
+	}
+}
+
+func TestCompositeLimits(t *testing.T) {
+	setLimits(t, 3, 2)
+	account := NewBankAccount(100, 0)
+	deposit := func() Command { return NewBankAccountCommand(account, Deposit, 1) }
+	tests := []struct {
+		name    string
+		cmd     *CompositeBankAccountCommand
+		wantErr bool
+	}{
+		{"at the child limit", NewCompositeCommand(false, deposit(), deposit(), deposit()), false},
+		{"over the child limit", NewCompositeCommand(false, deposit(), deposit(), deposit(), deposit()), true},
+		{"nested children count", NewCompositeCommand(false, deposit(), NewCompositeCommand(false, deposit(), deposit())), true},
+		{"at the depth limit", deeplyNested(deposit(), 2), false},
+		{"over the depth limit", deeplyNested(deposit(), 3), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cmd.Call()
+			if got := errors.Is(tt.cmd.Err(), ErrCompositeTooLarge); got != tt.wantErr {
+				t.Fatalf("err %v, want ErrCompositeTooLarge: %v", tt.cmd.Err(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadQueueRejectsDeepComposite(t *testing.T) {
+	setLimits(t, 0, 10)
+	wire := wireLeg(Deposit, 100, "a")
+	for i := 0; i < 20; i++ {
+		wire = gobCommand{Version: CommandSchemaVersion, Kind: gobComposite, Children: []gobCommand{wire}}
+	}
+	if _, err := LoadQueue(bytes.NewReader(encodeWire(t, wire)), queueRegistry(t)); !errors.Is(err, ErrCompositeTooLarge) {
+		t.Fatalf("LoadQueue = %v, want ErrCompositeTooLarge", err)
+	}
+}
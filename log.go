@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type LogFormat int
+
+const (
+	LogCSV LogFormat = iota
+	LogJSONLines
+)
+
+// LogEntry describes one leaf command. Fields are written in declaration
+// order by both formats.
+type LogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Action    Action    `json:"action"`
+	Amount    Money     `json:"amount"`
+	AccountID string    `json:"account"`
+	Succeeded bool      `json:"success"`
+	Balance   Money     `json:"balance"`
+
+	// OverdraftException marks a command that went past the overdraft
+	// limit under a one-time exception.
+	OverdraftException bool `json:"overdraft_exception"`
+
+	// Actor and Reason say who initiated the command and why.
+	Actor  string `json:"actor,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+var logHeader = []string{"timestamp", "action", "amount", "account", "success", "balance", "overdraft_exception", "actor", "reason"}
+
+// TransactionLog records executed commands as structured entries. It is
+// safe for concurrent use.
+type TransactionLog struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+func NewTransactionLog() *TransactionLog {
+	return &TransactionLog{}
+}
+
+// Record appends one entry per leaf command, so a transfer produces a line
+// for each of its legs.
+func (l *TransactionLog) Record(cmd Command) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, leaf := range leaves(cmd) {
+		c, ok := leaf.(*BankAccountCommand)
+		if !ok {
+			continue
+		}
+		entry := LogEntry{
+			Timestamp: c.executedAt,
+			Action:    c.action,
+			Amount:    c.amount,
+			Succeeded: c.succeeded,
+			Balance:   c.balanceAfter,
+
+			OverdraftException: c.overdraftExceptionUsed,
+			Actor:              c.actor,
+			Reason:             c.reason,
+		}
+		if c.account != nil {
+			entry.AccountID = c.account.ID
+		}
+		l.entries = append(l.entries, entry)
+	}
+}
+
+func (l *TransactionLog) Entries() []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LogEntry(nil), l.entries...)
+}
+
+// Dump writes every entry to w, either as CSV with a header row or as one
+// JSON object per line.
+func (l *TransactionLog) Dump(w io.Writer, format LogFormat) error {
+	entries := l.Entries()
+	switch format {
+	case LogCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(logHeader)
+		for _, e := range entries {
+			action, _ := e.Action.MarshalText()
+			cw.Write([]string{
+				e.Timestamp.Format(time.RFC3339Nano),
+				string(action),
+				e.Amount.String(),
+				e.AccountID,
+				strconv.FormatBool(e.Succeeded),
+				e.Balance.String(),
+				strconv.FormatBool(e.OverdraftException),
+				e.Actor,
+				e.Reason,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case LogJSONLines:
+		enc := json.NewEncoder(w)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown log format %d", int(format))
+}
+
+// parent is implemented by commands made of other commands.
+type parent interface {
+	children() []Command
+}
+
+// children lists the composite's children in the order the last run used.
+func (c *CompositeBankAccountCommand) children() []Command {
+	if c.sequence == nil {
+		return c.commands
+	}
+	ordered := make([]Command, len(c.commands))
+	for i := range ordered {
+		ordered[i] = c.child(i)
+	}
+	return ordered
+}
+
+// leaves returns the non-composite commands under cmd, depth first. It
+// keeps its own stack instead of recursing, so deep nesting can't overflow
+// the goroutine's.
+func leaves(cmd Command) []Command {
+	var result []Command
+	stack := []Command{cmd}
+	for len(stack) > 0 {
+		cmd := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		// An overdraft fee is logged as its own entry right after the
+		// withdrawal that triggered it.
+		if c, ok := cmd.(*BankAccountCommand); ok && c.fee != nil {
+			result = append(result, c, c.fee)
+			continue
+		}
+		p, ok := cmd.(parent)
+		if !ok {
+			result = append(result, cmd)
+			continue
+		}
+		children := p.children()
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, children[i])
+		}
+	}
+	return result
+}
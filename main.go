@@ -1,30 +1,396 @@
 package main
 
-import "fmt"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 var overdraftLimit = -500.0
 
+var (
+	ErrOverdraftExceeded   = errors.New("overdraft limit exceeded")
+	ErrNegativeAmount      = errors.New("amount must not be negative")
+	ErrDailyLimitExceeded  = errors.New("daily withdrawal limit exceeded")
+	ErrAlreadyExecuted     = errors.New("command already executed")
+	ErrBelowMinimumBalance = errors.New("balance would fall below minimum")
+	ErrAccountFrozen       = errors.New("account is frozen")
+	ErrUnknownAction       = errors.New("unknown action")
+	ErrSelfTransfer        = errors.New("cannot transfer to the same account")
+	ErrZeroAmount          = errors.New("amount must not be zero")
+	ErrAmountTooLarge      = errors.New("amount exceeds the transaction limit")
+	ErrInvalidAmount       = errors.New("amount is not a finite number")
+)
+
+// RejectZeroAmounts makes commands that move a zero amount fail with
+// ErrZeroAmount, for callers that treat one as a mistake. By default such a
+// command is a no-op that succeeds. Balance inquiries and WithdrawAll, whose
+// amount isn't given by the caller, are exempt. Set it at start-up.
+var RejectZeroAmounts = false
+
+// BankAccount is safe for concurrent use. Every read and write of balance
+// happens under mu.
 type BankAccount struct {
-	balance float64
+	ID       string
+	Currency string
+
+	// Holder is who owns the account; nil for accounts opened without
+	// NewBankAccountWithHolder. Set it before the account is shared.
+	Holder *Holder
+
+	// BeforeExecute and AfterExecute run around every BankAccountCommand
+	// and MoneyTransferCommand called on the account, including a transfer
+	// into it. A BeforeExecute hook returning an error vetoes the command
+	// before anything changes. Set them up before the account is shared.
+	BeforeExecute []BeforeHook
+	AfterExecute  []AfterHook
+
+	// Clock stamps the account's commands and balance history and decides
+	// which day a withdrawal counts against; nil means DefaultClock. Set it
+	// before the account is shared.
+	Clock Clock
+
+	mu             sync.Mutex
+	seq            atomic.Uint64
+	balance        Money
+	overdraftLimit Money
+
+	// held is the total reserved by outstanding authorizations. It stays
+	// part of balance until captured but is not available to spend.
+	held Money
+
+	// minimumBalance, when set, is a floor the balance must stay above. It
+	// applies instead of overdraftLimit whenever it is the stricter of the two.
+	minimumBalance    Money
+	hasMinimumBalance bool
+
+	// frozen blocks every deposit and withdrawal until Unfreeze.
+	frozen bool
+
+	// closed is set by a CloseAccountCommand and makes every command on the
+	// account fail until the closure is undone.
+	closed bool
+
+	// version counts changes to the balance and holds, for ExpectVersion.
+	version int
+
+	// overdraftFee is charged after withdrawals that leave the balance
+	// negative, but only while chargeOverdraftFee is set.
+	overdraftFee       Money
+	chargeOverdraftFee bool
+
+	// validators vet every deposit, withdrawal and authorization; nil means
+	// DefaultValidators.
+	validators []Validator
+
+	// dailyWithdrawLimit caps the sum of withdrawals per calendar day; zero
+	// means unlimited. withdrawnToday is the running total for withdrawnDay.
+	dailyWithdrawLimit Money
+	withdrawnDay       time.Time
+	withdrawnToday     Money
+
+	// maxTransactionAmount caps any single deposit or withdrawal; zero means
+	// unlimited.
+	maxTransactionAmount Money
+
+	// limits caps single commands per action, see SetActionLimit.
+	limits map[Action]Money
+
+	// history records every balance change, see adjust.
+	history []BalanceChange
+
+	// thresholds are the OnThreshold callbacks; crossings holds those due
+	// to run once the lock is released.
+	thresholds []*thresholdWatch
+	crossings  []pendingCrossing
+}
+
+var accountSeq atomic.Uint64
+
+func NewBankAccount(balance, overdraftLimit float64) *BankAccount {
+	account := &BankAccount{balance: NewMoney(balance), overdraftLimit: NewMoney(overdraftLimit)}
+	account.seq.Store(accountSeq.Add(1))
+	return account
 }
 
-func (account *BankAccount) Withdraw(amount float64) bool {
-	if account.balance-amount < overdraftLimit {
-		return false
+// lockOrder returns the key used to order lock acquisition across accounts.
+// Accounts built without NewBankAccount are assigned one on first use.
+func (account *BankAccount) lockOrder() uint64 {
+	if seq := account.seq.Load(); seq != 0 {
+		return seq
+	}
+	account.seq.CompareAndSwap(0, accountSeq.Add(1))
+	return account.seq.Load()
+}
+
+// lockAccounts locks every distinct account in ascending lockOrder, so two
+// goroutines locking the same set of accounts can never deadlock. The
+// returned function releases the locks.
+func lockAccounts(accounts ...*BankAccount) func() {
+	distinct := make([]*BankAccount, 0, len(accounts))
+	for _, account := range accounts {
+		seen := false
+		for _, d := range distinct {
+			if d == account {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			distinct = append(distinct, account)
+		}
+	}
+	sort.Slice(distinct, func(i, j int) bool {
+		return distinct[i].lockOrder() < distinct[j].lockOrder()
+	})
+	for _, account := range distinct {
+		account.mu.Lock()
+	}
+	return func() {
+		var crossings []pendingCrossing
+		for i := len(distinct) - 1; i >= 0; i-- {
+			crossings = append(crossings, distinct[i].takeCrossings()...)
+			distinct[i].mu.Unlock()
+		}
+		fireCrossings(crossings)
+	}
+}
+
+func (account *BankAccount) Balance() float64 {
+	return account.BalanceMoney().Float64()
+}
+
+func (account *BankAccount) BalanceMoney() Money {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return account.balance
+}
+
+// IsHealthy reports whether the balance, holds and limits are all real
+// amounts. An account opened or configured with a NaN or infinite float64
+// holds invalidMoney instead, and is not.
+func (account *BankAccount) IsHealthy() bool {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	for _, m := range []Money{account.balance, account.held, account.overdraftLimit, account.minimumBalance} {
+		if m == invalidMoney {
+			return false
+		}
 	}
-	account.balance -= amount
 	return true
 }
 
-func (account *BankAccount) Deposit(amount float64) {
-	account.balance += amount
+// SetDailyWithdrawLimit caps the total withdrawn per calendar day. A limit of
+// zero removes the cap.
+func (account *BankAccount) SetDailyWithdrawLimit(limit float64) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	account.dailyWithdrawLimit = NewMoney(limit)
+}
+
+// SetMaxTransactionAmount caps any single deposit or withdrawal on the
+// account, including either leg of a transfer, as an anti-fraud control.
+// Transfer fees are separate withdrawals and are checked on their own. A cap
+// of zero removes it.
+func (account *BankAccount) SetMaxTransactionAmount(limit float64) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	account.maxTransactionAmount = NewMoney(limit)
+}
+
+func (account *BankAccount) Freeze() {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	account.frozen = true
+}
+
+func (account *BankAccount) Unfreeze() {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	account.frozen = false
+}
+
+func (account *BankAccount) Frozen() bool {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return account.frozen
+}
+
+// SetMinimumBalance requires the balance to stay at or above min, for
+// example on savings accounts.
+func (account *BankAccount) SetMinimumBalance(min float64) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	account.minimumBalance = NewMoney(min)
+	account.hasMinimumBalance = true
+}
+
+// Withdraw and Deposit round amount to the nearest cent. NaN and infinite
+// amounts fail with ErrInvalidAmount and leave the account untouched.
+func (account *BankAccount) Withdraw(amount float64) error {
+	money := NewMoney(amount)
+	if money == invalidMoney {
+		return ErrInvalidAmount
+	}
+	return account.WithdrawMoney(money)
+}
+
+func (account *BankAccount) Deposit(amount float64) error {
+	money := NewMoney(amount)
+	if money == invalidMoney {
+		return ErrInvalidAmount
+	}
+	return account.DepositMoney(money)
+}
+
+func (account *BankAccount) WithdrawMoney(amount Money) error {
+	account.mu.Lock()
+	defer account.unlock()
+	return account.withdraw(amount)
+}
+
+func (account *BankAccount) DepositMoney(amount Money) error {
+	account.mu.Lock()
+	defer account.unlock()
+	return account.deposit(amount)
+}
+
+// withdraw and deposit expect the caller to hold account.mu. They vet the
+// amount as if it came from a command performing that action.
+func (account *BankAccount) withdraw(amount Money) error {
+	return account.withdrawFor(account.request(Withdraw, amount), account.now())
+}
+
+// reverseDeposit takes back an applied deposit, for Undo. It isn't a
+// withdrawal: the balance checks still apply, but the daily withdrawal limit
+// can't refuse it and it doesn't count towards the day's total. The caller
+// must hold account.mu.
+func (account *BankAccount) reverseDeposit(amount Money) error {
+	if err := account.usable(); err != nil {
+		return err
+	}
+	if err := account.validate(account.reversal(Withdraw, amount)); err != nil {
+		return err
+	}
+	account.adjust(-amount)
+	return nil
+}
+
+// withdrawFor applies cmd's withdrawal, counting it against the daily limit
+// of the day at falls on.
+func (account *BankAccount) withdrawFor(cmd *BankAccountCommand, at time.Time) error {
+	if err := account.checkWithdrawal(cmd, at); err != nil {
+		return err
+	}
+	account.adjust(-cmd.amount)
+	account.withdrawnToday += cmd.amount
+	return nil
+}
+
+// checkWithdrawal reports whether cmd's withdrawal may go ahead at time at,
+// starting a new daily total if at falls on a new day.
+func (account *BankAccount) checkWithdrawal(cmd *BankAccountCommand, at time.Time) error {
+	if err := account.usable(); err != nil {
+		return err
+	}
+	if err := account.validate(cmd); err != nil {
+		return err
+	}
+	day := startOfDay(at)
+	if !day.Equal(account.withdrawnDay) {
+		account.withdrawnDay = day
+		account.withdrawnToday = 0
+	}
+	if account.dailyWithdrawLimit > 0 && account.withdrawnToday+cmd.amount > account.dailyWithdrawLimit {
+		return ErrDailyLimitExceeded
+	}
+	return nil
+}
+
+// creditDailyWithdrawal gives back room under the daily limit of the day at
+// falls on, if that day is still the one being tracked.
+func (account *BankAccount) creditDailyWithdrawal(amount Money, at time.Time) {
+	if startOfDay(at).Equal(account.withdrawnDay) {
+		account.withdrawnToday -= amount
+	}
+}
+
+// withdrawable returns the most a withdrawal at time at could take: the
+// available balance down to the stricter of the minimum balance and the
+// overdraft limit, capped by what is left of that day's withdrawal limit.
+// The caller must hold account.mu, and the amount holds only until it is
+// released.
+func (account *BankAccount) withdrawable(at time.Time) Money {
+	floor := account.overdraftLimit
+	if account.hasMinimumBalance {
+		floor = max(floor, account.minimumBalance)
+	}
+	amount := account.balance - account.held - floor
+	if account.dailyWithdrawLimit > 0 {
+		var withdrawn Money
+		if startOfDay(at).Equal(account.withdrawnDay) {
+			withdrawn = account.withdrawnToday
+		}
+		amount = min(amount, account.dailyWithdrawLimit-withdrawn)
+	}
+	return max(amount, 0)
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func (account *BankAccount) deposit(amount Money) error {
+	return account.depositFor(account.request(Deposit, amount))
+}
+
+func (account *BankAccount) depositFor(cmd *BankAccountCommand) error {
+	if err := account.usable(); err != nil {
+		return err
+	}
+	if err := account.validate(cmd); err != nil {
+		return err
+	}
+	account.adjust(cmd.amount)
+	return nil
 }
 
 type Command interface {
 	Call()
-	Undo()
+	Undo() error
+
+	// CanUndo reports whether Undo would reverse anything, see canundo.go.
+	CanUndo() bool
+
 	Succeeded() bool
 	SetSucceeded(value bool)
+	Err() error
+	CreatedAt() time.Time
+	ExecutedAt() time.Time
+	Reverse() Command
+	Clone() Command
+	Name() string
+	Describe() string
+	CommandID() string
+
+	// Validate checks, without changing anything, whether Call would
+	// succeed against the accounts as they stand now. It is best-effort: a
+	// concurrent change can still make Call fail afterwards.
+	Validate() error
+
+	// Prepare, Commit and Rollback run a command in two phases, see
+	// twophase.go.
+	Prepare() error
+	Commit()
+	Rollback()
 }
 
 type Action int
@@ -32,35 +398,262 @@ type Action int
 const (
 	Deposit Action = iota
 	Withdraw
+	BalanceInquiry
+	Authorize
+	Capture
+	Release
+	Fee
+	// WithdrawAll withdraws whatever the account can spare when it runs;
+	// the amount given to the command is ignored.
+	WithdrawAll
+	// WithdrawUpTo withdraws the amount given to the command, or as much of
+	// it as the account can spare, see withdrawupto.go.
+	WithdrawUpTo
 )
 
+var actionStrings = [...]string{
+	Deposit:        "Deposit",
+	Withdraw:       "Withdraw",
+	BalanceInquiry: "BalanceInquiry",
+	Authorize:      "Authorize",
+	Capture:        "Capture",
+	Release:        "Release",
+	Fee:            "Fee",
+	WithdrawAll:    "WithdrawAll",
+	WithdrawUpTo:   "WithdrawUpTo",
+}
+
+// String returns the action's name, such as "Deposit", or "Action(n)" for a
+// value outside the defined actions.
+func (a Action) String() string {
+	if !a.valid() {
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+	return actionStrings[a]
+}
+
+func (a Action) valid() bool {
+	return a >= 0 && int(a) < len(actionStrings)
+}
+
+// ParseAction accepts the names returned by String, in any case, as well as
+// the snake_case forms used in JSON such as "balance_inquiry".
+func ParseAction(s string) (Action, error) {
+	for i, name := range actionStrings {
+		action := Action(i)
+		if strings.EqualFold(s, name) || s == actionNames[action] {
+			return action, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q", ErrUnknownAction, s)
+}
+
 type BankAccountCommand struct {
-	account   *BankAccount
-	action    Action
-	amount    float64
-	succeeded bool
+	id         string
+	account    *BankAccount
+	accountID  string
+	action     Action
+	amount     Money
+	succeeded  bool
+	err        error
+	result     Money
+	priority   int
+	prepared   bool
+	executed   bool
+	createdAt  time.Time
+	executedAt time.Time
+
+	// balanceAfter is the account balance right after Call last ran.
+	balanceAfter Money
+
+	// fee is the overdraft fee charged after this withdrawal, if any.
+	fee *BankAccountCommand
+
+	// allowOneTimeOverdraft lets this command alone go past the account's
+	// overdraft limit; overdraftExceptionUsed records that it had to.
+	allowOneTimeOverdraft  bool
+	overdraftExceptionUsed bool
+
+	// expectedVersion is the account version Call requires, when
+	// hasExpectedVersion is set.
+	expectedVersion    int
+	hasExpectedVersion bool
+
+	// onSuccess and onFailure are the callbacks set with OnSuccess and
+	// OnFailure.
+	onSuccess func(Command)
+	onFailure func(Command, error)
+
+	// category and tags label the command for statements and reports.
+	category string
+	tags     []string
+
+	// actor and reason attribute the command for auditing, see audit.go.
+	actor  string
+	reason string
+
+	// upTo is the most a WithdrawUpTo command may take; amount becomes
+	// what it actually took.
+	upTo Money
+
+	// reversal marks the request Undo validates when it takes an applied
+	// command back, see BankAccount.reversal.
+	reversal bool
 }
 
+// NewBankAccountCommand rounds amount to the nearest cent.
+func NewBankAccountCommand(account *BankAccount, action Action, amount float64) *BankAccountCommand {
+	return newBankAccountCommand(account, action, NewMoney(amount))
+}
+
+func newBankAccountCommand(account *BankAccount, action Action, amount Money) *BankAccountCommand {
+	return &BankAccountCommand{id: newCommandID(), account: account, action: action, amount: amount, createdAt: account.now()}
+}
+
+// Call fails with ErrNilAccount when the command has no account, such as one
+// decoded from JSON that hasn't been through Replay.
 func (c *BankAccountCommand) Call() {
-	switch c.action {
-	case Deposit:
-		c.account.Deposit(c.amount)
-		c.succeeded = true
-	case Withdraw:
-		c.succeeded = c.account.Withdraw(c.amount)
+	if c.account == nil {
+		c.err, c.succeeded = ErrNilAccount, false
+		c.notifyOutcome()
+		return
+	}
+	if _, err := runBeforeHooks(c, c.account); err != nil {
+		if c.veto(err) {
+			c.notifyOutcome()
+		}
+		return
+	}
+	unlock := lockAccounts(c.account)
+	alreadyApplied := c.executed
+	c.callLocked()
+	unlock()
+	runAfterHooks(c, c.account)
+	if !alreadyApplied {
+		c.notifyOutcome()
 	}
 }
 
-func (c *BankAccountCommand) Undo() {
-	if !c.succeeded {
+// Undo returns the error that kept the reversal from being applied, for
+// example ErrOverdraftExceeded when undoing a deposit that has since been
+// spent. Undoing a command that has nothing to reverse returns nil.
+func (c *BankAccountCommand) Undo() error {
+	if c.account == nil {
+		return nil
+	}
+	unlock := lockAccounts(c.account)
+	defer unlock()
+	return c.undoLocked()
+}
+
+// callLocked and undoLocked expect the caller to hold the account lock.
+// A command whose effect has been applied and not undone is marked executed;
+// calling it again is a no-op, so a retry can never apply it twice. Likewise
+// Undo only reverses an executed command, and only once.
+func (c *BankAccountCommand) callLocked() {
+	if c.executed {
 		return
 	}
+	c.executedAt = c.account.now()
+	c.fee = nil
+	c.overdraftExceptionUsed = false
+	c.restoreUpTo()
+	c.err = c.checkArgs()
+	if c.err == nil && c.account.closed {
+		c.err = ErrAccountClosed
+	}
+	if c.err == nil {
+		c.err = c.checkVersion()
+	}
+	if c.err == nil {
+		switch c.action {
+		case Deposit:
+			c.err = c.account.depositFor(c)
+		case Withdraw:
+			c.err = c.account.withdrawFor(c, c.executedAt)
+		case WithdrawAll:
+			c.amount = c.account.withdrawable(c.executedAt)
+			c.err = c.account.withdrawFor(c, c.executedAt)
+		case WithdrawUpTo:
+			c.amount = c.account.withdrawableUpTo(c.upTo, c.executedAt)
+			c.err = c.account.withdrawFor(c, c.executedAt)
+		case BalanceInquiry:
+			c.result = c.account.balance
+		case Authorize:
+			c.err = c.account.authorizeFor(c)
+		case Capture:
+			c.err = c.account.capture(c.amount)
+		case Release:
+			c.err = c.account.release(c.amount)
+		case Fee:
+			c.err = c.account.chargeFor(c)
+		}
+	}
+	c.succeeded = c.err == nil
+	c.executed = c.succeeded && c.action != BalanceInquiry
+	c.balanceAfter = c.account.balance
+	if c.executed {
+		c.chargeOverdraftFee()
+	}
+}
+
+func (c *BankAccountCommand) undoLocked() error {
+	if !c.succeeded || !c.executed {
+		return nil
+	}
+	if c.fee != nil {
+		if err := c.fee.undoLocked(); err != nil {
+			return err
+		}
+	}
+	var err error
 	switch c.action {
 	case Deposit:
-		c.account.Withdraw(c.amount)
-	case Withdraw:
-		c.account.Deposit(c.amount)
+		err = c.account.reverseDeposit(c.amount)
+	case Withdraw, WithdrawAll, WithdrawUpTo:
+		if err = c.account.depositFor(c.account.reversal(Deposit, c.amount)); err == nil {
+			c.account.creditDailyWithdrawal(c.amount, c.executedAt)
+		}
+	case Authorize:
+		err = c.account.release(c.amount)
+	case Capture:
+		err = c.account.uncapture(c.amount)
+	case Release:
+		err = c.account.authorizeFor(c.account.reversal(Authorize, c.amount))
+	case Fee:
+		err = c.account.depositFor(c.account.reversal(Deposit, c.amount))
+	}
+	// A reversal that failed, for example on a frozen account, leaves the
+	// command executed, with its fee charged again, so it can be undone
+	// later.
+	c.executed = err != nil
+	if err != nil {
+		if c.fee != nil {
+			c.fee.callLocked()
+		}
+		return fmt.Errorf("undo %s: %w", c.Describe(), err)
+	}
+	return nil
+}
+
+// checkArgs reports whether the command can be attempted at all. A zero
+// amount is valid, and the command succeeds without changing the balance,
+// unless RejectZeroAmounts is set. An action outside the defined ones fails
+// with ErrUnknownAction.
+func (c *BankAccountCommand) checkArgs() error {
+	if !c.action.valid() {
+		return fmt.Errorf("%w %s", ErrUnknownAction, c.action)
+	}
+	if c.amount == invalidMoney {
+		return ErrInvalidAmount
 	}
+	if c.amount < 0 {
+		return ErrNegativeAmount
+	}
+	if c.amount == 0 && RejectZeroAmounts && c.action != BalanceInquiry && c.action != WithdrawAll {
+		return ErrZeroAmount
+	}
+	return nil
 }
 
 func (c *BankAccountCommand) Succeeded() bool {
@@ -71,106 +664,798 @@ func (c *BankAccountCommand) SetSucceeded(value bool) {
 	c.succeeded = value
 }
 
+func (c *BankAccountCommand) Err() error {
+	return c.err
+}
+
+// CreatedAt is zero for commands built without NewBankAccountCommand.
+func (c *BankAccountCommand) CreatedAt() time.Time {
+	return c.createdAt
+}
+
+// ExecutedAt reports when Call last ran, or zero if it never has.
+func (c *BankAccountCommand) ExecutedAt() time.Time {
+	return c.executedAt
+}
+
+// Reverse returns a new, un-executed command performing the inverse action.
+// Unlike Undo it is an operation in its own right, so both the original and
+// the correction show up in an audit trail. A captured payment is reversed
+// by a refund deposit.
+func (c *BankAccountCommand) Reverse() Command {
+	switch c.action {
+	case Deposit:
+		return newBankAccountCommand(c.account, Withdraw, c.amount)
+	case Withdraw, WithdrawAll, WithdrawUpTo:
+		return newBankAccountCommand(c.account, Deposit, c.amount)
+	case Authorize:
+		return newBankAccountCommand(c.account, Release, c.amount)
+	case Release:
+		return newBankAccountCommand(c.account, Authorize, c.amount)
+	case Capture, Fee:
+		return newBankAccountCommand(c.account, Deposit, c.amount)
+	}
+	return newBankAccountCommand(c.account, c.action, c.amount)
+}
+
+// Clone returns a fresh, un-executed copy bound to the same account. Replay
+// can rebind it to a different set of accounts by ID.
+func (c *BankAccountCommand) Clone() Command {
+	return &BankAccountCommand{
+		id:        newCommandID(),
+		account:   c.account,
+		accountID: c.accountID,
+		action:    c.action,
+		amount:    c.amount,
+		upTo:      c.upTo,
+		priority:  c.priority,
+		createdAt: c.account.now(),
+
+		allowOneTimeOverdraft: c.allowOneTimeOverdraft,
+		expectedVersion:       c.expectedVersion,
+		hasExpectedVersion:    c.hasExpectedVersion,
+		category:              c.category,
+		tags:                  slices.Clone(c.tags),
+		actor:                 c.actor,
+		reason:                c.reason,
+	}
+}
+
+func (c *BankAccountCommand) Name() string {
+	return c.action.String()
+}
+
+// Describe returns a readable summary such as "Withdraw 200.00 from acct-1",
+// followed by who initiated the command and why when those are set, as in
+// "Withdraw 200.00 from acct-1 by teller-07 (loan disbursement)".
+func (c *BankAccountCommand) Describe() string {
+	return c.describeAction() + c.attribution()
+}
+
+func (c *BankAccountCommand) describeAction() string {
+	switch c.action {
+	case Deposit:
+		return fmt.Sprintf("Deposit %s to %s", c.amount, c.accountLabel())
+	case Withdraw:
+		return fmt.Sprintf("Withdraw %s from %s", c.amount, c.accountLabel())
+	case WithdrawAll:
+		if c.executed {
+			return fmt.Sprintf("Withdraw all (%s) from %s", c.amount, c.accountLabel())
+		}
+		return fmt.Sprintf("Withdraw all from %s", c.accountLabel())
+	case WithdrawUpTo:
+		if c.executed {
+			return fmt.Sprintf("Withdraw %s (up to %s) from %s", c.amount, c.upTo, c.accountLabel())
+		}
+		limit := c.amount
+		if c.upTo != 0 {
+			limit = c.upTo
+		}
+		return fmt.Sprintf("Withdraw up to %s from %s", limit, c.accountLabel())
+	case BalanceInquiry:
+		return fmt.Sprintf("Balance inquiry on %s", c.accountLabel())
+	case Authorize:
+		return fmt.Sprintf("Authorize %s on %s", c.amount, c.accountLabel())
+	case Capture:
+		return fmt.Sprintf("Capture %s from %s", c.amount, c.accountLabel())
+	case Release:
+		return fmt.Sprintf("Release %s on %s", c.amount, c.accountLabel())
+	case Fee:
+		return fmt.Sprintf("Fee %s on %s", c.amount, c.accountLabel())
+	}
+	return fmt.Sprintf("%s on %s", c.action, c.accountLabel())
+}
+
+func (c *BankAccountCommand) accountLabel() string {
+	if c.account != nil {
+		return accountLabel(c.account)
+	}
+	if c.accountID != "" {
+		return c.accountID
+	}
+	return "unknown account"
+}
+
+func accountLabel(account *BankAccount) string {
+	if account == nil {
+		return "unknown account"
+	}
+	if account.ID == "" {
+		return "unnamed account"
+	}
+	return account.ID
+}
+
+// Amount returns the command's amount. For WithdrawAll and WithdrawUpTo it
+// is the amount the last Call actually withdrew.
+func (c *BankAccountCommand) Amount() float64 {
+	return c.amount.Float64()
+}
+
+// SetAllowOneTimeOverdraft lets this command, and only this command, take
+// the account past its overdraft limit, for example for an emergency
+// transfer. The account's standing limit is unchanged.
+func (c *BankAccountCommand) SetAllowOneTimeOverdraft(allow bool) {
+	c.allowOneTimeOverdraft = allow
+}
+
+// OverdraftExceptionUsed reports whether the last Call only succeeded
+// because of SetAllowOneTimeOverdraft.
+func (c *BankAccountCommand) OverdraftExceptionUsed() bool {
+	return c.overdraftExceptionUsed
+}
+
+// BalanceAfter returns the account's balance right after Call last ran.
+func (c *BankAccountCommand) BalanceAfter() Money {
+	return c.balanceAfter
+}
+
+// Result returns the balance recorded by a BalanceInquiry.
+func (c *BankAccountCommand) Result() float64 {
+	return c.result.Float64()
+}
+
 type CompositeBankAccountCommand struct {
+	id       string
 	commands []Command
+
+	// stopOnFailure makes Call halt at the first failing child and roll
+	// back the children before it. rollbackErr holds the errors of children
+	// that couldn't be rolled back.
+	stopOnFailure bool
+	rollbackErr   error
+
+	// resumeFrom is the index of the first child Call runs; see ResumeFrom.
+	resumeFrom int
+
+	// order is the strategy set with SetOrder. sequence holds the child
+	// indexes in the order the last run used, nil for slice order.
+	order    OrderStrategy
+	sequence []int
+
+	// refusedErr is set when the last Call refused to run the composite for
+	// being over MaxChildren or MaxDepth, or a transfer for lacking an
+	// account.
+	refusedErr error
+
+	// suspense is the account set with SetSuspenseAccount; suspended lists
+	// the deposits the last rollback booked against it.
+	suspense  *BankAccount
+	suspended []SuspenseEntry
 }
 
+// NewCompositeCommand groups cmds. With stopOnFailure false it behaves like a
+// plain composite literal and runs every child whatever happens; with it
+// true the composite is all-or-nothing, see Call.
+func NewCompositeCommand(stopOnFailure bool, cmds ...Command) *CompositeBankAccountCommand {
+	return &CompositeBankAccountCommand{id: newCommandID(), commands: cmds, stopOnFailure: stopOnFailure}
+}
+
+// Call runs the children in order. By default every child runs even if an
+// earlier one failed. A composite built with stopOnFailure stops at the
+// first failure instead and undoes the failed child and everything before
+// it in reverse order of execution, so the accounts are left as they were;
+// every child is then reported as failed.
 func (c *CompositeBankAccountCommand) Call() {
-	for _, cmd := range c.commands {
+	c.rollbackErr, c.suspended = nil, nil
+	if c.refusedErr = checkCommandLimits(c); c.refusedErr != nil {
+		return
+	}
+	c.arrange()
+	for i := c.start(); i < len(c.commands); i++ {
+		cmd := c.child(i)
 		cmd.Call()
+		if c.stopOnFailure && !cmd.Succeeded() {
+			c.rollbackErr = c.rollback(i+1, nil)
+			return
+		}
 	}
 }
 
-func (c *CompositeBankAccountCommand) Undo() {
-	for i := len(c.commands) - 1; i >= 0; i-- {
-		c.commands[i].Undo()
+// Undo reverses the children in the reverse of the order they ran in and
+// stops at the first child that can't be reversed, returning its error.
+// Children undone before the failure stay undone; ForceUndo carries on past
+// failures instead. Nested composites are walked with an explicit stack
+// rather than by recursion, so nesting depth can't overflow the stack.
+func (c *CompositeBankAccountCommand) Undo() error {
+	type frame struct {
+		composite *CompositeBankAccountCommand
+		next      int
+	}
+	stack := []frame{{c, len(c.commands) - 1}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next < 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		cmd := top.composite.child(top.next)
+		top.next--
+		if nested, ok := cmd.(*CompositeBankAccountCommand); ok {
+			stack = append(stack, frame{nested, len(nested.commands) - 1})
+			continue
+		}
+		if err := cmd.Undo(); err != nil {
+			for i := len(stack) - 1; i >= 0; i-- {
+				err = fmt.Errorf("child %d: %w", stack[i].next+1, err)
+			}
+			return err
+		}
 	}
+	return nil
 }
 
+// ForceUndo reverses every child it can, in reverse order, and returns the
+// errors of those it couldn't joined together, so a partial rollback is
+// never silent. Nested composites are force-undone too, walked with the same
+// explicit stack as Undo, so a failure deep inside one doesn't stop its
+// siblings from being reversed.
+func (c *CompositeBankAccountCommand) ForceUndo() error {
+	type frame struct {
+		composite *CompositeBankAccountCommand
+		next      int
+	}
+	var errs []error
+	stack := []frame{{c, len(c.commands) - 1}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next < 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		cmd := top.composite.child(top.next)
+		top.next--
+		if nested, ok := cmd.(*CompositeBankAccountCommand); ok {
+			stack = append(stack, frame{nested, len(nested.commands) - 1})
+			continue
+		}
+		undo := cmd.Undo
+		if forcer, ok := cmd.(interface{ ForceUndo() error }); ok {
+			undo = forcer.ForceUndo
+		}
+		if err := undo(); err != nil {
+			for i := len(stack) - 1; i >= 0; i-- {
+				err = fmt.Errorf("child %d: %w", stack[i].next+1, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Succeeded reports whether every child succeeded. Nested composites are
+// expanded in place, without recursion, so the check reaches leaves at any
+// depth. An empty composite has trivially succeeded; one that Call refused
+// for its size has not. Children skipped with ResumeFrom count as applied.
 func (c *CompositeBankAccountCommand) Succeeded() bool {
-	for _, cmd := range c.commands {
-		if !cmd.Succeeded() {
+	stack := []*CompositeBankAccountCommand{c}
+	for len(stack) > 0 {
+		composite := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if composite.refusedErr != nil {
 			return false
 		}
+		for i := composite.start(); i < len(composite.commands); i++ {
+			cmd := composite.child(i)
+			if nested, ok := cmd.(*CompositeBankAccountCommand); ok {
+				stack = append(stack, nested)
+			} else if !cmd.Succeeded() {
+				return false
+			}
+		}
 	}
 	return true
 }
 
+// SetSucceeded sets value on every child and, through nested composites, on
+// every leaf below them.
 func (c *CompositeBankAccountCommand) SetSucceeded(value bool) {
 	for _, cmd := range c.commands {
 		cmd.SetSucceeded(value)
 	}
 }
 
+// Flatten returns every leaf command under c, however deeply composites are
+// nested, in the order they run. Transfers and interest commands are
+// expanded into their legs, and an overdraft fee follows the withdrawal that
+// triggered it.
+func (c *CompositeBankAccountCommand) Flatten() []Command {
+	return leaves(c)
+}
+
+// Err returns the first child error, together with any errors from a
+// rollback that didn't complete, or ErrCompositeTooLarge if Call refused
+// to run the composite.
+func (c *CompositeBankAccountCommand) Err() error {
+	if c.refusedErr != nil {
+		return c.refusedErr
+	}
+	for _, cmd := range c.commands {
+		if err := cmd.Err(); err != nil {
+			if c.rollbackErr != nil {
+				return errors.Join(err, c.rollbackErr)
+			}
+			return err
+		}
+	}
+	return c.rollbackErr
+}
+
+// CreatedAt returns the earliest creation time among the children.
+func (c *CompositeBankAccountCommand) CreatedAt() time.Time {
+	var earliest time.Time
+	for _, cmd := range c.commands {
+		t := cmd.CreatedAt()
+		if !t.IsZero() && (earliest.IsZero() || t.Before(earliest)) {
+			earliest = t
+		}
+	}
+	return earliest
+}
+
+// ExecutedAt returns the latest execution time among the children.
+func (c *CompositeBankAccountCommand) ExecutedAt() time.Time {
+	var latest time.Time
+	for _, cmd := range c.commands {
+		if t := cmd.ExecutedAt(); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// Reverse returns a composite of the children's reversals in reverse order.
+func (c *CompositeBankAccountCommand) Reverse() Command {
+	reversed := make([]Command, 0, len(c.commands))
+	for i := len(c.commands) - 1; i >= 0; i-- {
+		reversed = append(reversed, c.child(i).Reverse())
+	}
+	return &CompositeBankAccountCommand{commands: reversed}
+}
+
+func (c *CompositeBankAccountCommand) Clone() Command {
+	return &CompositeBankAccountCommand{commands: cloneAll(c.commands), stopOnFailure: c.stopOnFailure, order: c.order, suspense: c.suspense}
+}
+
+func (c *CompositeBankAccountCommand) Name() string {
+	return "Composite"
+}
+
+// Describe joins the children's descriptions.
+func (c *CompositeBankAccountCommand) Describe() string {
+	descriptions := make([]string, len(c.commands))
+	for i, cmd := range c.commands {
+		descriptions[i] = cmd.Describe()
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+func cloneAll(cmds []Command) []Command {
+	clones := make([]Command, len(cmds))
+	for i, cmd := range cmds {
+		clones[i] = cmd.Clone()
+	}
+	return clones
+}
+
+// MoneyTransferCommand holds the locks of both accounts, acquired in
+// lockOrder, for the whole of Call and Undo. Concurrent transfers in opposite
+// directions between the same pair of accounts therefore cannot deadlock, and
+// no other goroutine observes the money mid-flight.
 type MoneyTransferCommand struct {
 	CompositeBankAccountCommand
-	from   *BankAccount
-	to     *BankAccount
-	amount float64
+	from      *BankAccount
+	to        *BankAccount
+	amount    Money
+	fee       Money
+	converter CurrencyConverter
+	rounding  RoundingMode
+
+	// rate converts amount, in the source currency, into credited, in the
+	// destination currency. It is fixed at construction so Undo and Reverse
+	// use exactly the rate the transfer was made at.
+	rate     float64
+	credited Money
+}
+
+type TransferOption func(*MoneyTransferCommand)
+
+// WithFee charges fee to the source account as a separate withdrawal leg
+// that commits or fails together with the transfer itself.
+func WithFee(fee float64) TransferOption {
+	return func(c *MoneyTransferCommand) {
+		c.fee = NewMoney(fee)
+	}
 }
 
-func NewMoneyTransferCommand(from, to *BankAccount, amount float64) *MoneyTransferCommand {
-	withdrawCmd := &BankAccountCommand{account: from, action: Withdraw, amount: amount}
-	depositCmd := &BankAccountCommand{account: to, action: Deposit, amount: amount}
-	commands := []Command{withdrawCmd, depositCmd}
-	return &MoneyTransferCommand{
-		CompositeBankAccountCommand: CompositeBankAccountCommand{commands: commands},
-		from:                        from,
-		to:                          to,
-		amount:                      amount,
+// WithRounding sets how the converted amount of a cross-currency transfer
+// is rounded to cents. The default is HalfEven.
+func WithRounding(mode RoundingMode) TransferOption {
+	return func(c *MoneyTransferCommand) {
+		c.rounding = mode
 	}
 }
 
+// NewMoneyTransferCommand rejects a negative amount or fee with
+// ErrNegativeAmount. When the accounts hold different currencies the
+// destination is credited the converted amount, and the transfer is rejected
+// with ErrNoExchangeRate unless a converter supplies a rate. A transfer from
+// an account to itself would move nothing and is rejected with
+// ErrSelfTransfer, and a nil account with ErrNilAccount.
+func NewMoneyTransferCommand(from, to *BankAccount, amount float64, opts ...TransferOption) (*MoneyTransferCommand, error) {
+	c := &MoneyTransferCommand{from: from, to: to, amount: NewMoney(amount), rate: 1}
+	c.id = newCommandID()
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.amount == invalidMoney || c.fee == invalidMoney {
+		return nil, ErrInvalidAmount
+	}
+	if c.amount < 0 || c.fee < 0 {
+		return nil, ErrNegativeAmount
+	}
+	if c.amount == 0 && RejectZeroAmounts {
+		return nil, ErrZeroAmount
+	}
+	if from == nil || to == nil {
+		return nil, ErrNilAccount
+	}
+	if from == to {
+		return nil, fmt.Errorf("%w: %s", ErrSelfTransfer, accountLabel(from))
+	}
+	if from.Currency != to.Currency {
+		if c.converter == nil {
+			return nil, fmt.Errorf("%w from %s to %s", ErrNoExchangeRate, from.Currency, to.Currency)
+		}
+		rate, err := c.converter.Rate(from.Currency, to.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("%w from %s to %s: %w", ErrNoExchangeRate, from.Currency, to.Currency, err)
+		}
+		c.rate = rate
+	}
+	c.credited = c.rounding.Round(c.amount.Float64() * c.rate)
+	if c.credited == invalidMoney {
+		return nil, fmt.Errorf("%w: rate %v from %s to %s", ErrInvalidAmount, c.rate, from.Currency, to.Currency)
+	}
+	c.buildLegs()
+	return c, nil
+}
+
+func newMoneyTransferCommand(from, to *BankAccount, amount Money) *MoneyTransferCommand {
+	c := &MoneyTransferCommand{from: from, to: to, amount: amount, rate: 1, credited: amount}
+	c.id = newCommandID()
+	c.buildLegs()
+	return c
+}
+
+func (c *MoneyTransferCommand) buildLegs() {
+	c.commands = []Command{
+		newBankAccountCommand(c.from, Withdraw, c.amount),
+		newBankAccountCommand(c.to, Deposit, c.credited),
+	}
+	if c.fee > 0 {
+		c.commands = append(c.commands, newBankAccountCommand(c.from, Withdraw, c.fee))
+	}
+}
+
+// checkAccounts returns ErrNilAccount unless the transfer has both its
+// accounts and its legs. Only a transfer that hasn't been re-linked after
+// decoding, or a zero value, lacks them.
+func (c *MoneyTransferCommand) checkAccounts() error {
+	if c.from == nil || c.to == nil || len(c.commands) < 2 {
+		return ErrNilAccount
+	}
+	return nil
+}
+
+// Fee returns the fee charged on top of the transferred amount.
+func (c *MoneyTransferCommand) Fee() float64 {
+	return c.fee.Float64()
+}
+
+// Call prepares every leg and commits them only if all were prepared, with
+// both accounts locked throughout. If a leg can't be prepared, the legs
+// prepared before it are rolled back, so nothing is ever applied and then
+// undone; the failing leg keeps its error. A transfer missing an account,
+// such as one decoded with GobDecode and never re-linked, fails with
+// ErrNilAccount.
 func (c *MoneyTransferCommand) Call() {
-	succeded := true
+	if c.refusedErr = c.checkAccounts(); c.refusedErr != nil {
+		return
+	}
+	if account, err := runBeforeHooks(c, c.from, c.to); err != nil {
+		if c.veto(account, err) {
+			c.notifyLegs()
+		}
+		return
+	}
+	unlock := lockAccounts(c.from, c.to)
+	applied := make([]bool, len(c.commands))
+	for i, cmd := range c.commands {
+		applied[i] = cmd.(*BankAccountCommand).executed
+	}
+	if c.prepareLocked() == nil {
+		c.commitLocked()
+	}
+	unlock()
+	runAfterHooks(c, c.from, c.to)
+	for i, cmd := range c.commands {
+		if !applied[i] {
+			cmd.(*BankAccountCommand).notifyOutcome()
+		}
+	}
+}
+
+func (c *MoneyTransferCommand) Prepare() error {
+	if err := c.checkAccounts(); err != nil {
+		return err
+	}
+	unlock := lockAccounts(c.from, c.to)
+	defer unlock()
+	return c.prepareLocked()
+}
+
+func (c *MoneyTransferCommand) Commit() {
+	if c.checkAccounts() != nil {
+		return
+	}
+	unlock := lockAccounts(c.from, c.to)
+	defer unlock()
+	c.commitLocked()
+}
+
+func (c *MoneyTransferCommand) Rollback() {
+	if c.checkAccounts() != nil {
+		return
+	}
+	unlock := lockAccounts(c.from, c.to)
+	defer unlock()
+	for i := len(c.commands) - 1; i >= 0; i-- {
+		c.commands[i].(*BankAccountCommand).rollbackLocked()
+	}
+}
+
+func (c *MoneyTransferCommand) prepareLocked() error {
+	for i, cmd := range c.commands {
+		leg := cmd.(*BankAccountCommand)
+		if err := leg.prepareLocked(); err == nil || errors.Is(err, ErrAlreadyExecuted) {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			c.commands[j].(*BankAccountCommand).rollbackLocked()
+		}
+		for _, skipped := range c.commands[i+1:] {
+			skipped.(*BankAccountCommand).err = nil
+			skipped.SetSucceeded(false)
+		}
+		return leg.err
+	}
+	return nil
+}
+
+func (c *MoneyTransferCommand) commitLocked() {
 	for _, cmd := range c.commands {
-		if succeded {
-			cmd.Call()
-			succeded = cmd.Succeeded()
-		} else {
-			cmd.SetSucceeded(false)
+		cmd.(*BankAccountCommand).commitLocked()
+	}
+}
+
+// Rate returns the exchange rate applied to the transfer, 1 when both
+// accounts share a currency.
+func (c *MoneyTransferCommand) Rate() float64 {
+	return c.rate
+}
+
+// AppliedAmount returns what the transfer credited to the destination, in
+// its currency, or 0 while the transfer isn't applied. The credited amount
+// is converted once, at construction, and Undo debits exactly that, so a
+// rate that has changed since can't make an undo cycle gain or lose money.
+func (c *MoneyTransferCommand) AppliedAmount() float64 {
+	if c.checkAccounts() != nil || !c.commands[1].(*BankAccountCommand).executed {
+		return 0
+	}
+	return c.credited.Float64()
+}
+
+// From returns the account the transfer debits.
+func (c *MoneyTransferCommand) From() *BankAccount {
+	return c.from
+}
+
+// To returns the account the transfer credits.
+func (c *MoneyTransferCommand) To() *BankAccount {
+	return c.to
+}
+
+// Reverse returns a transfer of the same amount in the opposite direction,
+// with an extra leg refunding the fee, if one was charged. A converted
+// transfer is reversed at its original rate.
+func (c *MoneyTransferCommand) Reverse() Command {
+	reversal := &MoneyTransferCommand{from: c.to, to: c.from, amount: c.credited, rate: 1 / c.rate, credited: c.amount}
+	reversal.buildLegs()
+	if c.fee > 0 {
+		reversal.commands = append(reversal.commands, newBankAccountCommand(c.from, Deposit, c.fee))
+	}
+	return reversal
+}
+
+func (c *MoneyTransferCommand) Name() string {
+	return "Transfer"
+}
+
+// Describe returns a summary such as "Transfer 300.00 from A to B".
+func (c *MoneyTransferCommand) Describe() string {
+	description := fmt.Sprintf("Transfer %s from %s to %s", c.amount, accountLabel(c.from), accountLabel(c.to))
+	if c.checkAccounts() == nil && c.from.Currency != c.to.Currency {
+		description += fmt.Sprintf(" (%s credited at rate %v)", c.credited, c.rate)
+	}
+	if c.fee > 0 {
+		description += fmt.Sprintf(" (fee %s)", c.fee)
+	}
+	return description
+}
+
+func (c *MoneyTransferCommand) Clone() Command {
+	clone := &MoneyTransferCommand{
+		from:      c.from,
+		to:        c.to,
+		amount:    c.amount,
+		fee:       c.fee,
+		converter: c.converter,
+		rounding:  c.rounding,
+		rate:      c.rate,
+		credited:  c.credited,
+	}
+	clone.commands = cloneAll(c.commands)
+	return clone
+}
+
+// Undo is all-or-nothing like Call: if a leg can't be reversed, the legs
+// already reversed are applied again and the leg's error is returned. A
+// transfer missing an account never ran, so like any command that has
+// nothing to reverse it returns nil.
+func (c *MoneyTransferCommand) Undo() error {
+	if c.checkAccounts() != nil {
+		return nil
+	}
+	unlock := lockAccounts(c.from, c.to)
+	defer unlock()
+	for i := len(c.commands) - 1; i >= 0; i-- {
+		if err := c.commands[i].(*BankAccountCommand).undoLocked(); err != nil {
+			for _, undone := range c.commands[i+1:] {
+				undone.(*BankAccountCommand).callLocked()
+			}
+			return err
 		}
 	}
+	return nil
+}
+
+// ForceUndo is the same as Undo: a transfer is never left half reversed.
+func (c *MoneyTransferCommand) ForceUndo() error {
+	return c.Undo()
+}
+
+type printObserver struct{}
+
+func (printObserver) OnCall(cmd Command) {
+	fmt.Println("  observer: called", cmd.Describe())
+}
+
+func (printObserver) OnUndo(cmd Command) {
+	fmt.Println("  observer: undid", cmd.Describe())
 }
 
 func main() {
+	repl := flag.Bool("repl", false, "read commands from stdin instead of running the examples")
+	locale := flag.String("locale", "", "format amounts for a locale, such as de-DE")
+	flag.Parse()
+	if *locale != "" {
+		formatter, err := NewFormatter(*locale)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		SetDefaultFormatter(formatter)
+	}
+	if *repl {
+		if err := RunCLI(os.Stdin, os.Stdout, NewRegistry(), NewCommandManager()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Simple bank account command example
 	fmt.Println("Simple Bank Account Command Example:")
-	account := &BankAccount{balance: 1000}
-	cmd := &BankAccountCommand{account: account, action: Withdraw, amount: 200}
+	account := NewBankAccount(1000, overdraftLimit)
+	cmd := NewBankAccountCommand(account, Withdraw, 200)
 	cmd.Call()
-	fmt.Println("Account balance:", account.balance)
-	cmd2 := &BankAccountCommand{account: account, action: Deposit, amount: 500}
+	fmt.Println("Account balance:", account.Balance())
+	cmd2 := NewBankAccountCommand(account, Deposit, 500)
 	cmd2.Call()
-	fmt.Println("Account balance after deposit:", account.balance)
+	fmt.Println("Account balance after deposit:", account.Balance())
 	cmd.Undo()
-	fmt.Println("Account balance after undoing withdrawal:", account.balance)
+	fmt.Println("Account balance after undoing withdrawal:", account.Balance())
 	cmd2.Undo()
-	fmt.Println("Account balance after undoing deposit:", account.balance)
-
-	// Money transfer example
-	fmt.Println("\nMoney Transfer Command Example:")
-	accountA := &BankAccount{balance: 1000}
-	accountB := &BankAccount{balance: 500}
-	transferCmd := NewMoneyTransferCommand(accountA, accountB, 300)
-	transferCmd.Call()
-	fmt.Println("Account A balance after transfer:", accountA.balance)
-	fmt.Println("Account B balance after transfer:", accountB.balance)
+	fmt.Println("Account balance after undoing deposit:", account.Balance())
+
+	// Money transfer example, as a transaction
+	fmt.Println("\nMoney Transfer Transaction Example:")
+	accountA := NewBankAccount(1000, overdraftLimit)
+	accountB := NewBankAccount(500, overdraftLimit)
+	tx := Begin()
+	tx.Add(NewBankAccountCommand(accountA, Withdraw, 300))
+	tx.Add(NewBankAccountCommand(accountB, Deposit, 300))
+	txErr := tx.Commit()
+	fmt.Println("Account A balance after transfer:", accountA.Balance())
+	fmt.Println("Account B balance after transfer:", accountB.Balance())
 	// Print whether the transfer succeeded
-	fmt.Println("Did the transfer succeed?", transferCmd.Succeeded())
-	transferCmd.Undo()
-	fmt.Println("Account A balance after undoing transfer:", accountA.balance)
-	fmt.Println("Account B balance after undoing transfer:", accountB.balance)
+	fmt.Println("Did the transfer succeed?", txErr == nil)
+	refundTx := Begin()
+	refundTx.Add(NewBankAccountCommand(accountB, Withdraw, 300))
+	refundTx.Add(NewBankAccountCommand(accountA, Deposit, 300))
+	refundTx.Add(NewBankAccountCommand(accountA, Withdraw, 5000))
+	fmt.Println("Refund with an overdrawing step:", refundTx.Commit())
+	fmt.Println("Account A balance after the aborted refund:", accountA.Balance())
+	fmt.Println("Account B balance after the aborted refund:", accountB.Balance())
+	refundTx = Begin()
+	refundTx.Add(NewBankAccountCommand(accountB, Withdraw, 300))
+	refundTx.Add(NewBankAccountCommand(accountA, Deposit, 300))
+	refundTx.Commit()
+	fmt.Println("Account A balance after the refund:", accountA.Balance())
+	fmt.Println("Account B balance after the refund:", accountB.Balance())
 
 	// Composite command example exceeding overdraft limit
 	fmt.Println("\nComposite Command Exceeding Overdraft Limit Example:")
-	largeTransferCmd := NewMoneyTransferCommand(accountA, accountB, 2000)
+	largeTransferCmd, _ := NewMoneyTransferCommand(accountA, accountB, 2000)
 	largeTransferCmd.Call()
-	fmt.Println("Account A balance after large transfer attempt:", accountA.balance)
-	fmt.Println("Account B balance after large transfer attempt:", accountB.balance)
+	fmt.Println("Account A balance after large transfer attempt:", accountA.Balance())
+	fmt.Println("Account B balance after large transfer attempt:", accountB.Balance())
 	// Print whether the large transfer succeeded
 	fmt.Println("Did the large transfer succeed?", largeTransferCmd.Succeeded())
+	fmt.Println("Large transfer error:", largeTransferCmd.Err())
 	largeTransferCmd.Undo()
-	fmt.Println("Account A balance after undoing large transfer attempt:", accountA.balance)
-	fmt.Println("Account B balance after undoing large transfer attempt:", accountB.balance)
+	fmt.Println("Account A balance after undoing large transfer attempt:", accountA.Balance())
+	fmt.Println("Account B balance after undoing large transfer attempt:", accountB.Balance())
+
+	// Command manager undo/redo example
+	fmt.Println("\nCommand Manager Undo/Redo Example:")
+	manager := NewCommandManager()
+	manager.AddObserver(printObserver{})
+	manager.Execute(NewBankAccountCommand(account, Deposit, 100))
+	smallTransfer, _ := NewMoneyTransferCommand(account, accountA, 50)
+	manager.Execute(smallTransfer)
+	fmt.Println("Account balance after deposit and transfer:", account.Balance())
+	manager.Undo()
+	fmt.Println("Account balance after undoing the transfer:", account.Balance())
+	manager.Undo()
+	fmt.Println("Account balance after undoing the deposit:", account.Balance())
+	manager.Redo()
+	fmt.Println("Account balance after redoing the deposit:", account.Balance())
+
+	// Scenario example
+	fmt.Println("\nScenario Example:")
+	for _, scenario := range exampleScenarios {
+		fmt.Printf("%s: %v\n", scenario.Name, Run(scenario))
+	}
 }
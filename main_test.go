@@ -0,0 +1,588 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentDepositsAndWithdrawals(t *testing.T) {
+	account := NewBankAccount(1000, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			account.Deposit(10)
+		}()
+		go func() {
+			defer wg.Done()
+			account.Withdraw(10)
+		}()
+	}
+	wg.Wait()
+	if got := account.Balance(); got != 1000 {
+		t.Fatalf("balance = %v, want 1000", got)
+	}
+}
+
+func TestConcurrentTransfersConserveMoney(t *testing.T) {
+	a := NewBankAccount(1000, 0)
+	b := NewBankAccount(1000, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		from, to := a, b
+		if i%2 == 1 {
+			from, to = b, a
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transfer, err := NewMoneyTransferCommand(from, to, 10)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			transfer.Call()
+			if !transfer.Succeeded() {
+				t.Errorf("transfer failed: %v", transfer.Err())
+			}
+		}()
+	}
+	wg.Wait()
+	if total := a.Balance() + b.Balance(); total != 2000 {
+		t.Fatalf("total = %v, want 2000", total)
+	}
+}
+
+// Transfers in opposite directions take the two locks in the same order, so
+// they finish instead of deadlocking.
+func TestOppositeTransfersDoNotDeadlock(t *testing.T) {
+	a := NewBankAccount(1_000_000, 0)
+	b := NewBankAccount(1_000_000, 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for _, pair := range [][2]*BankAccount{{a, b}, {b, a}} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 1000 {
+					transfer, _ := NewMoneyTransferCommand(pair[0], pair[1], 1)
+					transfer.Call()
+					transfer.Undo()
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("transfers in opposite directions deadlocked")
+	}
+	if a.Balance() != 1_000_000 || b.Balance() != 1_000_000 {
+		t.Fatalf("balances = %v, %v, want 1000000 each", a.Balance(), b.Balance())
+	}
+}
+
+func TestLockAccountsOrdersBySequence(t *testing.T) {
+	first := NewBankAccount(0, 0)
+	second := NewBankAccount(0, 0)
+	literal := &BankAccount{}
+	if first.lockOrder() >= second.lockOrder() {
+		t.Fatalf("lockOrder %d, %d: want accounts ordered by creation", first.lockOrder(), second.lockOrder())
+	}
+	if literal.lockOrder() == 0 || literal.lockOrder() != literal.lockOrder() {
+		t.Fatalf("literal account lockOrder = %d, want a stable non-zero key", literal.lockOrder())
+	}
+	unlock := lockAccounts(second, first, second)
+	unlock()
+}
+
+func TestTransferLegFailureRestoresBothBalances(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(from, to *BankAccount)
+		amount float64
+		fee    float64
+		want   error
+	}{
+		{"withdrawal over the overdraft limit", func(from, to *BankAccount) {}, 150, 0, ErrOverdraftExceeded},
+		{"frozen destination", func(from, to *BankAccount) { to.Freeze() }, 100, 0, ErrAccountFrozen},
+		{"deposit over the destination's cap", func(from, to *BankAccount) { to.SetMaxTransactionAmount(50) }, 100, 0, ErrAmountTooLarge},
+		{"fee overdraws the source", func(from, to *BankAccount) {}, 100, 1, ErrOverdraftExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := NewBankAccount(100, 0)
+			to := NewBankAccount(20, 0)
+			tt.setup(from, to)
+			transfer, err := NewMoneyTransferCommand(from, to, tt.amount, WithFee(tt.fee))
+			if err != nil {
+				t.Fatal(err)
+			}
+			transfer.Call()
+			if transfer.Succeeded() || !errors.Is(transfer.Err(), tt.want) {
+				t.Fatalf("Call: succeeded %v, err %v; want %v", transfer.Succeeded(), transfer.Err(), tt.want)
+			}
+			if from.Balance() != 100 || to.Balance() != 20 {
+				t.Fatalf("after Call: balances %v, %v; want 100, 20", from.Balance(), to.Balance())
+			}
+			if err := transfer.Undo(); err != nil {
+				t.Fatalf("Undo: %v", err)
+			}
+			if from.Balance() != 100 || to.Balance() != 20 {
+				t.Fatalf("after Undo: balances %v, %v; want 100, 20", from.Balance(), to.Balance())
+			}
+			if len(to.History()) != 0 {
+				t.Fatalf("destination history = %v, want no changes", to.History())
+			}
+		})
+	}
+}
+
+func TestNegativeAmountsFail(t *testing.T) {
+	for _, action := range []Action{Deposit, Withdraw, Authorize, Capture, Release, Fee, WithdrawUpTo} {
+		t.Run(action.String(), func(t *testing.T) {
+			account := NewBankAccount(100, 0)
+			cmd := NewBankAccountCommand(account, action, -10)
+			cmd.Call()
+			if cmd.Succeeded() || !errors.Is(cmd.Err(), ErrNegativeAmount) {
+				t.Fatalf("succeeded %v, err %v; want ErrNegativeAmount", cmd.Succeeded(), cmd.Err())
+			}
+			if account.Balance() != 100 || account.Held() != 0 {
+				t.Fatalf("balance %v, held %v; want 100, 0", account.Balance(), account.Held())
+			}
+		})
+	}
+}
+
+func TestNegativeTransferRejectedAtConstruction(t *testing.T) {
+	from, to := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	for _, tt := range []struct {
+		name        string
+		amount, fee float64
+	}{
+		{"amount", -10, 0},
+		{"fee", 10, -1},
+	} {
+		if transfer, err := NewMoneyTransferCommand(from, to, tt.amount, WithFee(tt.fee)); transfer != nil || !errors.Is(err, ErrNegativeAmount) {
+			t.Errorf("negative %s: got %v, %v; want ErrNegativeAmount", tt.name, transfer, err)
+		}
+	}
+}
+
+// By default a zero amount is a no-op that succeeds; RejectZeroAmounts
+// makes it an error instead.
+func TestZeroAmountIsSuccessfulNoOp(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	for _, action := range []Action{Deposit, Withdraw} {
+		cmd := NewBankAccountCommand(account, action, 0)
+		cmd.Call()
+		if !cmd.Succeeded() || cmd.Err() != nil {
+			t.Fatalf("%s 0: succeeded %v, err %v; want success", action, cmd.Succeeded(), cmd.Err())
+		}
+	}
+	transfer, err := NewMoneyTransferCommand(account, NewBankAccount(0, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfer.Call()
+	if !transfer.Succeeded() || account.Balance() != 100 {
+		t.Fatalf("zero transfer: succeeded %v, balance %v; want success, 100", transfer.Succeeded(), account.Balance())
+	}
+}
+
+func TestFrozenAccountRejectsChanges(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(account *BankAccount) error
+	}{
+		{"Deposit", func(account *BankAccount) error { return account.Deposit(10) }},
+		{"Withdraw", func(account *BankAccount) error { return account.Withdraw(10) }},
+		{"deposit command", func(account *BankAccount) error {
+			cmd := NewBankAccountCommand(account, Deposit, 10)
+			cmd.Call()
+			if cmd.Succeeded() {
+				return nil
+			}
+			return cmd.Err()
+		}},
+		{"withdraw command", func(account *BankAccount) error {
+			cmd := NewBankAccountCommand(account, Withdraw, 10)
+			cmd.Call()
+			if cmd.Succeeded() {
+				return nil
+			}
+			return cmd.Err()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := NewBankAccount(100, 0)
+			account.Freeze()
+			if err := tt.run(account); !errors.Is(err, ErrAccountFrozen) {
+				t.Fatalf("frozen: err %v, want ErrAccountFrozen", err)
+			}
+			if account.Balance() != 100 {
+				t.Fatalf("balance %v, want 100", account.Balance())
+			}
+			account.Unfreeze()
+			if err := tt.run(account); err != nil {
+				t.Fatalf("after Unfreeze: %v", err)
+			}
+		})
+	}
+}
+
+func TestTransferToFrozenAccountLeavesSourceAlone(t *testing.T) {
+	from, to := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	to.Freeze()
+	transfer, _ := NewMoneyTransferCommand(from, to, 40)
+	transfer.Call()
+	if transfer.Succeeded() || !errors.Is(transfer.Err(), ErrAccountFrozen) {
+		t.Fatalf("succeeded %v, err %v; want ErrAccountFrozen", transfer.Succeeded(), transfer.Err())
+	}
+	if from.Balance() != 100 || to.Balance() != 0 {
+		t.Fatalf("balances %v, %v; want 100, 0", from.Balance(), to.Balance())
+	}
+}
+
+// The last account is frozen while the composite runs, after the legs
+// before it have been applied; those legs must be rolled back.
+func TestFreezeMidCompositeRollsBack(t *testing.T) {
+	a, b, c := NewBankAccount(0, 0), NewBankAccount(0, 0), NewBankAccount(0, 0)
+	second := NewBankAccountCommand(b, Deposit, 20)
+	second.OnSuccess(func(Command) { c.Freeze() })
+	composite := NewCompositeCommand(true,
+		NewBankAccountCommand(a, Deposit, 10),
+		second,
+		NewBankAccountCommand(c, Deposit, 30),
+	)
+	composite.Call()
+	if composite.Succeeded() {
+		t.Fatal("composite succeeded on a frozen account")
+	}
+	if a.Balance() != 0 || b.Balance() != 0 || c.Balance() != 0 {
+		t.Fatalf("balances %v, %v, %v; want all 0", a.Balance(), b.Balance(), c.Balance())
+	}
+	if composite.CanUndo() {
+		t.Fatal("rolled-back composite still has something to undo")
+	}
+}
+
+// nested builds a composite three levels deep with a leaf at every level,
+// and returns it with its leaves in the order they run.
+func nested(account *BankAccount, last float64) (*CompositeBankAccountCommand, []Command) {
+	leaves := []Command{
+		NewBankAccountCommand(account, Deposit, 1),
+		NewBankAccountCommand(account, Deposit, 2),
+		NewBankAccountCommand(account, Deposit, 3),
+		NewBankAccountCommand(account, Withdraw, last),
+	}
+	innermost := NewCompositeCommand(true, leaves[2], leaves[3])
+	middle := NewCompositeCommand(true, leaves[1], innermost)
+	return NewCompositeCommand(true, leaves[0], middle), leaves
+}
+
+func TestNestedCompositeFlatten(t *testing.T) {
+	root, leaves := nested(NewBankAccount(0, 0), 4)
+	flat := root.Flatten()
+	if len(flat) != len(leaves) {
+		t.Fatalf("Flatten returned %d commands, want %d", len(flat), len(leaves))
+	}
+	for i := range flat {
+		if flat[i] != leaves[i] {
+			t.Fatalf("Flatten()[%d] = %s, want %s", i, flat[i].Describe(), leaves[i].Describe())
+		}
+	}
+}
+
+func TestNestedCompositeSucceededAndUndo(t *testing.T) {
+	account := NewBankAccount(0, 0)
+	root, leaves := nested(account, 4)
+	root.Call()
+	if !root.Succeeded() || account.Balance() != 2 {
+		t.Fatalf("succeeded %v, balance %v; want true, 2", root.Succeeded(), account.Balance())
+	}
+	root.SetSucceeded(false)
+	for i, leaf := range leaves {
+		if leaf.Succeeded() {
+			t.Fatalf("SetSucceeded(false) didn't reach leaf %d", i)
+		}
+	}
+	root.SetSucceeded(true)
+	if err := root.Undo(); err != nil || account.Balance() != 0 {
+		t.Fatalf("Undo: %v, balance %v; want nil, 0", err, account.Balance())
+	}
+}
+
+// A failure in the innermost composite is seen at the top and rolls back
+// the legs applied at every level.
+func TestNestedCompositeInnermostFailure(t *testing.T) {
+	account := NewBankAccount(0, 0)
+	root, _ := nested(account, 100)
+	root.Call()
+	if root.Succeeded() {
+		t.Fatal("root succeeded though its innermost leg failed")
+	}
+	if !errors.Is(root.Err(), ErrOverdraftExceeded) {
+		t.Fatalf("Err = %v, want ErrOverdraftExceeded", root.Err())
+	}
+	if account.Balance() != 0 {
+		t.Fatalf("balance %v, want 0 after rollback", account.Balance())
+	}
+}
+
+func TestOneTimeOverdraft(t *testing.T) {
+	account := NewBankAccount(100, -50)
+	without := NewBankAccountCommand(account, Withdraw, 200)
+	without.Call()
+	if without.Succeeded() || !errors.Is(without.Err(), ErrOverdraftExceeded) {
+		t.Fatalf("without the flag: succeeded %v, err %v; want ErrOverdraftExceeded", without.Succeeded(), without.Err())
+	}
+
+	with := NewBankAccountCommand(account, Withdraw, 200)
+	with.SetAllowOneTimeOverdraft(true)
+	log := NewTransactionLog()
+	with.Call()
+	log.Record(with)
+	if !with.Succeeded() || account.Balance() != -100 {
+		t.Fatalf("with the flag: succeeded %v, balance %v; want true, -100", with.Succeeded(), account.Balance())
+	}
+	if !with.OverdraftExceptionUsed() {
+		t.Fatal("OverdraftExceptionUsed = false, want true")
+	}
+	if entries := log.Entries(); len(entries) != 1 || !entries[0].OverdraftException {
+		t.Fatalf("log entries %+v, want one marked OverdraftException", entries)
+	}
+
+	// The standing limit is unchanged: the next withdrawal without the flag
+	// is still held to it.
+	account.Deposit(200)
+	again := NewBankAccountCommand(account, Withdraw, 200)
+	again.Call()
+	if again.Succeeded() {
+		t.Fatal("withdrawal past the standing limit succeeded without the flag")
+	}
+}
+
+func TestOneTimeOverdraftUnusedWithinLimit(t *testing.T) {
+	account := NewBankAccount(100, -50)
+	cmd := NewBankAccountCommand(account, Withdraw, 120)
+	cmd.SetAllowOneTimeOverdraft(true)
+	cmd.Call()
+	if !cmd.Succeeded() || cmd.OverdraftExceptionUsed() {
+		t.Fatalf("succeeded %v, exception used %v; want true, false", cmd.Succeeded(), cmd.OverdraftExceptionUsed())
+	}
+}
+
+func TestSelfTransferRejected(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	account.ID = "self"
+	reg := NewRegistry()
+	reg.Add(account)
+	tests := []struct {
+		name  string
+		build func() error
+	}{
+		{"NewMoneyTransferCommand", func() error {
+			_, err := NewMoneyTransferCommand(account, account, 10)
+			return err
+		}},
+		{"Registry.Transfer", func() error {
+			_, err := reg.Transfer("self", "self", 10, "")
+			return err
+		}},
+		{"BuildCommand", func() error {
+			_, err := BuildCommand(CommandSpec{Action: TransferAction, Amount: 1000, From: "self", To: "self"}, reg)
+			return err
+		}},
+		{"CommandBuilder", func() error {
+			_, err := NewCommandBuilder().Transfer(account, account, 10).Build()
+			return err
+		}},
+		{"NewSplitTransferCommand", func() error {
+			_, err := NewSplitTransferCommand(account, map[*BankAccount]float64{account: 10, NewBankAccount(0, 0): 5})
+			return err
+		}},
+		{"NewCollectCommand", func() error {
+			_, err := NewCollectCommand(account, map[*BankAccount]float64{account: 10, NewBankAccount(50, 0): 5})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.build(); !errors.Is(err, ErrSelfTransfer) {
+				t.Fatalf("err %v, want ErrSelfTransfer", err)
+			}
+		})
+	}
+	if account.Balance() != 100 || len(account.History()) != 0 {
+		t.Fatalf("balance %v, history %v; want the account untouched", account.Balance(), account.History())
+	}
+}
+
+func rejectZeroAmounts(t *testing.T) {
+	t.Helper()
+	RejectZeroAmounts = true
+	t.Cleanup(func() { RejectZeroAmounts = false })
+}
+
+func TestRejectZeroAmounts(t *testing.T) {
+	rejectZeroAmounts(t)
+	for _, action := range []Action{Deposit, Withdraw, WithdrawUpTo, Authorize, Capture, Release, Fee} {
+		t.Run(action.String(), func(t *testing.T) {
+			account := NewBankAccount(100, 0)
+			cmd := NewBankAccountCommand(account, action, 0)
+			if err := cmd.Validate(); !errors.Is(err, ErrZeroAmount) {
+				t.Fatalf("Validate = %v, want ErrZeroAmount", err)
+			}
+			cmd.Call()
+			if cmd.Succeeded() || !errors.Is(cmd.Err(), ErrZeroAmount) {
+				t.Fatalf("succeeded %v, err %v; want ErrZeroAmount", cmd.Succeeded(), cmd.Err())
+			}
+		})
+	}
+	if _, err := NewMoneyTransferCommand(NewBankAccount(100, 0), NewBankAccount(0, 0), 0); !errors.Is(err, ErrZeroAmount) {
+		t.Fatalf("zero transfer: %v, want ErrZeroAmount", err)
+	}
+}
+
+// Commands whose amount the caller doesn't give are exempt.
+func TestRejectZeroAmountsExemptions(t *testing.T) {
+	rejectZeroAmounts(t)
+	for _, action := range []Action{BalanceInquiry, WithdrawAll} {
+		cmd := NewBankAccountCommand(NewBankAccount(100, 0), action, 0)
+		cmd.Call()
+		if !cmd.Succeeded() {
+			t.Errorf("%s: %v, want success", action, cmd.Err())
+		}
+	}
+}
+
+func TestCompositeWithZeroLeg(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%v", strict), func(t *testing.T) {
+			if strict {
+				rejectZeroAmounts(t)
+			}
+			a, b := NewBankAccount(100, 0), NewBankAccount(0, 0)
+			composite := NewCompositeCommand(true, NewBankAccountCommand(a, Withdraw, 10), NewBankAccountCommand(b, Deposit, 0))
+			composite.Call()
+			if strict {
+				if composite.Succeeded() || !errors.Is(composite.Err(), ErrZeroAmount) || a.Balance() != 100 {
+					t.Fatalf("succeeded %v, err %v, balance %v; want ErrZeroAmount and a rollback", composite.Succeeded(), composite.Err(), a.Balance())
+				}
+				return
+			}
+			if !composite.Succeeded() || a.Balance() != 90 || b.Balance() != 0 {
+				t.Fatalf("succeeded %v, balances %v, %v; want true, 90, 0", composite.Succeeded(), a.Balance(), b.Balance())
+			}
+		})
+	}
+}
+
+func TestDailyWithdrawLimit(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	account := NewBankAccount(1000, 0)
+	account.Clock = clock
+	account.SetDailyWithdrawLimit(100)
+	first := NewBankAccountCommand(account, Withdraw, 70)
+	if err := call(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(NewBankAccountCommand(account, Withdraw, 40)); !errors.Is(err, ErrDailyLimitExceeded) {
+		t.Fatalf("withdrawal past the day's total = %v, want ErrDailyLimitExceeded", err)
+	}
+	if err := first.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(NewBankAccountCommand(account, Withdraw, 100)); err != nil {
+		t.Fatalf("withdrawal after undoing the first: %v", err)
+	}
+}
+
+// Undoing a deposit isn't a withdrawal: the daily limit neither refuses it
+// nor counts it.
+func TestUndoDepositIgnoresDailyLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		deposit float64
+		then    float64
+	}{
+		{"deposit above the limit", 500, 100},
+		{"undo doesn't use up the allowance", 50, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := NewBankAccount(100, 0)
+			account.SetDailyWithdrawLimit(100)
+			deposit := NewBankAccountCommand(account, Deposit, tt.deposit)
+			if err := call(deposit); err != nil {
+				t.Fatal(err)
+			}
+			if err := deposit.Undo(); err != nil {
+				t.Fatalf("Undo = %v, want nil", err)
+			}
+			if account.Balance() != 100 {
+				t.Fatalf("balance %v after Undo, want 100", account.Balance())
+			}
+			if err := call(NewBankAccountCommand(account, Withdraw, tt.then)); err != nil {
+				t.Fatalf("withdrawal of %v after the undo: %v", tt.then, err)
+			}
+		})
+	}
+}
+
+// ForceUndo carries on past a failure inside a nested composite, where the
+// nested composite's own Undo would stop.
+func TestForceUndoNestedComposite(t *testing.T) {
+	a, b := NewBankAccount(0, 0), NewBankAccount(0, 0)
+	inner := NewCompositeCommand(false,
+		NewBankAccountCommand(b, Deposit, 10),
+		NewBankAccountCommand(a, Deposit, 50),
+	)
+	outer := NewCompositeCommand(false, NewBankAccountCommand(b, Deposit, 5), inner)
+	outer.Call()
+	if !outer.Succeeded() {
+		t.Fatal(outer.Err())
+	}
+	if err := call(NewBankAccountCommand(a, Withdraw, 50)); err != nil {
+		t.Fatal(err)
+	}
+	if err := outer.ForceUndo(); !errors.Is(err, ErrOverdraftExceeded) {
+		t.Fatalf("ForceUndo = %v, want ErrOverdraftExceeded", err)
+	}
+	if b.Balance() != 0 {
+		t.Fatalf("balance %v after ForceUndo, want 0: the nested deposit before the failure wasn't reversed", b.Balance())
+	}
+}
+
+// A transaction cap lowered after a command ran doesn't stop it being
+// undone, though it still refuses new commands of the same size.
+func TestUndoIgnoresLoweredTransactionCap(t *testing.T) {
+	for _, action := range []Action{Deposit, Withdraw} {
+		t.Run(action.String(), func(t *testing.T) {
+			account := NewBankAccount(1000, 0)
+			cmd := NewBankAccountCommand(account, action, 500)
+			if err := call(cmd); err != nil {
+				t.Fatal(err)
+			}
+			account.SetMaxTransactionAmount(100)
+			if err := cmd.Undo(); err != nil {
+				t.Fatalf("Undo = %v, want nil", err)
+			}
+			if account.Balance() != 1000 {
+				t.Fatalf("balance %v after Undo, want 1000", account.Balance())
+			}
+			if err := call(NewBankAccountCommand(account, action, 500)); !errors.Is(err, ErrAmountTooLarge) {
+				t.Fatalf("new %s over the cap = %v, want ErrAmountTooLarge", action, err)
+			}
+		})
+	}
+}
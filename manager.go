@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+	ErrRedoFailed    = errors.New("redo failed")
+)
+
+// Observer is notified synchronously after a CommandManager successfully
+// calls or undoes a command.
+type Observer interface {
+	OnCall(cmd Command)
+	OnUndo(cmd Command)
+}
+
+// CommandManager keeps a history of executed commands so the most recent
+// one can be undone and redone without holding a reference to it. It is not
+// safe for concurrent use. RateLimiter and DedupExecutor serialize the calls
+// they make into it, with each other as well, but not with calls made on the
+// manager directly: once one of them wraps a manager, send every command
+// through a wrapper.
+type CommandManager struct {
+	undoStack []Command
+	redoStack []Command
+	observers []Observer
+	log       *TransactionLog
+	metrics   *Metrics
+	guard     *conservationGuard
+	events    chan CommandEvent
+	dropped   int
+	closed    bool
+
+	// execMu is held by the executors wrapping the manager while they call
+	// Execute, see executeSerialized.
+	execMu sync.Mutex
+}
+
+type conservationGuard struct {
+	accounts    []*BankAccount
+	onViolation func(error)
+}
+
+func NewCommandManager() *CommandManager {
+	return &CommandManager{}
+}
+
+func (m *CommandManager) AddObserver(o Observer) {
+	m.observers = append(m.observers, o)
+}
+
+// SetTransactionLog makes Execute and Redo record every command they call
+// in log.
+func (m *CommandManager) SetTransactionLog(log *TransactionLog) {
+	m.log = log
+}
+
+// GuardConservation checks after every Execute that the total balance of
+// accounts hasn't changed, which must hold when only transfers without fees
+// or interest run between them. A violation is passed to onViolation, or
+// causes a panic if onViolation is nil.
+func (m *CommandManager) GuardConservation(onViolation func(error), accounts ...*BankAccount) {
+	m.guard = &conservationGuard{accounts: accounts, onViolation: onViolation}
+}
+
+// Execute calls cmd and records it for Undo. Executing a new command
+// discards anything that could have been redone.
+func (m *CommandManager) Execute(cmd Command) {
+	var before float64
+	if m.guard != nil {
+		before = TotalBalance(m.guard.accounts...)
+	}
+	m.call(cmd)
+	if m.guard != nil {
+		if err := AssertConserved(before, TotalBalance(m.guard.accounts...)); err != nil {
+			if m.guard.onViolation == nil {
+				panic(err)
+			}
+			m.guard.onViolation(err)
+		}
+	}
+	m.undoStack = append(m.undoStack, cmd)
+	m.redoStack = nil
+	m.emit(EventExecuted, cmd)
+	m.notifyCall(cmd)
+}
+
+// Undo undoes the most recent command. If the command reports an error it
+// stays on the undo stack and the error is returned.
+func (m *CommandManager) Undo() error {
+	if len(m.undoStack) == 0 {
+		return ErrNothingToUndo
+	}
+	cmd := m.undoStack[len(m.undoStack)-1]
+	succeeded := cmd.Succeeded()
+	// A command with nothing to reverse, such as one that failed, just
+	// moves to the redo stack.
+	if cmd.CanUndo() {
+		if err := cmd.Undo(); err != nil {
+			return err
+		}
+	}
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+	m.redoStack = append(m.redoStack, cmd)
+	m.emit(EventUndone, cmd)
+	if succeeded {
+		for _, o := range m.observers {
+			o.OnUndo(cmd)
+		}
+	}
+	return nil
+}
+
+// Redo calls the most recently undone command again. Success is decided by
+// that new call against the current balances, not by how the command fared
+// before, so a redo that no longer fits, say because the account has been
+// drawn down since the undo, returns an error wrapping ErrRedoFailed and the
+// command's own error. Like a failed Execute it still moves to the undo
+// stack, where undoing it changes nothing.
+func (m *CommandManager) Redo() error {
+	if len(m.redoStack) == 0 {
+		return ErrNothingToRedo
+	}
+	cmd := m.redoStack[len(m.redoStack)-1]
+	m.redoStack = m.redoStack[:len(m.redoStack)-1]
+	m.call(cmd)
+	m.undoStack = append(m.undoStack, cmd)
+	m.emit(EventRedone, cmd)
+	if !cmd.Succeeded() {
+		if err := cmd.Err(); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrRedoFailed, cmd.Describe(), err)
+		}
+		return fmt.Errorf("%w: %s", ErrRedoFailed, cmd.Describe())
+	}
+	m.notifyCall(cmd)
+	return nil
+}
+
+// executeSerialized is Execute for the executors wrapping the manager. They
+// all take the same lock, so a RateLimiter and a DedupExecutor can share a
+// manager.
+func (m *CommandManager) executeSerialized(cmd Command) {
+	m.execMu.Lock()
+	defer m.execMu.Unlock()
+	m.Execute(cmd)
+}
+
+func (m *CommandManager) call(cmd Command) {
+	start := time.Now()
+	cmd.Call()
+	if m.metrics != nil {
+		m.metrics.Record(cmd, time.Since(start))
+	}
+	if m.log != nil {
+		m.log.Record(cmd)
+	}
+}
+
+func (m *CommandManager) notifyCall(cmd Command) {
+	if !cmd.Succeeded() {
+		return
+	}
+	for _, o := range m.observers {
+		o.OnCall(cmd)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEmptyHistory(t *testing.T) {
+	m := NewCommandManager()
+	if err := m.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undo = %v, want ErrNothingToUndo", err)
+	}
+	if err := m.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Redo = %v, want ErrNothingToRedo", err)
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	m := NewCommandManager()
+	m.Execute(NewBankAccountCommand(account, Deposit, 50))
+	if err := m.Undo(); err != nil || account.Balance() != 100 {
+		t.Fatalf("Undo: %v, balance %v; want nil, 100", err, account.Balance())
+	}
+	if err := m.Redo(); err != nil || account.Balance() != 150 {
+		t.Fatalf("Redo: %v, balance %v; want nil, 150", err, account.Balance())
+	}
+	m.Execute(NewBankAccountCommand(account, Withdraw, 10))
+	if err := m.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Fatalf("Redo after Execute = %v, want the redo stack cleared", err)
+	}
+}
+
+// The balance drops between the undo and the redo, so the redo no longer
+// fits and must fail without touching the account.
+func TestRedoJudgedAgainstCurrentBalance(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	m := NewCommandManager()
+	withdrawal := NewBankAccountCommand(account, Withdraw, 80)
+	m.Execute(withdrawal)
+	if err := m.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := account.Withdraw(50); err != nil {
+		t.Fatal(err)
+	}
+	err := m.Redo()
+	if !errors.Is(err, ErrRedoFailed) || !errors.Is(err, ErrOverdraftExceeded) {
+		t.Fatalf("Redo = %v, want ErrRedoFailed wrapping ErrOverdraftExceeded", err)
+	}
+	if withdrawal.Succeeded() {
+		t.Fatal("failed redo reports success")
+	}
+	if account.Balance() != 50 {
+		t.Fatalf("balance %v, want 50", account.Balance())
+	}
+	// The failed redo sits on the undo stack, where undoing it changes
+	// nothing.
+	if err := m.Undo(); err != nil || account.Balance() != 50 {
+		t.Fatalf("Undo of failed redo: %v, balance %v; want nil, 50", err, account.Balance())
+	}
+}
+
+func TestRedoOfFailedCommandIsReevaluated(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	m := NewCommandManager()
+	withdrawal := NewBankAccountCommand(account, Withdraw, 150)
+	m.Execute(withdrawal)
+	if withdrawal.Succeeded() {
+		t.Fatal("overdrawing withdrawal succeeded")
+	}
+	m.Undo()
+	account.Deposit(100)
+	if err := m.Redo(); err != nil {
+		t.Fatalf("Redo = %v, want success now the funds are there", err)
+	}
+	if !withdrawal.Succeeded() || account.Balance() != 50 {
+		t.Fatalf("succeeded %v, balance %v; want true, 50", withdrawal.Succeeded(), account.Balance())
+	}
+}
+
+func TestUndoErrorKeepsCommandOnStack(t *testing.T) {
+	account := NewBankAccount(0, 0)
+	m := NewCommandManager()
+	m.Execute(NewBankAccountCommand(account, Deposit, 100))
+	account.Withdraw(100)
+	if err := m.Undo(); !errors.Is(err, ErrOverdraftExceeded) {
+		t.Fatalf("Undo = %v, want ErrOverdraftExceeded", err)
+	}
+	account.Deposit(100)
+	if err := m.Undo(); err != nil || account.Balance() != 0 {
+		t.Fatalf("second Undo: %v, balance %v; want nil, 0", err, account.Balance())
+	}
+}
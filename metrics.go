@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics aggregates how many commands ran and how long they took, keyed by
+// command name (the action for bank account commands, "Transfer",
+// "Composite" and so on otherwise). It is safe for concurrent use.
+type Metrics struct {
+	mu            sync.Mutex
+	slowThreshold time.Duration
+	byName        map[string]*ActionMetrics
+}
+
+// ActionMetrics are the totals for one kind of command. A call counts as
+// slow when it took longer than the collector's slow threshold.
+type ActionMetrics struct {
+	Count           int
+	Succeeded       int
+	Failed          int
+	Slow            int
+	TotalDuration   time.Duration
+	MaxDuration     time.Duration
+	AverageDuration time.Duration
+}
+
+// MetricsSnapshot is a copy of the collected metrics at one moment.
+type MetricsSnapshot struct {
+	Total     int
+	Succeeded int
+	Failed    int
+	ByAction  map[string]ActionMetrics
+}
+
+// NewMetrics returns an empty collector. A slowThreshold of zero disables
+// slow-call counting.
+func NewMetrics(slowThreshold time.Duration) *Metrics {
+	return &Metrics{slowThreshold: slowThreshold, byName: make(map[string]*ActionMetrics)}
+}
+
+// Record adds one call of cmd that took d.
+func (m *Metrics) Record(cmd Command, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats, ok := m.byName[cmd.Name()]
+	if !ok {
+		stats = &ActionMetrics{}
+		m.byName[cmd.Name()] = stats
+	}
+	stats.Count++
+	if cmd.Succeeded() {
+		stats.Succeeded++
+	} else {
+		stats.Failed++
+	}
+	if m.slowThreshold > 0 && d > m.slowThreshold {
+		stats.Slow++
+	}
+	stats.TotalDuration += d
+	stats.MaxDuration = max(stats.MaxDuration, d)
+}
+
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := MetricsSnapshot{ByAction: make(map[string]ActionMetrics, len(m.byName))}
+	for name, stats := range m.byName {
+		s := *stats
+		s.AverageDuration = s.TotalDuration / time.Duration(s.Count)
+		snapshot.ByAction[name] = s
+		snapshot.Total += s.Count
+		snapshot.Succeeded += s.Succeeded
+		snapshot.Failed += s.Failed
+	}
+	return snapshot
+}
+
+// SetMetrics makes Execute and Redo time every command they call and record
+// it in metrics.
+func (m *CommandManager) SetMetrics(metrics *Metrics) {
+	m.metrics = metrics
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// Money is an amount in integer cents. Unlike float64 it adds and subtracts
+// exactly, so balances don't drift over many transfers.
+type Money int64
+
+// invalidMoney is what float64 amounts that aren't finite, or are too big
+// for Money, convert to. No real amount takes this value, so the checks
+// that matter reject it with ErrInvalidAmount instead of letting a NaN
+// through every comparison.
+const invalidMoney = Money(math.MinInt64)
+
+// NewMoney converts a float64 amount to Money, rounding to the nearest cent.
+// It is the adapter for callers still working in float64. NaN, ±Inf and
+// amounts beyond the range of Money become invalidMoney.
+func NewMoney(amount float64) Money {
+	return centsToMoney(math.Round(amount * 100))
+}
+
+// centsToMoney converts a whole number of cents, mapping anything Money
+// can't hold to invalidMoney rather than to whatever the platform's
+// conversion yields.
+func centsToMoney(cents float64) Money {
+	if math.IsNaN(cents) || cents >= math.MaxInt64 || cents <= math.MinInt64 {
+		return invalidMoney
+	}
+	return Money(cents)
+}
+
+// ParseMoney parses a decimal string with at most two fractional digits,
+// such as "12", "-0.5" or "1234.56".
+func ParseMoney(s string) (Money, error) {
+	invalid := fmt.Errorf("%w %q", ErrInvalidMoney, s)
+	text := strings.TrimSpace(s)
+	negative := strings.HasPrefix(text, "-")
+	if negative {
+		text = text[1:]
+	} else {
+		text = strings.TrimPrefix(text, "+")
+	}
+	units, fraction, _ := strings.Cut(text, ".")
+	if units == "" && fraction == "" || len(fraction) > 2 || !isDigits(units) || !isDigits(fraction) {
+		return 0, invalid
+	}
+	var whole, cents int64
+	if units != "" {
+		n, err := strconv.ParseInt(units, 10, 64)
+		if err != nil || n > math.MaxInt64/100-1 {
+			return 0, invalid
+		}
+		whole = n
+	}
+	if fraction != "" {
+		cents, _ = strconv.ParseInt(fraction, 10, 64)
+		if len(fraction) == 1 {
+			cents *= 10
+		}
+	}
+	m := Money(whole*100 + cents)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Money) Add(other Money) Money {
+	return m + other
+}
+
+func (m Money) Sub(other Money) Money {
+	return m - other
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// String formats m with two decimals, for example "-1234.50".
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalJSON writes m as a JSON number with two decimals.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseMoney(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+// Optimize returns a copy of c in which the plain deposits and withdrawals
+// on each account are netted into a single command, placed where that
+// account's first leg was. Accounts whose legs cancel out get no command at
+// all. Transfers, nested composites, fees, holds, already executed legs and
+// legs with callbacks, an expected version or a one-time overdraft exception
+// are left as they are. c itself is not changed and keeps its per-leg
+// granularity.
+//
+// The optimized composite leaves the same final balances as c when it
+// succeeds, and undoing it restores the balances c started from. It takes
+// one lock per account instead of one per leg, but it checks limits against
+// the net amount only: a batch whose legs would overdraw an account midway,
+// or whose net exceeds a per-transaction limit no leg did, behaves
+// differently. Netted commands carry no category, tags or attribution.
+func (c *CompositeBankAccountCommand) Optimize() *CompositeBankAccountCommand {
+	nets := map[*BankAccount]Money{}
+	first := map[*BankAccount]int{}
+	for i, cmd := range c.commands {
+		leg, ok := cmd.(*BankAccountCommand)
+		if !ok || !leg.nettable() {
+			continue
+		}
+		if _, seen := first[leg.account]; !seen {
+			first[leg.account] = i
+		}
+		if leg.action == Deposit {
+			nets[leg.account] += leg.amount
+		} else {
+			nets[leg.account] -= leg.amount
+		}
+	}
+	optimized := &CompositeBankAccountCommand{id: newCommandID(), stopOnFailure: c.stopOnFailure, order: c.order}
+	for i, cmd := range c.commands {
+		leg, ok := cmd.(*BankAccountCommand)
+		if !ok || !leg.nettable() {
+			optimized.commands = append(optimized.commands, cmd)
+			continue
+		}
+		if first[leg.account] != i {
+			continue
+		}
+		switch net := nets[leg.account]; {
+		case net > 0:
+			optimized.commands = append(optimized.commands, newBankAccountCommand(leg.account, Deposit, net))
+		case net < 0:
+			optimized.commands = append(optimized.commands, newBankAccountCommand(leg.account, Withdraw, -net))
+		}
+	}
+	return optimized
+}
+
+// nettable reports whether Optimize may fold c into a net amount without
+// changing what it does beyond the balance.
+func (c *BankAccountCommand) nettable() bool {
+	if c.action != Deposit && c.action != Withdraw {
+		return false
+	}
+	return c.account != nil && !c.executed && c.amount > 0 &&
+		c.onSuccess == nil && c.onFailure == nil &&
+		!c.hasExpectedVersion && !c.allowOneTimeOverdraft
+}
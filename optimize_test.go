@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+// batch builds a composite mixing nettable legs on a and b with legs
+// Optimize must keep.
+func batch(a, b *BankAccount) *CompositeBankAccountCommand {
+	transfer, _ := NewMoneyTransferCommand(a, b, 10)
+	return NewCompositeCommand(true,
+		NewBankAccountCommand(a, Deposit, 50),
+		NewBankAccountCommand(b, Withdraw, 20),
+		NewBankAccountCommand(a, Withdraw, 30),
+		transfer,
+		NewBankAccountCommand(b, Deposit, 20),
+		NewBankAccountCommand(a, Fee, 1),
+	)
+}
+
+func TestOptimize(t *testing.T) {
+	a, b := NewBankAccount(100, 0), NewBankAccount(100, 0)
+	original := batch(a, b)
+	optimized := original.Optimize()
+
+	// a nets +20 where its first leg was, b's legs cancel out, and the
+	// transfer and fee are kept as they are.
+	if len(optimized.commands) != 3 {
+		t.Fatalf("%d commands after Optimize, want 3: %s", len(optimized.commands), optimized.Describe())
+	}
+	net, ok := optimized.commands[0].(*BankAccountCommand)
+	if !ok || net.account != a || net.action != Deposit || net.amount != NewMoney(20) {
+		t.Fatalf("first command %s, want a deposit of 20.00 into a", optimized.commands[0].Describe())
+	}
+	if optimized.commands[1] != original.commands[3] || optimized.commands[2] != original.commands[5] {
+		t.Fatal("Optimize didn't keep the transfer and fee in place")
+	}
+	if len(original.commands) != 6 || optimized.CommandID() == original.CommandID() || optimized.stopOnFailure != original.stopOnFailure {
+		t.Fatal("Optimize changed the original or didn't copy its settings")
+	}
+
+	want1, want2 := NewBankAccount(100, 0), NewBankAccount(100, 0)
+	if err := call(batch(want1, want2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(optimized); err != nil {
+		t.Fatal(err)
+	}
+	if a.Balance() != want1.Balance() || b.Balance() != want2.Balance() {
+		t.Fatalf("balances %v, %v; want %v, %v as without Optimize", a.Balance(), b.Balance(), want1.Balance(), want2.Balance())
+	}
+	if err := optimized.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Balance() != 100 || b.Balance() != 100 {
+		t.Fatalf("balances %v, %v after Undo, want 100, 100", a.Balance(), b.Balance())
+	}
+}
+
+func TestOptimizeKeepsSpecialLegs(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	executed := NewBankAccountCommand(account, Deposit, 1)
+	executed.Call()
+	versioned := NewBankAccountCommand(account, Deposit, 2)
+	versioned.ExpectVersion(account.Version())
+	overdraft := NewBankAccountCommand(account, Withdraw, 3)
+	overdraft.SetAllowOneTimeOverdraft(true)
+	callback := NewBankAccountCommand(account, Withdraw, 4)
+	callback.OnSuccess(func(Command) {})
+	special := []Command{
+		executed,
+		versioned,
+		overdraft,
+		callback,
+		NewBankAccountCommand(account, WithdrawAll, 0),
+		NewBankAccountCommand(account, Authorize, 5),
+		NewCompositeCommand(false, NewBankAccountCommand(account, Deposit, 6)),
+	}
+	optimized := NewCompositeCommand(false, special...).Optimize()
+	if len(optimized.commands) != len(special) {
+		t.Fatalf("%d commands after Optimize, want all %d kept", len(optimized.commands), len(special))
+	}
+	for i, cmd := range special {
+		if optimized.commands[i] != cmd {
+			t.Errorf("command %d is %s, want %s kept in place", i, optimized.commands[i].Describe(), cmd.Describe())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import "sort"
+
+// OrderStrategy decides the order a composite calls its children in.
+type OrderStrategy int
+
+const (
+	// AsGiven calls the children in slice order, the default.
+	AsGiven OrderStrategy = iota
+	// CreditsFirst calls deposits before everything else and withdrawals,
+	// fees, captures and authorizations last, so a batch doesn't fail on
+	// an overdraft that a later deposit would have covered.
+	CreditsFirst
+	// DebitsFirst is the opposite, taking money out before paying any in.
+	DebitsFirst
+)
+
+func (s OrderStrategy) String() string {
+	switch s {
+	case CreditsFirst:
+		return "credits first"
+	case DebitsFirst:
+		return "debits first"
+	}
+	return "as given"
+}
+
+// SetOrder sets the order Call uses for the composite's children. Children
+// that are neither a credit nor a debit on their own, such as transfers and
+// nested composites, stay in place relative to one another, between the
+// credits and the debits. The order is worked out at each Call and kept,
+// so Undo, rollbacks, Progress and ResumeFrom all follow it.
+func (c *CompositeBankAccountCommand) SetOrder(strategy OrderStrategy) {
+	c.order = strategy
+}
+
+func (c *CompositeBankAccountCommand) Order() OrderStrategy {
+	return c.order
+}
+
+// arrange works out the order the next run calls the children in.
+func (c *CompositeBankAccountCommand) arrange() {
+	c.sequence = nil
+	if c.order == AsGiven {
+		return
+	}
+	c.sequence = make([]int, len(c.commands))
+	for i := range c.sequence {
+		c.sequence[i] = i
+	}
+	sort.SliceStable(c.sequence, func(i, j int) bool {
+		return c.rank(c.commands[c.sequence[i]]) < c.rank(c.commands[c.sequence[j]])
+	})
+}
+
+// rank places credits at 0, debits at 2 and anything else at 1, flipped for
+// DebitsFirst.
+func (c *CompositeBankAccountCommand) rank(cmd Command) int {
+	rank := 1
+	if leaf, ok := cmd.(*BankAccountCommand); ok {
+		switch leaf.action {
+		case Deposit:
+			rank = 0
+		case Withdraw, WithdrawAll, WithdrawUpTo, Fee, Capture, Authorize:
+			rank = 2
+		}
+	}
+	if c.order == DebitsFirst {
+		return 2 - rank
+	}
+	return rank
+}
+
+// child returns the child at position i of the order last used.
+func (c *CompositeBankAccountCommand) child(i int) Command {
+	if c.sequence == nil {
+		return c.commands[i]
+	}
+	return c.commands[c.sequence[i]]
+}
@@ -0,0 +1,30 @@
+package main
+
+// OverdraftUsed returns how far below zero the balance is, or 0 when it
+// isn't negative.
+func (account *BankAccount) OverdraftUsed() float64 {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return account.overdraftUsed().Float64()
+}
+
+// OverdraftAvailable returns how much further the account can be overdrawn
+// before reaching its overdraft limit. Like the OverdraftLimit validator it
+// works from the available balance, so holds use up headroom too. It is 0
+// for an account at or past its limit, or without an overdraft.
+func (account *BankAccount) OverdraftAvailable() float64 {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return account.overdraftAvailable().Float64()
+}
+
+// overdraftUsed and overdraftAvailable expect the caller to hold
+// account.mu.
+func (account *BankAccount) overdraftUsed() Money {
+	return max(-account.balance, 0)
+}
+
+func (account *BankAccount) overdraftAvailable() Money {
+	available := account.balance - account.held
+	return max(min(available, 0)-account.overdraftLimit, 0)
+}
@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestOverdraftUsage(t *testing.T) {
+	tests := []struct {
+		name           string
+		balance, limit float64
+		used, avail    float64
+	}{
+		{"in credit", 100, -500, 0, 500},
+		{"at zero", 0, -500, 0, 500},
+		{"partly overdrawn", -200, -500, 200, 300},
+		{"exactly at the limit", -500, -500, 500, 0},
+		{"past the limit", -600, -500, 600, 0},
+		{"one cent over zero", -0.01, -500, 0.01, 499.99},
+		{"no overdraft", 50, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := NewBankAccount(tt.balance, tt.limit)
+			if got := account.OverdraftUsed(); got != tt.used {
+				t.Errorf("OverdraftUsed = %v, want %v", got, tt.used)
+			}
+			if got := account.OverdraftAvailable(); got != tt.avail {
+				t.Errorf("OverdraftAvailable = %v, want %v", got, tt.avail)
+			}
+		})
+	}
+}
+
+// At the boundary the headroom is exactly what a withdrawal may take: a
+// withdrawal of it succeeds and one cent more fails.
+func TestOverdraftAvailableAtBoundary(t *testing.T) {
+	account := NewBankAccount(0, -100)
+	over := NewBankAccountCommand(account, Withdraw, account.OverdraftAvailable()+0.01)
+	over.Call()
+	if over.Succeeded() {
+		t.Fatal("withdrawal one cent past the headroom succeeded")
+	}
+	exact := NewBankAccountCommand(account, Withdraw, account.OverdraftAvailable())
+	exact.Call()
+	if !exact.Succeeded() {
+		t.Fatalf("withdrawal of the whole headroom failed: %v", exact.Err())
+	}
+	if account.Balance() != -100 || account.OverdraftUsed() != 100 || account.OverdraftAvailable() != 0 {
+		t.Fatalf("balance %v, used %v, available %v; want -100, 100, 0", account.Balance(), account.OverdraftUsed(), account.OverdraftAvailable())
+	}
+	last := NewBankAccountCommand(account, Withdraw, 0.01)
+	last.Call()
+	if last.Succeeded() {
+		t.Fatal("withdrawal from an account at its limit succeeded")
+	}
+}
+
+func TestOverdraftAvailableCountsHolds(t *testing.T) {
+	account := NewBankAccount(10, -100)
+	if err := account.Authorize(50); err != nil {
+		t.Fatal(err)
+	}
+	if got := account.OverdraftAvailable(); got != 60 {
+		t.Fatalf("OverdraftAvailable = %v, want 60", got)
+	}
+	if got := account.OverdraftUsed(); got != 0 {
+		t.Fatalf("OverdraftUsed = %v, want 0 while the balance is positive", got)
+	}
+}
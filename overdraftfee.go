@@ -0,0 +1,40 @@
+package main
+
+// SetOverdraftFee turns on overdraft fees: every withdrawal that leaves the
+// balance negative is followed by a Fee command charging fee. Accounts
+// charge nothing until this is called.
+func (account *BankAccount) SetOverdraftFee(fee float64) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	account.overdraftFee = NewMoney(fee)
+	account.chargeOverdraftFee = true
+}
+
+func (account *BankAccount) DisableOverdraftFee() {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	account.chargeOverdraftFee = false
+}
+
+// chargeFor debits a fee. Fees are not withdrawals: they apply to frozen
+// accounts, don't count against the daily limit and may take the balance
+// past the overdraft limit. The caller must hold account.mu.
+func (account *BankAccount) chargeFor(cmd *BankAccountCommand) error {
+	if err := account.validate(cmd); err != nil {
+		return err
+	}
+	account.adjust(-cmd.amount)
+	return nil
+}
+
+// chargeOverdraftFee follows a successful withdrawal with the account's
+// overdraft fee if the withdrawal left the balance negative.
+func (c *BankAccountCommand) chargeOverdraftFee() {
+	account := c.account
+	if c.action != Withdraw && c.action != WithdrawAll && c.action != WithdrawUpTo || !account.chargeOverdraftFee || account.balance >= 0 || account.overdraftFee <= 0 {
+		return
+	}
+	c.fee = newBankAccountCommand(account, Fee, account.overdraftFee)
+	c.fee.actor = c.actor
+	c.fee.callLocked()
+}
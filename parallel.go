@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSharedAccount       = errors.New("parallel children share an account")
+	ErrParallelUnsupported = errors.New("command can't run in parallel")
+)
+
+// ParallelCommand calls its children at the same time, each on its own
+// goroutine, and waits for all of them. It is all-or-nothing: if any child
+// fails, the ones that succeeded are undone, so the accounts are left as
+// they were and every child is reported as failed. Because two goroutines
+// changing one account would race on it, children may not share an account;
+// see NewParallelCommand.
+type ParallelCommand struct {
+	id    string
+	group *CompositeBankAccountCommand
+
+	// rollbackErr holds the errors of children that couldn't be undone
+	// after a sibling failed.
+	rollbackErr error
+}
+
+// NewParallelCommand groups independent commands, such as a batch of
+// unrelated transfers. It fails with ErrSharedAccount if two children touch
+// the same account, and with ErrParallelUnsupported for a child whose
+// accounts it can't determine.
+func NewParallelCommand(cmds ...Command) (*ParallelCommand, error) {
+	owners := make(map[*BankAccount]int)
+	for i, cmd := range cmds {
+		accounts, ok := touchedAccounts(cmd)
+		if !ok {
+			return nil, fmt.Errorf("%w: child %d (%s)", ErrParallelUnsupported, i, cmd.Name())
+		}
+		for _, account := range accounts {
+			if j, taken := owners[account]; taken && j != i {
+				return nil, fmt.Errorf("%w: children %d and %d both use %s", ErrSharedAccount, j, i, accountLabel(account))
+			}
+			owners[account] = i
+		}
+	}
+	return &ParallelCommand{id: newCommandID(), group: &CompositeBankAccountCommand{commands: cmds}}, nil
+}
+
+// touchedAccounts returns every account cmd may change, and false if cmd is
+// of a kind whose accounts aren't known.
+func touchedAccounts(cmd Command) ([]*BankAccount, bool) {
+	var accounts []*BankAccount
+	switch c := cmd.(type) {
+	case *BankAccountCommand:
+		accounts = append(accounts, c.account)
+	case *MoneyTransferCommand:
+		accounts = append(accounts, c.from, c.to)
+	case *InterestCommand:
+		accounts = append(accounts, c.account)
+	case *CloseAccountCommand:
+		accounts = append(accounts, c.lockSet()...)
+	case *ConditionalCommand:
+		inner, ok := touchedAccounts(c.inner)
+		if !ok {
+			return nil, false
+		}
+		accounts = append(append(accounts, c.account), inner...)
+	case *RetryCommand:
+		return touchedAccounts(c.inner)
+	case *ParallelCommand:
+		return touchedAccounts(c.group)
+	case *CompositeBankAccountCommand:
+		for _, child := range c.commands {
+			inner, ok := touchedAccounts(child)
+			if !ok {
+				return nil, false
+			}
+			accounts = append(accounts, inner...)
+		}
+	default:
+		return nil, false
+	}
+	known := accounts[:0]
+	for _, account := range accounts {
+		if account != nil {
+			known = append(known, account)
+		}
+	}
+	return known, true
+}
+
+// Call runs every child concurrently and, once all have finished, undoes
+// the successful ones in reverse order if any child failed. The children it
+// undoes are marked as failed; one whose undo fails is left applied, still
+// reporting success, and its error is reported by Err.
+func (c *ParallelCommand) Call() {
+	c.rollbackErr = nil
+	var wg sync.WaitGroup
+	for _, cmd := range c.group.commands {
+		wg.Add(1)
+		go func(cmd Command) {
+			defer wg.Done()
+			cmd.Call()
+		}(cmd)
+	}
+	wg.Wait()
+	if c.group.Succeeded() {
+		return
+	}
+	var errs []error
+	for i := len(c.group.commands) - 1; i >= 0; i-- {
+		cmd := c.group.commands[i]
+		if !cmd.Succeeded() {
+			continue
+		}
+		if err := cmd.Undo(); err != nil {
+			errs = append(errs, fmt.Errorf("child %d: %w", i, err))
+			continue
+		}
+		cmd.SetSucceeded(false)
+	}
+	c.rollbackErr = errors.Join(errs...)
+}
+
+// CallCtx checks ctx once up front; the children are then left to finish.
+func (c *ParallelCommand) CallCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.Call()
+	return c.Err()
+}
+
+func (c *ParallelCommand) Undo() error {
+	return c.group.Undo()
+}
+
+func (c *ParallelCommand) Succeeded() bool {
+	return c.group.Succeeded()
+}
+
+func (c *ParallelCommand) SetSucceeded(value bool) {
+	c.group.SetSucceeded(value)
+}
+
+// Err joins the errors of every failed child, rather than returning only the
+// first as a composite does, together with any errors from a rollback that
+// didn't complete.
+func (c *ParallelCommand) Err() error {
+	var errs []error
+	for i, cmd := range c.group.commands {
+		if err := cmd.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("child %d: %w", i, err))
+		}
+	}
+	return errors.Join(append(errs, c.rollbackErr)...)
+}
+
+func (c *ParallelCommand) CreatedAt() time.Time {
+	return c.group.CreatedAt()
+}
+
+func (c *ParallelCommand) ExecutedAt() time.Time {
+	return c.group.ExecutedAt()
+}
+
+// Reverse returns the children's reversals as a sequential composite.
+func (c *ParallelCommand) Reverse() Command {
+	return c.group.Reverse()
+}
+
+func (c *ParallelCommand) Clone() Command {
+	return &ParallelCommand{id: newCommandID(), group: &CompositeBankAccountCommand{commands: cloneAll(c.group.commands)}}
+}
+
+func (c *ParallelCommand) Name() string {
+	return "Parallel"
+}
+
+func (c *ParallelCommand) Describe() string {
+	return "In parallel: " + c.group.Describe()
+}
+
+func (c *ParallelCommand) CommandID() string {
+	if c.id == "" {
+		c.id = newCommandID()
+	}
+	return c.id
+}
+
+func (c *ParallelCommand) Validate() error {
+	return c.group.Validate()
+}
+
+// Prepare, Commit and Rollback go through the children one at a time, as
+// for a composite.
+func (c *ParallelCommand) Prepare() error {
+	return c.group.Prepare()
+}
+
+func (c *ParallelCommand) Commit() {
+	c.group.Commit()
+}
+
+func (c *ParallelCommand) Rollback() {
+	c.group.Rollback()
+}
+
+func (c *ParallelCommand) Results() []CommandResult {
+	return c.group.Results()
+}
+
+func (c *ParallelCommand) Priority() int {
+	return c.group.Priority()
+}
+
+func (c *ParallelCommand) children() []Command {
+	return c.group.commands
+}
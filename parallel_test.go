@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParallelTransfers(t *testing.T) {
+	var cmds []Command
+	var froms, tos []*BankAccount
+	for i := 0; i < 50; i++ {
+		from, to := NewBankAccount(100, 0), NewBankAccount(0, 0)
+		transfer, err := NewMoneyTransferCommand(from, to, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		froms, tos = append(froms, from), append(tos, to)
+		cmds = append(cmds, transfer)
+	}
+	parallel, err := NewParallelCommand(cmds...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel.Call()
+	if !parallel.Succeeded() || parallel.Err() != nil {
+		t.Fatalf("succeeded %v, err %v; want success", parallel.Succeeded(), parallel.Err())
+	}
+	for i := range froms {
+		if froms[i].Balance() != 90 || tos[i].Balance() != 10 {
+			t.Fatalf("transfer %d: balances %v, %v; want 90, 10", i, froms[i].Balance(), tos[i].Balance())
+		}
+	}
+	if err := parallel.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range froms {
+		if froms[i].Balance() != 100 || tos[i].Balance() != 0 {
+			t.Fatalf("transfer %d after Undo: balances %v, %v; want 100, 0", i, froms[i].Balance(), tos[i].Balance())
+		}
+	}
+}
+
+// One failing child undoes the siblings that went through, and every child
+// is reported as failed.
+func TestParallelRollsBackOnFailure(t *testing.T) {
+	a, b, c := NewBankAccount(100, 0), NewBankAccount(0, 0), NewBankAccount(5, 0)
+	parallel, err := NewParallelCommand(
+		NewBankAccountCommand(a, Withdraw, 50),
+		NewBankAccountCommand(b, Deposit, 5),
+		NewBankAccountCommand(c, Withdraw, 50),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel.Call()
+	if parallel.Succeeded() || !errors.Is(parallel.Err(), ErrOverdraftExceeded) {
+		t.Fatalf("succeeded %v, err %v; want ErrOverdraftExceeded", parallel.Succeeded(), parallel.Err())
+	}
+	if a.Balance() != 100 || b.Balance() != 0 || c.Balance() != 5 {
+		t.Fatalf("balances %v, %v, %v; want 100, 0, 5", a.Balance(), b.Balance(), c.Balance())
+	}
+	for i, child := range parallel.children() {
+		if child.Succeeded() {
+			t.Errorf("child %d still reports success after the rollback", i)
+		}
+	}
+}
+
+// A sibling whose undo fails during the rollback stays applied, rather than
+// being reported as failed where nothing could undo it any more.
+func TestParallelRollbackLeavesFailedUndoApplied(t *testing.T) {
+	a, b := NewBankAccount(0, 0), NewBankAccount(5, 0)
+	deposit := NewBankAccountCommand(a, Deposit, 50)
+	deposit.OnSuccess(func(Command) { a.Withdraw(50) })
+	parallel, err := NewParallelCommand(deposit, NewBankAccountCommand(b, Withdraw, 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel.Call()
+	if !errors.Is(parallel.Err(), ErrOverdraftExceeded) || parallel.Succeeded() {
+		t.Fatalf("succeeded %v, err %v; want ErrOverdraftExceeded", parallel.Succeeded(), parallel.Err())
+	}
+	if !deposit.Succeeded() || !deposit.CanUndo() {
+		t.Fatalf("deposit succeeded %v, undoable %v; want it left applied", deposit.Succeeded(), deposit.CanUndo())
+	}
+	a.Deposit(50)
+	if err := deposit.Undo(); err != nil || a.Balance() != 0 {
+		t.Fatalf("Undo = %v with balance %v, want nil and 0", err, a.Balance())
+	}
+}
+
+func TestParallelRejectsSharedAccounts(t *testing.T) {
+	a, b, c := NewBankAccount(100, 0), NewBankAccount(0, 0), NewBankAccount(0, 0)
+	aToB, _ := NewMoneyTransferCommand(a, b, 1)
+	cToA, _ := NewMoneyTransferCommand(c, a, 1)
+	tests := []struct {
+		name string
+		cmds []Command
+	}{
+		{"transfers sharing an account", []Command{aToB, cToA}},
+		{"account inside a composite", []Command{NewCompositeCommand(false, NewBankAccountCommand(a, Deposit, 1)), NewBankAccountCommand(a, Deposit, 1)}},
+		{"account behind a retry", []Command{NewRetryCommand(NewBankAccountCommand(b, Deposit, 1), 2), aToB}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewParallelCommand(tt.cmds...); !errors.Is(err, ErrSharedAccount) {
+				t.Fatalf("NewParallelCommand = %v, want ErrSharedAccount", err)
+			}
+		})
+	}
+	if _, err := NewParallelCommand(aToB, NewBankAccountCommand(c, Deposit, 1)); err != nil {
+		t.Fatalf("disjoint children: %v", err)
+	}
+}
+
+type opaqueCommand struct{ Command }
+
+func TestParallelRejectsUnknownCommands(t *testing.T) {
+	child := opaqueCommand{NewBankAccountCommand(NewBankAccount(0, 0), Deposit, 1)}
+	if _, err := NewParallelCommand(child); !errors.Is(err, ErrParallelUnsupported) {
+		t.Fatalf("NewParallelCommand = %v, want ErrParallelUnsupported", err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// DefaultPriority is the priority of commands that don't set one.
+const DefaultPriority = 0
+
+// Prioritized is implemented by commands that carry a priority. Higher
+// values run first.
+type Prioritized interface {
+	Priority() int
+}
+
+func (c *BankAccountCommand) Priority() int {
+	return c.priority
+}
+
+func (c *BankAccountCommand) SetPriority(priority int) {
+	c.priority = priority
+}
+
+// Priority of a composite is that of its most urgent child.
+func (c *CompositeBankAccountCommand) Priority() int {
+	if len(c.commands) == 0 {
+		return DefaultPriority
+	}
+	highest := priorityOf(c.commands[0])
+	for _, cmd := range c.commands[1:] {
+		highest = max(highest, priorityOf(cmd))
+	}
+	return highest
+}
+
+func priorityOf(cmd Command) int {
+	if p, ok := cmd.(Prioritized); ok {
+		return p.Priority()
+	}
+	return DefaultPriority
+}
+
+// PriorityQueue holds commands until Drain calls them highest priority
+// first, breaking ties by creation time and then by the order they were
+// pushed. It is safe for concurrent use.
+type PriorityQueue struct {
+	mu    sync.Mutex
+	items priorityItems
+	seq   int
+}
+
+func NewPriorityQueue() *PriorityQueue {
+	return &PriorityQueue{}
+}
+
+func (q *PriorityQueue) Push(cmd Command) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	heap.Push(&q.items, priorityItem{cmd: cmd, priority: priorityOf(cmd), seq: q.seq})
+}
+
+func (q *PriorityQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// Drain calls every queued command in priority order and returns them in
+// the order they ran. Commands pushed while draining are run too.
+func (q *PriorityQueue) Drain() []Command {
+	var ran []Command
+	for {
+		q.mu.Lock()
+		if q.items.Len() == 0 {
+			q.mu.Unlock()
+			return ran
+		}
+		item := heap.Pop(&q.items).(priorityItem)
+		q.mu.Unlock()
+		item.cmd.Call()
+		ran = append(ran, item.cmd)
+	}
+}
+
+type priorityItem struct {
+	cmd      Command
+	priority int
+	seq      int
+}
+
+type priorityItems []priorityItem
+
+func (p priorityItems) Len() int { return len(p) }
+
+func (p priorityItems) Less(i, j int) bool {
+	if p[i].priority != p[j].priority {
+		return p[i].priority > p[j].priority
+	}
+	ci, cj := p[i].cmd.CreatedAt(), p[j].cmd.CreatedAt()
+	if !ci.Equal(cj) {
+		return ci.Before(cj)
+	}
+	return p[i].seq < p[j].seq
+}
+
+func (p priorityItems) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+func (p *priorityItems) Push(x any) { *p = append(*p, x.(priorityItem)) }
+
+func (p *priorityItems) Pop() any {
+	old := *p
+	item := old[len(old)-1]
+	*p = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,28 @@
+package main
+
+// Progress reports how far through its children the composite has got: done
+// is the number of leading children, in the order the last run used, that
+// have run successfully, counting
+// any skipped with ResumeFrom, out of total. After an interrupted run,
+// persisting done and passing it to ResumeFrom on a rebuilt composite
+// carries on without applying the finished children a second time.
+func (c *CompositeBankAccountCommand) Progress() (done int, total int) {
+	done = c.start()
+	for done < len(c.commands) && c.child(done).Succeeded() {
+		done++
+	}
+	return done, len(c.commands)
+}
+
+// ResumeFrom makes Call and CallCtx skip the first index children, treating
+// them as already applied by an earlier run. A failure in a stopOnFailure
+// composite then only rolls back the children this run applied. Indexes
+// outside the children are clamped, so resuming from total runs nothing.
+// A MoneyTransferCommand always runs as a whole and ignores it.
+func (c *CompositeBankAccountCommand) ResumeFrom(index int) {
+	c.resumeFrom = index
+}
+
+func (c *CompositeBankAccountCommand) start() int {
+	return min(max(c.resumeFrom, 0), len(c.commands))
+}
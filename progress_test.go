@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+// resumable builds a composite depositing 10 into a, 20 into b and
+// withdrawing 50 from c.
+func resumable(stopOnFailure bool, a, b, c *BankAccount) *CompositeBankAccountCommand {
+	return NewCompositeCommand(stopOnFailure,
+		NewBankAccountCommand(a, Deposit, 10),
+		NewBankAccountCommand(b, Deposit, 20),
+		NewBankAccountCommand(c, Withdraw, 50),
+	)
+}
+
+// An interrupted run is picked up by a rebuilt composite from the persisted
+// progress, without applying the finished children again.
+func TestResumeFromProgress(t *testing.T) {
+	a, b, c := NewBankAccount(0, 0), NewBankAccount(0, 0), NewBankAccount(0, 0)
+	first := resumable(false, a, b, c)
+	first.Call()
+	done, total := first.Progress()
+	if done != 2 || total != 3 {
+		t.Fatalf("Progress = %d, %d; want 2, 3", done, total)
+	}
+	c.Deposit(50)
+	second := resumable(false, a, b, c)
+	second.ResumeFrom(done)
+	second.Call()
+	if !second.Succeeded() {
+		t.Fatal(second.Err())
+	}
+	if a.Balance() != 10 || b.Balance() != 20 || c.Balance() != 0 {
+		t.Fatalf("balances %v, %v, %v; want 10, 20, 0", a.Balance(), b.Balance(), c.Balance())
+	}
+	if done, total := second.Progress(); done != total {
+		t.Fatalf("Progress = %d, %d after the resumed run, want all done", done, total)
+	}
+}
+
+// A resumed all-or-nothing composite only rolls back what this run applied.
+func TestResumeFromRollsBackOnlyThisRun(t *testing.T) {
+	a, b, c := NewBankAccount(10, 0), NewBankAccount(0, 0), NewBankAccount(0, 0)
+	resumed := resumable(true, a, b, c)
+	resumed.ResumeFrom(1)
+	resumed.Call()
+	if resumed.Succeeded() {
+		t.Fatal("resumed composite succeeded with c unable to cover the withdrawal")
+	}
+	if a.Balance() != 10 || b.Balance() != 0 || c.Balance() != 0 {
+		t.Fatalf("balances %v, %v, %v; want 10 left from the earlier run, 0, 0", a.Balance(), b.Balance(), c.Balance())
+	}
+}
+
+func TestResumeFromClamps(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		want  float64
+	}{
+		{"before the first child", -1, 10},
+		{"past the last child", 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b, c := NewBankAccount(0, 0), NewBankAccount(0, 0), NewBankAccount(50, 0)
+			composite := resumable(false, a, b, c)
+			composite.ResumeFrom(tt.index)
+			composite.Call()
+			if a.Balance() != tt.want {
+				t.Fatalf("a's balance %v, want %v", a.Balance(), tt.want)
+			}
+			if done, total := composite.Progress(); done != total {
+				t.Fatalf("Progress = %d, %d; want all done", done, total)
+			}
+		})
+	}
+}
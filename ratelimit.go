@@ -0,0 +1,178 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is temporary: tokens refill over time.
+var ErrRateLimited error = &temporaryError{"rate limit exceeded"}
+
+// RateLimiter throttles CommandManager.Execute with token buckets refilled
+// at a fixed rate, so a runaway loop can't drain an account through a flood
+// of tiny withdrawals. By default one bucket is shared by every command; with
+// PerAccount each account gets its own and a command needs a token from
+// every account it touches. It is safe for concurrent use, and can share its
+// manager with a DedupExecutor, but the manager mustn't also be used
+// directly; see CommandManager.
+type RateLimiter struct {
+	manager    *CommandManager
+	perSecond  float64
+	burst      float64
+	block      bool
+	perAccount bool
+
+	mu      sync.Mutex
+	buckets map[*BankAccount]*tokenBucket
+	// swept is when sweep last dropped the full buckets.
+	swept time.Time
+}
+
+type RateLimitOption func(*RateLimiter)
+
+// Blocking makes Execute wait for a token instead of returning
+// ErrRateLimited.
+func Blocking() RateLimitOption {
+	return func(l *RateLimiter) {
+		l.block = true
+	}
+}
+
+// PerAccount gives every account its own bucket.
+func PerAccount() RateLimitOption {
+	return func(l *RateLimiter) {
+		l.perAccount = true
+	}
+}
+
+// WithBurst lets up to n commands through at once after a quiet period. The
+// default burst is one second's worth of tokens.
+func WithBurst(n int) RateLimitOption {
+	return func(l *RateLimiter) {
+		l.burst = float64(n)
+	}
+}
+
+// NewRateLimiter allows perSecond executions per second through manager.
+// perSecond must be positive.
+func NewRateLimiter(manager *CommandManager, perSecond float64, opts ...RateLimitOption) *RateLimiter {
+	l := &RateLimiter{
+		manager:   manager,
+		perSecond: perSecond,
+		burst:     max(perSecond, 1),
+		buckets:   make(map[*BankAccount]*tokenBucket),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Execute takes a token and passes cmd to the manager. Without a token it
+// returns ErrRateLimited, or waits for one if the limiter is Blocking.
+func (l *RateLimiter) Execute(cmd Command) error {
+	keys := l.keys(cmd)
+	for {
+		wait := l.take(keys, time.Now())
+		if wait == 0 {
+			break
+		}
+		if !l.block {
+			return ErrRateLimited
+		}
+		time.Sleep(wait)
+	}
+	l.manager.executeSerialized(cmd)
+	return nil
+}
+
+// Capacity returns how many tokens are available right now: in account's
+// bucket for a PerAccount limiter, in the shared bucket otherwise, where
+// account is ignored.
+func (l *RateLimiter) Capacity(account *BankAccount) float64 {
+	if !l.perAccount {
+		account = nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bucket(account, time.Now()).tokens
+}
+
+// keys returns the buckets cmd draws from. The shared bucket is keyed by
+// nil.
+func (l *RateLimiter) keys(cmd Command) []*BankAccount {
+	if !l.perAccount {
+		return []*BankAccount{nil}
+	}
+	var keys []*BankAccount
+	for _, leaf := range leaves(cmd) {
+		c, ok := leaf.(*BankAccountCommand)
+		if !ok || c.account == nil {
+			continue
+		}
+		seen := false
+		for _, key := range keys {
+			seen = seen || key == c.account
+		}
+		if !seen {
+			keys = append(keys, c.account)
+		}
+	}
+	return keys
+}
+
+// take removes a token from every bucket in keys if each has one and
+// returns zero. Otherwise it takes nothing and returns how long until all
+// of them will.
+func (l *RateLimiter) take(keys []*BankAccount, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	var wait time.Duration
+	for _, key := range keys {
+		if b := l.bucket(key, now); b.tokens < 1 {
+			wait = max(wait, time.Duration((1-b.tokens)/l.perSecond*float64(time.Second))+1)
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, key := range keys {
+		l.buckets[key].tokens--
+	}
+	return 0
+}
+
+// sweep drops the buckets that have refilled completely, so accounts that
+// have gone idle don't keep a bucket forever. A full bucket is exactly what
+// bucket would create afresh, so dropping one changes nothing. It sweeps at
+// most once per time it takes to refill an empty bucket. The caller must
+// hold l.mu.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept).Seconds()*l.perSecond < l.burst {
+		return
+	}
+	l.swept = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.perSecond >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// bucket returns key's bucket refilled up to now. The caller must hold l.mu.
+func (l *RateLimiter) bucket(key *BankAccount, now time.Time) *tokenBucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+	return b
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// A RateLimiter and a DedupExecutor wrapping one manager take the same lock,
+// so the race detector sees no concurrent use of the manager.
+func TestRateLimiterSharesManagerWithDedup(t *testing.T) {
+	manager := NewCommandManager()
+	limiter := NewRateLimiter(manager, 1e6, WithBurst(1000))
+	dedup := NewDedupExecutor(manager, 0)
+	account := NewBankAccount(0, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if cmd := NewBankAccountCommand(account, Deposit, 1); i%2 == 0 {
+				err = limiter.Execute(cmd)
+			} else {
+				err = dedup.Execute(cmd)
+			}
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if account.Balance() != 100 || len(manager.undoStack) != 100 {
+		t.Fatalf("balance %v with %d commands recorded, want 100 of each", account.Balance(), len(manager.undoStack))
+	}
+}
+
+// Buckets of accounts that have gone quiet are dropped once they have
+// refilled, rather than kept for every account ever seen.
+func TestRateLimiterDropsIdleBuckets(t *testing.T) {
+	limiter := NewRateLimiter(NewCommandManager(), 1, PerAccount(), WithBurst(1))
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if wait := limiter.take([]*BankAccount{NewBankAccount(0, 0)}, start); wait != 0 {
+			t.Fatalf("account %d had to wait %s for its first token", i, wait)
+		}
+	}
+	busy := NewBankAccount(0, 0)
+	limiter.take([]*BankAccount{busy}, start.Add(900*time.Millisecond))
+	if len(limiter.buckets) != 101 {
+		t.Fatalf("%d buckets before any refilled, want 101", len(limiter.buckets))
+	}
+	if wait := limiter.take([]*BankAccount{busy}, start.Add(time.Second)); wait == 0 {
+		t.Fatal("busy account got a second token before its bucket refilled")
+	}
+	if len(limiter.buckets) != 1 {
+		t.Fatalf("%d buckets after the idle ones refilled, want only the busy account's", len(limiter.buckets))
+	}
+}
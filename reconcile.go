@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// reconcileWindow is how far apart two timestamps may be and still be
+// taken as the same moment, since logs kept by different systems rarely
+// agree to the nanosecond.
+const reconcileWindow = time.Second
+
+// DiscrepancyKind classifies a difference between two command logs.
+type DiscrepancyKind int
+
+const (
+	// OnlyInA is a command in the first log with no counterpart in the
+	// second.
+	OnlyInA DiscrepancyKind = iota
+	// OnlyInB is a command in the second log with no counterpart in the
+	// first.
+	OnlyInB
+	// Mismatched is a command present in both logs under the same ID but
+	// with a different action, amount or account.
+	Mismatched
+)
+
+func (k DiscrepancyKind) String() string {
+	switch k {
+	case OnlyInA:
+		return "only in A"
+	case OnlyInB:
+		return "only in B"
+	case Mismatched:
+		return "mismatched"
+	}
+	return fmt.Sprintf("DiscrepancyKind(%d)", int(k))
+}
+
+// Discrepancy is one difference found by Reconcile. A is nil for OnlyInB
+// and B is nil for OnlyInA.
+type Discrepancy struct {
+	Kind   DiscrepancyKind
+	A, B   Command
+	Detail string
+}
+
+// ReconcileReport lists the discrepancies between two logs, in the order of
+// the first log followed by anything left over from the second.
+type ReconcileReport struct {
+	Matched       int
+	Discrepancies []Discrepancy
+}
+
+// Balanced reports whether the logs agree entirely.
+func (r ReconcileReport) Balanced() bool {
+	return len(r.Discrepancies) == 0
+}
+
+type reconcileEntry struct {
+	cmd     Command
+	action  string
+	amount  Money
+	account string
+	at      time.Time
+	matched bool
+}
+
+// Reconcile compares two recorded command sequences, such as our ledger
+// and a bank's, leg by leg: composites and transfers are expanded as in the
+// transaction log. Commands are paired by CommandID first, and a pair that
+// disagrees on action, amount or account is Mismatched. Whatever is left is
+// paired on action, amount, account and execution time, so entries the
+// other side recorded under its own IDs still match. The rest are reported
+// as OnlyInA or OnlyInB.
+func Reconcile(logA, logB []Command) ReconcileReport {
+	a, b := reconcileEntries(logA), reconcileEntries(logB)
+	byID := make(map[string]*reconcileEntry, len(b))
+	for _, entry := range b {
+		if id := entry.cmd.CommandID(); id != "" {
+			byID[id] = entry
+		}
+	}
+
+	var report ReconcileReport
+	for _, entry := range a {
+		other, ok := byID[entry.cmd.CommandID()]
+		if !ok || other.matched {
+			continue
+		}
+		entry.matched, other.matched = true, true
+		if detail := entry.differences(other); detail != "" {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{Kind: Mismatched, A: entry.cmd, B: other.cmd, Detail: detail})
+			continue
+		}
+		report.Matched++
+	}
+	for _, entry := range a {
+		if entry.matched {
+			continue
+		}
+		for _, other := range b {
+			if !other.matched && entry.differences(other) == "" && entry.sameTime(other) {
+				entry.matched, other.matched = true, true
+				report.Matched++
+				break
+			}
+		}
+		if !entry.matched {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{Kind: OnlyInA, A: entry.cmd, Detail: entry.cmd.Describe()})
+		}
+	}
+	for _, other := range b {
+		if !other.matched {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{Kind: OnlyInB, B: other.cmd, Detail: other.cmd.Describe()})
+		}
+	}
+	return report
+}
+
+func reconcileEntries(log []Command) []*reconcileEntry {
+	var entries []*reconcileEntry
+	for _, cmd := range log {
+		for _, leaf := range leaves(cmd) {
+			at := leaf.ExecutedAt()
+			if at.IsZero() {
+				at = leaf.CreatedAt()
+			}
+			entries = append(entries, &reconcileEntry{
+				cmd:     leaf,
+				action:  leaf.Name(),
+				amount:  commandAmount(leaf),
+				account: commandAccountID(leaf),
+				at:      at,
+			})
+		}
+	}
+	return entries
+}
+
+// differences describes how other disagrees with e, or returns "" if they
+// agree on action, amount and account.
+func (e *reconcileEntry) differences(other *reconcileEntry) string {
+	switch {
+	case e.action != other.action:
+		return fmt.Sprintf("action %s vs %s", e.action, other.action)
+	case e.amount != other.amount:
+		return fmt.Sprintf("amount %s vs %s", e.amount, other.amount)
+	case e.account != other.account:
+		return fmt.Sprintf("account %q vs %q", e.account, other.account)
+	}
+	return ""
+}
+
+func (e *reconcileEntry) sameTime(other *reconcileEntry) bool {
+	gap := e.at.Sub(other.at)
+	return gap < reconcileWindow && gap > -reconcileWindow
+}
+
+// commandAccountID returns the ID of the single account cmd acts on, or ""
+// if it doesn't act on one.
+func commandAccountID(cmd Command) string {
+	switch c := cmd.(type) {
+	case *BankAccountCommand:
+		if c.account != nil {
+			return c.account.ID
+		}
+		return c.accountID
+	case *InterestCommand:
+		return c.account.ID
+	case *CloseAccountCommand:
+		return c.account.ID
+	}
+	return ""
+}
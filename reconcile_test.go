@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// bankCopy returns cmd as another system would have recorded it: the same
+// ID and leg, decoded from JSON.
+func bankCopy(t *testing.T, cmd *BankAccountCommand) *BankAccountCommand {
+	t.Helper()
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var copied BankAccountCommand
+	if err := json.Unmarshal(data, &copied); err != nil {
+		t.Fatal(err)
+	}
+	return &copied
+}
+
+func TestReconcile(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	acct, _ := statementAccounts(clock)
+	deposit := NewBankAccountCommand(acct, Deposit, 50)
+	withdrawal := NewBankAccountCommand(acct, Withdraw, 20)
+	fee := NewBankAccountCommand(acct, Fee, 5)
+	unbooked := NewBankAccountCommand(acct, Deposit, 7)
+	runHourly(clock, deposit, withdrawal, fee, unbooked)
+
+	// The bank has the fee under its own ID, half a second off ours.
+	bank := NewBankAccount(0, 0)
+	bank.ID, bank.Clock = "acct", NewFakeClock(fee.ExecutedAt().Add(500*time.Millisecond))
+	bankFee := NewBankAccountCommand(bank, Fee, 5)
+	bankOnly := NewBankAccountCommand(bank, Deposit, 3)
+	misbooked := bankCopy(t, withdrawal)
+	misbooked.amount = NewMoney(25)
+
+	report := Reconcile(
+		[]Command{deposit, withdrawal, fee, unbooked},
+		[]Command{bankOnly, bankFee, misbooked, bankCopy(t, deposit)},
+	)
+	if report.Matched != 2 || report.Balanced() {
+		t.Fatalf("matched %d, balanced %v; want the deposit and fee matched and discrepancies", report.Matched, report.Balanced())
+	}
+	want := []struct {
+		kind   DiscrepancyKind
+		a, b   Command
+		detail string
+	}{
+		{Mismatched, withdrawal, misbooked, "amount 20.00 vs 25.00"},
+		{OnlyInA, unbooked, nil, unbooked.Describe()},
+		{OnlyInB, nil, bankOnly, bankOnly.Describe()},
+	}
+	if len(report.Discrepancies) != len(want) {
+		t.Fatalf("%d discrepancies, want %d: %+v", len(report.Discrepancies), len(want), report.Discrepancies)
+	}
+	for i, d := range report.Discrepancies {
+		if d.Kind != want[i].kind || d.A != want[i].a || d.B != want[i].b || d.Detail != want[i].detail {
+			t.Errorf("discrepancy %d = %s %q, want %s %q", i, d.Kind, d.Detail, want[i].kind, want[i].detail)
+		}
+	}
+}
+
+func TestReconcilePairing(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	acct, other := statementAccounts(clock)
+	transfer, err := NewMoneyTransferCommand(acct, other, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee := NewBankAccountCommand(acct, Fee, 5)
+	runHourly(clock, transfer, fee)
+
+	late := NewBankAccount(0, 0)
+	late.ID, late.Clock = "acct", NewFakeClock(fee.ExecutedAt().Add(2*time.Second))
+	lateFee := NewBankAccountCommand(late, Fee, 5)
+	moved := NewBankAccount(0, 0)
+	moved.ID, moved.Clock = "other", NewFakeClock(fee.ExecutedAt())
+	movedFee := NewBankAccountCommand(moved, Fee, 5)
+
+	tests := []struct {
+		name    string
+		b       []Command
+		matched int
+		kinds   []DiscrepancyKind
+	}{
+		{"the same log", []Command{transfer, fee}, 3, nil},
+		{"outside the time window", []Command{transfer, lateFee}, 2, []DiscrepancyKind{OnlyInA, OnlyInB}},
+		{"another account", []Command{transfer, movedFee}, 2, []DiscrepancyKind{OnlyInA, OnlyInB}},
+		{"an empty log", nil, 0, []DiscrepancyKind{OnlyInA, OnlyInA, OnlyInA}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Reconcile([]Command{transfer, fee}, tt.b)
+			if report.Matched != tt.matched || report.Balanced() != (len(tt.kinds) == 0) {
+				t.Fatalf("matched %d, balanced %v; want %d, %v", report.Matched, report.Balanced(), tt.matched, len(tt.kinds) == 0)
+			}
+			if len(report.Discrepancies) != len(tt.kinds) {
+				t.Fatalf("%d discrepancies, want %d: %+v", len(report.Discrepancies), len(tt.kinds), report.Discrepancies)
+			}
+			for i, d := range report.Discrepancies {
+				if d.Kind != tt.kinds[i] {
+					t.Errorf("discrepancy %d is %s, want %s", i, d.Kind, tt.kinds[i])
+				}
+			}
+		})
+	}
+}
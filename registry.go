@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrDuplicateAccount = errors.New("duplicate account ID")
+	ErrMissingAccountID = errors.New("account ID must not be empty")
+)
+
+// Registry looks accounts up by ID, so commands can be built from external
+// input that only carries identifiers. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	accounts map[string]*BankAccount
+
+	// createPayees makes Transfer open missing destination accounts.
+	createPayees bool
+
+	// keys maps idempotency keys to the transfers built for them; keyOrder
+	// lists the keys oldest first, for eviction.
+	keys     map[string]keyedTransfer
+	keyOrder []string
+
+	// keyLocks serializes requests sharing an idempotency key, see LockKey.
+	keyLocks map[string]*keyLock
+}
+
+func NewRegistry() *Registry {
+	return &Registry{accounts: make(map[string]*BankAccount)}
+}
+
+func (r *Registry) Add(account *BankAccount) error {
+	if account.ID == "" {
+		return ErrMissingAccountID
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.accounts[account.ID]; exists {
+		return fmt.Errorf("%w %q", ErrDuplicateAccount, account.ID)
+	}
+	r.accounts[account.ID] = account
+	return nil
+}
+
+func (r *Registry) Get(id string) (*BankAccount, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	account, ok := r.accounts[id]
+	return account, ok
+}
+
+// SetCreatePayees controls whether Transfer opens a destination account that
+// doesn't exist yet, as for a first-time payee. It is off by default, so a
+// mistyped ID fails rather than quietly opening an account.
+func (r *Registry) SetCreatePayees(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.createPayees = enabled
+}
+
+// Transfer builds, but does not call, a transfer between two registered
+// accounts. A missing destination is an ErrUnknownAccount unless
+// SetCreatePayees is on, in which case it is opened with a zero balance, no
+// overdraft and the source's currency, and registered. The account is only
+// registered once the transfer has been built, and two transfers racing to
+// the same new payee end up sharing one account.
+//
+// A non-empty idempotencyKey makes client retries safe: a later call with
+// the same key returns the very command built the first time, with its
+// outcome, instead of a new one, so a caller that skips commands already
+// executed moves the money only once. Reusing a key for a different
+// transfer fails with ErrIdempotencyConflict. Only transfers that could be
+// built are remembered, and only the most recent keys are kept.
+func (r *Registry) Transfer(fromID, toID string, amount float64, idempotencyKey string) (*MoneyTransferCommand, error) {
+	if idempotencyKey == "" {
+		return r.transfer(fromID, toID, amount)
+	}
+	request := keyedTransfer{fromID: fromID, toID: toID, amount: NewMoney(amount)}
+	if cmd, ok, err := r.keyedTransfer(idempotencyKey, request); ok {
+		return cmd, err
+	}
+	cmd, err := r.transfer(fromID, toID, amount)
+	if err != nil {
+		return nil, err
+	}
+	request.cmd = cmd
+	return r.rememberTransfer(idempotencyKey, request)
+}
+
+func (r *Registry) transfer(fromID, toID string, amount float64) (*MoneyTransferCommand, error) {
+	from, ok := r.Get(fromID)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownAccount, fromID)
+	}
+	to, ok := r.Get(toID)
+	if ok {
+		return NewMoneyTransferCommand(from, to, amount)
+	}
+	r.mu.RLock()
+	create := r.createPayees
+	r.mu.RUnlock()
+	if !create {
+		return nil, fmt.Errorf("%w %q", ErrUnknownAccount, toID)
+	}
+	if toID == "" {
+		return nil, ErrMissingAccountID
+	}
+	payee := NewBankAccount(0, 0)
+	payee.ID = toID
+	payee.Currency = from.Currency
+	cmd, err := NewMoneyTransferCommand(from, payee, amount)
+	if err != nil {
+		return nil, err
+	}
+	if existing := r.addIfAbsent(payee); existing != payee {
+		return NewMoneyTransferCommand(from, existing, amount)
+	}
+	return cmd, nil
+}
+
+// addIfAbsent registers account unless its ID is taken, and returns the
+// account registered under the ID either way.
+func (r *Registry) addIfAbsent(account *BankAccount) *BankAccount {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.accounts[account.ID]; ok {
+		return existing
+	}
+	r.accounts[account.ID] = account
+	return account
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestTransferToUnknownPayee(t *testing.T) {
+	reg := queueRegistry(t)
+	if _, err := reg.Transfer("a", "new", 10, ""); !errors.Is(err, ErrUnknownAccount) {
+		t.Fatalf("Transfer = %v, want ErrUnknownAccount", err)
+	}
+	if _, ok := reg.Get("new"); ok {
+		t.Fatal("payee opened with SetCreatePayees off")
+	}
+	if _, err := reg.Transfer("nobody", "a", 10, ""); !errors.Is(err, ErrUnknownAccount) {
+		t.Fatalf("Transfer from a missing source = %v, want ErrUnknownAccount", err)
+	}
+}
+
+func TestTransferCreatesPayee(t *testing.T) {
+	reg := queueRegistry(t)
+	reg.SetCreatePayees(true)
+	from, _ := reg.Get("a")
+	from.Currency = "EUR"
+	cmd, err := reg.Transfer("a", "new", 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.Call()
+	payee, ok := reg.Get("new")
+	if !ok {
+		t.Fatal("payee not registered")
+	}
+	if payee.Balance() != 10 || from.Balance() != 90 {
+		t.Fatalf("balances %v, %v; want 90, 10", from.Balance(), payee.Balance())
+	}
+	if payee.Currency != "EUR" || payee.OverdraftAvailable() != 0 {
+		t.Fatalf("payee in %q with %v overdraft headroom, want EUR with none", payee.Currency, payee.OverdraftAvailable())
+	}
+}
+
+// A transfer that can't be built doesn't leave a payee behind.
+func TestTransferCreatesPayeeOnlyOnSuccess(t *testing.T) {
+	reg := queueRegistry(t)
+	reg.SetCreatePayees(true)
+	if _, err := reg.Transfer("a", "new", -10, ""); !errors.Is(err, ErrNegativeAmount) {
+		t.Fatalf("Transfer = %v, want ErrNegativeAmount", err)
+	}
+	if _, ok := reg.Get("new"); ok {
+		t.Fatal("payee opened for a transfer that failed to build")
+	}
+	if _, err := reg.Transfer("a", "", 10, ""); !errors.Is(err, ErrMissingAccountID) {
+		t.Fatalf("Transfer to an empty ID = %v, want ErrMissingAccountID", err)
+	}
+}
+
+func TestTransfersRacingToNewPayeeShareAccount(t *testing.T) {
+	reg := queueRegistry(t)
+	reg.SetCreatePayees(true)
+	cmds := make([]*MoneyTransferCommand, 10)
+	var wg sync.WaitGroup
+	for i := range cmds {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cmd, err := reg.Transfer("a", "new", 1, "")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			cmds[i] = cmd
+			cmd.Call()
+		}(i)
+	}
+	wg.Wait()
+	payee, _ := reg.Get("new")
+	if payee.Balance() != 10 {
+		t.Fatalf("payee balance %v, want 10 from all %d transfers", payee.Balance(), len(cmds))
+	}
+}
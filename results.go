@@ -0,0 +1,58 @@
+package main
+
+// CommandResult is the outcome of one child of a composite.
+type CommandResult struct {
+	Index       int
+	Description string
+
+	// Ran reports whether the child was called. A child that ran but is
+	// neither succeeded nor failed was rolled back after a later sibling
+	// failed.
+	Ran       bool
+	Succeeded bool
+	Err       error
+
+	// Balance is the balance the child left on its account, or for a
+	// transfer on the source account. HasBalance is false for children
+	// that didn't succeed or don't touch a single account.
+	Balance    Money
+	HasBalance bool
+}
+
+// Results reports on each child in the order they run, so a batch can tell
+// exactly which legs failed and why rather than just whether all of them
+// succeeded. Index is the child's position among the composite's children,
+// which differs from its place in the results under SetOrder.
+func (c *CompositeBankAccountCommand) Results() []CommandResult {
+	results := make([]CommandResult, len(c.commands))
+	for i := range c.commands {
+		cmd, index := c.child(i), i
+		if c.sequence != nil {
+			index = c.sequence[i]
+		}
+		result := CommandResult{
+			Index:       index,
+			Description: cmd.Describe(),
+			Ran:         !cmd.ExecutedAt().IsZero(),
+			Succeeded:   cmd.Succeeded(),
+			Err:         cmd.Err(),
+		}
+		if leg := balanceLeg(cmd); leg != nil && result.Succeeded {
+			result.Balance = leg.balanceAfter
+			result.HasBalance = true
+		}
+		results[i] = result
+	}
+	return results
+}
+
+// balanceLeg returns the command whose balanceAfter describes cmd's outcome.
+func balanceLeg(cmd Command) *BankAccountCommand {
+	switch c := cmd.(type) {
+	case *BankAccountCommand:
+		return c
+	case *MoneyTransferCommand:
+		return c.commands[0].(*BankAccountCommand)
+	}
+	return nil
+}
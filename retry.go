@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// temporaryError is a sentinel for failures that may clear up by
+// themselves, such as ErrVersionConflict or ErrRateLimited.
+type temporaryError struct {
+	msg string
+}
+
+func (e *temporaryError) Error() string {
+	return e.msg
+}
+
+func (e *temporaryError) Temporary() bool {
+	return true
+}
+
+// IsTemporary reports whether err, or any error it wraps, has a
+// Temporary() bool method that returns true. Errors without one, such as
+// ErrOverdraftExceeded or ErrNegativeAmount, are permanent.
+func IsTemporary(err error) bool {
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
+
+// DefaultRetryBackoff is the wait before the first retry. Each further
+// retry waits twice as long as the one before.
+const DefaultRetryBackoff = 10 * time.Millisecond
+
+// RetryCommand calls inner again, up to a total of attempts, as long as it
+// fails with a temporary error. A permanent failure ends it straight away.
+// Retrying an unchanged command only helps with errors that clear up by
+// themselves; for a version conflict, use OnRetry to re-read the account
+// and refresh the expected version.
+type RetryCommand struct {
+	id       string
+	inner    Command
+	attempts int
+	backoff  time.Duration
+	onRetry  func(attempt int, err error)
+	tries    int
+}
+
+type RetryOption func(*RetryCommand)
+
+// WithBackoff sets the wait before the first retry.
+func WithBackoff(backoff time.Duration) RetryOption {
+	return func(c *RetryCommand) {
+		c.backoff = backoff
+	}
+}
+
+// OnRetry runs fn before each retry with the number of the attempt about to
+// be made, starting at 2, and the error that triggered it.
+func OnRetry(fn func(attempt int, err error)) RetryOption {
+	return func(c *RetryCommand) {
+		c.onRetry = fn
+	}
+}
+
+// NewRetryCommand makes at least one attempt, whatever attempts is.
+func NewRetryCommand(inner Command, attempts int, opts ...RetryOption) *RetryCommand {
+	c := &RetryCommand{id: newCommandID(), inner: inner, attempts: max(attempts, 1), backoff: DefaultRetryBackoff}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *RetryCommand) Call() {
+	wait := c.backoff
+	for c.tries = 1; ; c.tries++ {
+		c.inner.Call()
+		err := c.inner.Err()
+		if c.inner.Succeeded() || !IsTemporary(err) || c.tries == c.attempts {
+			return
+		}
+		time.Sleep(wait)
+		wait *= 2
+		if c.onRetry != nil {
+			c.onRetry(c.tries+1, err)
+		}
+	}
+}
+
+// Attempts returns how many times the last Call called inner.
+func (c *RetryCommand) Attempts() int {
+	return c.tries
+}
+
+func (c *RetryCommand) Undo() error {
+	return c.inner.Undo()
+}
+
+// Prepare and Commit pass straight through to inner: a prepare is never
+// retried.
+func (c *RetryCommand) Prepare() error {
+	return c.inner.Prepare()
+}
+
+func (c *RetryCommand) Commit() {
+	c.inner.Commit()
+}
+
+func (c *RetryCommand) Rollback() {
+	c.inner.Rollback()
+}
+
+func (c *RetryCommand) Validate() error {
+	return c.inner.Validate()
+}
+
+func (c *RetryCommand) Succeeded() bool {
+	return c.inner.Succeeded()
+}
+
+func (c *RetryCommand) SetSucceeded(value bool) {
+	c.inner.SetSucceeded(value)
+}
+
+func (c *RetryCommand) Err() error {
+	return c.inner.Err()
+}
+
+func (c *RetryCommand) CreatedAt() time.Time {
+	return c.inner.CreatedAt()
+}
+
+func (c *RetryCommand) ExecutedAt() time.Time {
+	return c.inner.ExecutedAt()
+}
+
+func (c *RetryCommand) CommandID() string {
+	if c.id == "" {
+		c.id = newCommandID()
+	}
+	return c.id
+}
+
+func (c *RetryCommand) Reverse() Command {
+	return c.inner.Reverse()
+}
+
+func (c *RetryCommand) Clone() Command {
+	return &RetryCommand{
+		id:       newCommandID(),
+		inner:    c.inner.Clone(),
+		attempts: c.attempts,
+		backoff:  c.backoff,
+		onRetry:  c.onRetry,
+	}
+}
+
+func (c *RetryCommand) Name() string {
+	return c.inner.Name()
+}
+
+func (c *RetryCommand) Describe() string {
+	return fmt.Sprintf("%s (up to %d attempts)", c.inner.Describe(), c.attempts)
+}
+
+func (c *RetryCommand) Priority() int {
+	return priorityOf(c.inner)
+}
+
+func (c *RetryCommand) children() []Command {
+	return []Command{c.inner}
+}
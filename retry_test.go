@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{ErrVersionConflict, true},
+		{ErrRateLimited, true},
+		{ErrQueueFull, true},
+		{fmt.Errorf("withdraw: %w", ErrVersionConflict), true},
+		{ErrOverdraftExceeded, false},
+		{ErrNegativeAmount, false},
+		{errors.New("boom"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsTemporary(tt.err); got != tt.want {
+			t.Errorf("IsTemporary(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// The first attempt conflicts with an update made since the read; OnRetry
+// refreshes the expected version and the second attempt goes through.
+func TestRetryAfterVersionConflict(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	inner := NewBankAccountCommand(account, Withdraw, 10)
+	inner.ExpectVersion(account.Version())
+	account.Deposit(5)
+	var retried []error
+	retry := NewRetryCommand(inner, 3, WithBackoff(time.Millisecond), OnRetry(func(attempt int, err error) {
+		retried = append(retried, err)
+		inner.ExpectVersion(account.Version())
+	}))
+	retry.Call()
+	if !retry.Succeeded() || retry.Attempts() != 2 {
+		t.Fatalf("succeeded %v after %d attempts (%v); want success on the second", retry.Succeeded(), retry.Attempts(), retry.Err())
+	}
+	if len(retried) != 1 || !errors.Is(retried[0], ErrVersionConflict) {
+		t.Fatalf("OnRetry saw %v, want one ErrVersionConflict", retried)
+	}
+	if account.Balance() != 95 {
+		t.Fatalf("balance %v, want 95", account.Balance())
+	}
+	if err := retry.Undo(); err != nil || account.Balance() != 105 {
+		t.Fatalf("Undo: %v, balance %v; want nil, 105", err, account.Balance())
+	}
+}
+
+func TestRetryStopsOnPermanentError(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	retry := NewRetryCommand(NewBankAccountCommand(account, Withdraw, 1000), 5, WithBackoff(time.Millisecond))
+	retry.Call()
+	if retry.Attempts() != 1 || !errors.Is(retry.Err(), ErrOverdraftExceeded) {
+		t.Fatalf("%d attempts, err %v; want 1, ErrOverdraftExceeded", retry.Attempts(), retry.Err())
+	}
+}
+
+func TestRetryGivesUpAfterAttempts(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	stale := NewBankAccountCommand(account, Withdraw, 1)
+	stale.ExpectVersion(-1)
+	retry := NewRetryCommand(stale, 3, WithBackoff(time.Millisecond))
+	retry.Call()
+	if retry.Attempts() != 3 || !errors.Is(retry.Err(), ErrVersionConflict) {
+		t.Fatalf("%d attempts, err %v; want 3, ErrVersionConflict", retry.Attempts(), retry.Err())
+	}
+	if account.Balance() != 100 {
+		t.Fatalf("balance %v, want 100", account.Balance())
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// RoundingMode decides how a computed amount with fractional cents, such as
+// accrued interest or a converted transfer, is rounded to whole cents.
+type RoundingMode int
+
+const (
+	// HalfEven rounds to the nearest cent and ties to the even cent, so
+	// rounding errors don't accumulate in one direction. It is the default.
+	HalfEven RoundingMode = iota
+	// HalfUp rounds to the nearest cent and ties away from zero.
+	HalfUp
+	// Floor rounds down, towards negative infinity.
+	Floor
+)
+
+// roundingEpsilon absorbs float64 error in amount * rate, so that a product
+// meant to be exactly 12.5 cents is treated as a tie rather than as
+// 12.4999999.
+const roundingEpsilon = 1e-6
+
+func (mode RoundingMode) String() string {
+	switch mode {
+	case HalfEven:
+		return "HalfEven"
+	case HalfUp:
+		return "HalfUp"
+	case Floor:
+		return "Floor"
+	}
+	return fmt.Sprintf("RoundingMode(%d)", int(mode))
+}
+
+// Round converts amount, in currency units, to Money using mode. Like
+// NewMoney it gives invalidMoney for amounts that aren't finite.
+func (mode RoundingMode) Round(amount float64) Money {
+	cents := amount * 100
+	whole := math.Floor(cents)
+	fraction := cents - whole
+	switch mode {
+	case Floor:
+		if fraction > 1-roundingEpsilon {
+			whole++
+		}
+		return centsToMoney(whole)
+	case HalfUp:
+		if math.Abs(fraction-0.5) < roundingEpsilon {
+			if cents > 0 {
+				whole++
+			}
+			return centsToMoney(whole)
+		}
+	default:
+		if math.Abs(fraction-0.5) < roundingEpsilon {
+			if math.Mod(whole, 2) != 0 {
+				whole++
+			}
+			return centsToMoney(whole)
+		}
+	}
+	if fraction > 0.5 {
+		whole++
+	}
+	return centsToMoney(whole)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrScenarioFailed = errors.New("scenario failed")
+
+// UndoAction is the ScenarioStep action that undoes the most recent step
+// instead of running a command.
+const UndoAction = "undo"
+
+// Scenario is an integration test written as data: the accounts to open,
+// the steps to run against them and the balances they must end with. Its
+// JSON form is
+//
+//	{
+//	  "name": "overdraft is rejected",
+//	  "accounts": [{"id": "alice", "balance": 100}],
+//	  "steps": [{"action": "withdraw", "amount": 150, "from": "alice", "expect_error": "overdraft_exceeded"}],
+//	  "expect": {"alice": 100}
+//	}
+type Scenario struct {
+	Name     string            `json:"name"`
+	Accounts []ScenarioAccount `json:"accounts"`
+	Steps    []ScenarioStep    `json:"steps"`
+	Expect   map[string]Money  `json:"expect"`
+}
+
+// ScenarioAccount is an account a scenario opens before its first step.
+// OverdraftLimit is negative, as for NewBankAccount.
+type ScenarioAccount struct {
+	ID             string `json:"id"`
+	Balance        Money  `json:"balance"`
+	OverdraftLimit Money  `json:"overdraft_limit,omitempty"`
+}
+
+// ScenarioStep is a CommandSpec to execute, or UndoAction. ExpectError, when
+// set, is the error code the step must fail with, as the HTTP API reports
+// it, such as "overdraft_exceeded" or "unknown_account".
+type ScenarioStep struct {
+	CommandSpec
+	ExpectError string `json:"expect_error,omitempty"`
+}
+
+func (step ScenarioStep) String() string {
+	if step.undo() {
+		return UndoAction
+	}
+	return step.CommandSpec.String()
+}
+
+func (step ScenarioStep) undo() bool {
+	return strings.EqualFold(step.Action, UndoAction)
+}
+
+// Run opens the scenario's accounts in a fresh registry, executes its steps
+// in order through a CommandManager and then compares the balances with
+// Expect. It stops at the first step that doesn't turn out as expected,
+// since the balances after it mean nothing, and otherwise reports every
+// balance that is off. Failures wrap ErrScenarioFailed.
+func Run(s Scenario) error {
+	reg := NewRegistry()
+	for _, a := range s.Accounts {
+		account := NewBankAccount(a.Balance.Float64(), a.OverdraftLimit.Float64())
+		account.ID = a.ID
+		if err := reg.Add(account); err != nil {
+			return fmt.Errorf("%w %q: open %q: %w", ErrScenarioFailed, s.Name, a.ID, err)
+		}
+	}
+	manager := NewCommandManager()
+	for i, step := range s.Steps {
+		if err := runStep(step, reg, manager); err != nil {
+			return fmt.Errorf("%w %q: step %d (%s): %w", ErrScenarioFailed, s.Name, i+1, step, err)
+		}
+	}
+	ids := make([]string, 0, len(s.Expect))
+	for id := range s.Expect {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var errs []error
+	for _, id := range ids {
+		account, ok := reg.Get(id)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w %q", ErrUnknownAccount, id))
+			continue
+		}
+		if got, want := account.BalanceMoney(), s.Expect[id]; got != want {
+			errs = append(errs, fmt.Errorf("%s: balance %s, want %s", id, got, want))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w %q: %w", ErrScenarioFailed, s.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+// runStep runs step and checks its outcome against ExpectError.
+func runStep(step ScenarioStep, reg *Registry, manager *CommandManager) error {
+	var err error
+	if step.undo() {
+		err = manager.Undo()
+	} else if cmd, buildErr := BuildCommand(step.CommandSpec, reg); buildErr != nil {
+		err = buildErr
+	} else {
+		manager.Execute(cmd)
+		err = cmd.Err()
+	}
+	switch {
+	case step.ExpectError == "":
+		return err
+	case err == nil:
+		return fmt.Errorf("succeeded, want %s", step.ExpectError)
+	}
+	if code := ErrorCode(err); code != step.ExpectError {
+		return fmt.Errorf("failed with %s, want %s: %w", code, step.ExpectError, err)
+	}
+	return nil
+}
+
+// exampleScenarios show the format and run in the demo.
+var exampleScenarios = []Scenario{
+	{
+		Name:     "transfer moves money",
+		Accounts: []ScenarioAccount{{ID: "alice", Balance: 10000}, {ID: "bob"}},
+		Steps: []ScenarioStep{
+			{CommandSpec: CommandSpec{Action: TransferAction, Amount: 2500, From: "alice", To: "bob"}},
+		},
+		Expect: map[string]Money{"alice": 7500, "bob": 2500},
+	},
+	{
+		Name:     "overdraft is rejected",
+		Accounts: []ScenarioAccount{{ID: "alice", Balance: 10000, OverdraftLimit: -2000}},
+		Steps: []ScenarioStep{
+			{CommandSpec: CommandSpec{Action: "withdraw", Amount: 15000, From: "alice"}, ExpectError: "overdraft_exceeded"},
+			{CommandSpec: CommandSpec{Action: "withdraw", Amount: 11000, From: "alice"}},
+		},
+		Expect: map[string]Money{"alice": -1000},
+	},
+	{
+		Name:     "undo restores balances",
+		Accounts: []ScenarioAccount{{ID: "alice", Balance: 10000}, {ID: "bob"}},
+		Steps: []ScenarioStep{
+			{CommandSpec: CommandSpec{Action: "deposit", Amount: 500, From: "bob"}},
+			{CommandSpec: CommandSpec{Action: TransferAction, Amount: 4000, From: "alice", To: "bob"}},
+			{CommandSpec: CommandSpec{Action: UndoAction}},
+		},
+		Expect: map[string]Money{"alice": 10000, "bob": 500},
+	},
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrSchedulerStopped = errors.New("scheduler stopped")
+
+// ErrQueueFull is temporary: the queue drains as entries run.
+var ErrQueueFull error = &temporaryError{"scheduler queue full"}
+
+// ScheduledCommand is a pending entry in a Scheduler. A non-zero Every makes
+// the entry recur at that interval after each run.
+type ScheduledCommand struct {
+	ID    int
+	At    time.Time
+	Every time.Duration
+	build func() Command
+}
+
+// ScheduleResult reports one execution of a scheduled command.
+type ScheduleResult struct {
+	ID      int
+	At      time.Time
+	Command Command
+	Err     error
+}
+
+type ScheduleOption func(*ScheduledCommand)
+
+// Every makes a scheduled entry recur at the given interval.
+func Every(interval time.Duration) ScheduleOption {
+	return func(e *ScheduledCommand) {
+		e.Every = interval
+	}
+}
+
+// Scheduler calls commands when their time arrives, on a background
+// goroutine started by NewScheduler. onResult, if not nil, is called on that
+// goroutine after every execution.
+type Scheduler struct {
+	mu       sync.Mutex
+	entries  map[int]*ScheduledCommand
+	nextID   int
+	paused   bool
+	capacity int
+	clock    Clock
+	// firing counts the due entries runDue has taken out of entries but
+	// not yet started.
+	firing   int
+	onResult func(ScheduleResult)
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewScheduler(onResult func(ScheduleResult)) *Scheduler {
+	s := &Scheduler{
+		entries:  make(map[int]*ScheduledCommand),
+		onResult: onResult,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	s.SetClock(DefaultClock)
+	go s.run()
+	return s
+}
+
+// Schedule calls cmd at the given time and returns an ID for Cancel. Because
+// a command is never applied twice, a recurring entry should be scheduled
+// with ScheduleFunc so each run gets a fresh command.
+func (s *Scheduler) Schedule(cmd Command, at time.Time, opts ...ScheduleOption) (int, error) {
+	return s.ScheduleFunc(func() Command { return cmd }, at, opts...)
+}
+
+// ScheduleFunc calls the command returned by build each time the entry
+// fires. It fails with ErrQueueFull if the queue is at capacity.
+func (s *Scheduler) ScheduleFunc(build func() Command, at time.Time, opts ...ScheduleOption) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.stop:
+		return 0, ErrSchedulerStopped
+	default:
+	}
+	if s.capacity > 0 && s.depthLocked() >= s.capacity {
+		return 0, fmt.Errorf("%w: %d entries", ErrQueueFull, s.capacity)
+	}
+	s.nextID++
+	entry := &ScheduledCommand{ID: s.nextID, At: at, build: build}
+	for _, opt := range opts {
+		opt(entry)
+	}
+	s.entries[entry.ID] = entry
+	s.notify()
+	return entry.ID, nil
+}
+
+// SetCapacity bounds the queue, so producers that outpace execution get
+// ErrQueueFull from Schedule and ScheduleFunc instead of growing it without
+// limit. Entries already queued are kept even if there are more than n.
+// Zero, the default, means unbounded. Together with a RateLimiter on the
+// commands' manager it covers both ends of the flow.
+func (s *Scheduler) SetCapacity(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capacity = n
+}
+
+// QueueDepth returns the number of entries waiting to run: those pending
+// and those that have fallen due but not yet started. A recurring entry
+// counts once while it waits, and once more for each run that is due.
+func (s *Scheduler) QueueDepth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.depthLocked()
+}
+
+// depthLocked expects the caller to hold s.mu.
+func (s *Scheduler) depthLocked() int {
+	return len(s.entries) + s.firing
+}
+
+// Cancel removes a pending entry, reporting whether it was found.
+func (s *Scheduler) Cancel(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		return false
+	}
+	delete(s.entries, id)
+	s.notify()
+	return true
+}
+
+// Stop cancels all pending entries and waits for a command that is already
+// running to finish. It is safe to call more than once.
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		close(s.stop)
+		s.entries = make(map[int]*ScheduledCommand)
+		s.mu.Unlock()
+	})
+	<-s.done
+}
+
+// SetClock makes the scheduler decide what is due by clock rather than
+// DefaultClock. With a FakeClock entries fire as soon as Set or Advance
+// takes the fake time past them, however long they are due in real time.
+func (s *Scheduler) SetClock(clock Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = clock
+	if w, ok := clock.(clockWatcher); ok {
+		w.watch(s.wake)
+	}
+	s.notify()
+}
+
+// now reads the scheduler's clock.
+func (s *Scheduler) now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clock.Now()
+}
+
+// Pause holds back every entry, for a maintenance window, without dropping
+// any. Entries that fall due while paused wait for Resume; a batch that is
+// already firing runs to completion. Stop still works while paused.
+func (s *Scheduler) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = true
+	s.notify()
+}
+
+// Resume lets a paused scheduler run again. Everything that fell due in the
+// meantime fires at once, in order, including each missed run of a
+// recurring entry.
+func (s *Scheduler) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = false
+	s.notify()
+}
+
+// Paused reports whether the scheduler is paused.
+func (s *Scheduler) Paused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// Pending returns a copy of the waiting entries, earliest first.
+func (s *Scheduler) Pending() []ScheduledCommand {
+	s.mu.Lock()
+	pending := make([]ScheduledCommand, 0, len(s.entries))
+	for _, entry := range s.entries {
+		pending = append(pending, *entry)
+	}
+	s.mu.Unlock()
+	sortEntries(pending)
+	return pending
+}
+
+func sortEntries(entries []ScheduledCommand) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].At.Equal(entries[j].At) {
+			return entries[i].ID < entries[j].ID
+		}
+		return entries[i].At.Before(entries[j].At)
+	})
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+	timer := time.NewTimer(0)
+	<-timer.C
+	for {
+		s.mu.Lock()
+		next, ok := s.nextDue()
+		if s.paused {
+			ok = false
+		}
+		wait := next.Sub(s.clock.Now())
+		s.mu.Unlock()
+		var fire <-chan time.Time
+		if ok {
+			timer.Reset(wait)
+			fire = timer.C
+		}
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-fire:
+			s.runDue()
+		}
+	}
+}
+
+// nextDue expects the caller to hold s.mu.
+func (s *Scheduler) nextDue() (time.Time, bool) {
+	var next time.Time
+	found := false
+	for _, entry := range s.entries {
+		if !found || entry.At.Before(next) {
+			next, found = entry.At, true
+		}
+	}
+	return next, found
+}
+
+// runDue executes every entry whose time has come, earliest first. A
+// recurring entry that has fallen behind, say after a pause, contributes one
+// run per missed interval.
+func (s *Scheduler) runDue() {
+	s.mu.Lock()
+	if s.paused {
+		s.mu.Unlock()
+		return
+	}
+	now := s.clock.Now()
+	var due []ScheduledCommand
+	for id, entry := range s.entries {
+		for !entry.At.After(now) {
+			due = append(due, *entry)
+			if entry.Every <= 0 {
+				delete(s.entries, id)
+				break
+			}
+			entry.At = entry.At.Add(entry.Every)
+		}
+	}
+	s.firing = len(due)
+	s.mu.Unlock()
+	sortEntries(due)
+	for _, entry := range due {
+		select {
+		case <-s.stop:
+			s.mu.Lock()
+			s.firing = 0
+			s.mu.Unlock()
+			return
+		default:
+		}
+		s.mu.Lock()
+		s.firing--
+		s.mu.Unlock()
+		cmd := entry.build()
+		cmd.Call()
+		if s.onResult != nil {
+			s.onResult(ScheduleResult{ID: entry.ID, At: entry.At, Command: cmd, Err: cmd.Err()})
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// nextResult waits for the scheduler to report a run.
+func nextResult(t *testing.T, results chan ScheduleResult) ScheduleResult {
+	t.Helper()
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing fired")
+		return ScheduleResult{}
+	}
+}
+
+// Filling the queue to capacity refuses further entries with ErrQueueFull,
+// and the depth drops, making room again, as entries fire.
+func TestSchedulerBackpressure(t *testing.T) {
+	s, clock, results := orderScheduler(t)
+	s.SetCapacity(3)
+	account := NewBankAccount(0, 0)
+	start := clock.Now()
+	for i := 1; i <= 3; i++ {
+		if _, err := s.Schedule(NewBankAccountCommand(account, Deposit, 1), start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+	}
+	if depth := s.QueueDepth(); depth != 3 {
+		t.Fatalf("QueueDepth = %d, want 3", depth)
+	}
+	_, err := s.Schedule(NewBankAccountCommand(account, Deposit, 1), start.Add(time.Hour))
+	if !errors.Is(err, ErrQueueFull) || !IsTemporary(err) {
+		t.Fatalf("Schedule on a full queue = %v, want temporary ErrQueueFull", err)
+	}
+	for want := 2; want >= 0; want-- {
+		clock.Advance(time.Hour)
+		nextResult(t, results)
+		if depth := s.QueueDepth(); depth != want {
+			t.Fatalf("QueueDepth = %d after a run, want %d", depth, want)
+		}
+	}
+	if account.Balance() != 3 {
+		t.Fatalf("balance %v, want 3", account.Balance())
+	}
+	if _, err := s.Schedule(NewBankAccountCommand(account, Deposit, 1), clock.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Schedule after draining: %v", err)
+	}
+}
+
+func TestSchedulerCancelMakesRoom(t *testing.T) {
+	s, clock, _ := orderScheduler(t)
+	s.SetCapacity(1)
+	later := clock.Now().Add(time.Hour)
+	id, err := s.Schedule(NewBankAccountCommand(NewBankAccount(0, 0), Deposit, 1), later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Schedule(NewBankAccountCommand(NewBankAccount(0, 0), Deposit, 1), later); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Schedule = %v, want ErrQueueFull", err)
+	}
+	if !s.Cancel(id) {
+		t.Fatal("Cancel didn't find the entry")
+	}
+	if _, err := s.Schedule(NewBankAccountCommand(NewBankAccount(0, 0), Deposit, 1), later); err != nil {
+		t.Fatalf("Schedule after Cancel: %v", err)
+	}
+}
+
+// A recurring entry counts once however many runs lie ahead of it.
+func TestSchedulerRecurringEntryDepth(t *testing.T) {
+	s, clock, results := orderScheduler(t)
+	account := NewBankAccount(0, 0)
+	if _, err := s.ScheduleFunc(func() Command { return NewBankAccountCommand(account, Deposit, 1) }, clock.Now().Add(time.Hour), Every(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Hour)
+		nextResult(t, results)
+		if depth := s.QueueDepth(); depth != 1 {
+			t.Fatalf("run %d: QueueDepth = %d, want 1", i, depth)
+		}
+	}
+	if account.Balance() != 3 {
+		t.Fatalf("balance %v, want 3", account.Balance())
+	}
+}
+
+// Entries that fall due while paused are held, then fire in order on
+// Resume, with one run for each interval a recurring entry missed.
+func TestSchedulerPauseResume(t *testing.T) {
+	s, clock, results := orderScheduler(t)
+	account := NewBankAccount(0, 0)
+	start := clock.Now()
+	recurring, err := s.ScheduleFunc(func() Command { return NewBankAccountCommand(account, Deposit, 1) }, start.Add(time.Hour), Every(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	once, err := s.Schedule(NewBankAccountCommand(account, Deposit, 10), start.Add(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Pause()
+	if !s.Paused() {
+		t.Fatal("Paused = false after Pause")
+	}
+	clock.Advance(3 * time.Hour)
+	select {
+	case r := <-results:
+		t.Fatalf("entry %d fired while paused", r.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if pending := s.Pending(); len(pending) != 2 || account.Balance() != 0 {
+		t.Fatalf("%d entries pending and balance %v while paused, want 2 and 0", len(pending), account.Balance())
+	}
+
+	s.Resume()
+	if s.Paused() {
+		t.Fatal("Paused = true after Resume")
+	}
+	want := []struct {
+		id int
+		at time.Time
+	}{
+		{recurring, start.Add(time.Hour)},
+		{once, start.Add(90 * time.Minute)},
+		{recurring, start.Add(2 * time.Hour)},
+		{recurring, start.Add(3 * time.Hour)},
+	}
+	for i, w := range want {
+		if r := nextResult(t, results); r.ID != w.id || !r.At.Equal(w.at) {
+			t.Fatalf("run %d was entry %d due at %v, want entry %d due at %v", i, r.ID, r.At, w.id, w.at)
+		}
+	}
+	if account.Balance() != 13 {
+		t.Fatalf("balance %v, want 13", account.Balance())
+	}
+	if pending := s.Pending(); len(pending) != 1 || !pending[0].At.Equal(start.Add(4*time.Hour)) {
+		t.Fatalf("pending %+v, want the recurring entry next due at +4h", pending)
+	}
+}
+
+// Stop returns while the scheduler is paused.
+func TestSchedulerStopWhilePaused(t *testing.T) {
+	s, clock, _ := orderScheduler(t)
+	if _, err := s.Schedule(NewBankAccountCommand(NewBankAccount(0, 0), Deposit, 1), clock.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	s.Pause()
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked on a paused scheduler")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// CommandSchemaVersion is the version of the JSON and gob forms written
+// today. Version 1 is the JSON form from before the version field existed,
+// which stored actions as integers.
+const CommandSchemaVersion = 2
+
+var ErrUnsupportedVersion = errors.New("unsupported command schema version")
+
+// JSONMigration upgrades the fields of a serialized command by one schema
+// version, in place.
+type JSONMigration func(fields map[string]json.RawMessage) error
+
+// jsonMigrations maps each version to the migration that upgrades a command
+// from it to the next one.
+var jsonMigrations = map[int]JSONMigration{
+	1: migrateJSONv1,
+}
+
+// RegisterJSONMigration installs the migration that upgrades commands
+// stored at version from to version from+1, replacing any already
+// registered. It is meant to be called at start-up.
+func RegisterJSONMigration(from int, migration JSONMigration) {
+	jsonMigrations[from] = migration
+}
+
+// migrateJSON brings fields up to CommandSchemaVersion, one migration at a
+// time. A payload without a version is taken to be version 1.
+func migrateJSON(fields map[string]json.RawMessage) error {
+	version := 1
+	if raw, ok := fields["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return fmt.Errorf("%w: %s", ErrUnsupportedVersion, raw)
+		}
+	}
+	if version < 1 || version > CommandSchemaVersion {
+		return fmt.Errorf("%w %d", ErrUnsupportedVersion, version)
+	}
+	for ; version < CommandSchemaVersion; version++ {
+		migration, ok := jsonMigrations[version]
+		if !ok {
+			return fmt.Errorf("%w %d: no migration registered", ErrUnsupportedVersion, version)
+		}
+		if err := migration(fields); err != nil {
+			return fmt.Errorf("migrate from version %d: %w", version, err)
+		}
+	}
+	fields["version"] = json.RawMessage(fmt.Sprint(CommandSchemaVersion))
+	return nil
+}
+
+// migrateJSONv1 turns an integer action into its name.
+func migrateJSONv1(fields map[string]json.RawMessage) error {
+	var code int
+	if err := json.Unmarshal(fields["action"], &code); err != nil {
+		// Already a name.
+		return nil
+	}
+	name, err := Action(code).MarshalText()
+	if err != nil {
+		return err
+	}
+	fields["action"], err = json.Marshal(string(name))
+	return err
+}
+
+// checkGobVersion rejects gob payloads written by a newer schema. Version
+// 0 comes from before the version field and has the same layout as 1 and 2.
+func checkGobVersion(wire gobCommand) error {
+	if wire.Version > CommandSchemaVersion {
+		return fmt.Errorf("%w %d", ErrUnsupportedVersion, wire.Version)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestJSONMigratesVersion1(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"integer action", `{"id":"cmd-old","action":1,"amount":25,"account":"acct-1"}`},
+		{"explicit version 1", `{"version":1,"id":"cmd-old","action":1,"amount":25,"account":"acct-1"}`},
+		{"version 1 with a named action", `{"id":"cmd-old","action":"withdraw","amount":25,"account":"acct-1"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cmd BankAccountCommand
+			if err := json.Unmarshal([]byte(tt.payload), &cmd); err != nil {
+				t.Fatal(err)
+			}
+			if cmd.action != Withdraw || cmd.amount != 2500 || cmd.accountID != "acct-1" || cmd.id != "cmd-old" {
+				t.Fatalf("decoded %s %s for %q as %s, want withdraw 25.00 for acct-1 as cmd-old", cmd.action, cmd.amount, cmd.accountID, cmd.id)
+			}
+		})
+	}
+}
+
+func TestJSONRejectsUnsupportedVersions(t *testing.T) {
+	for _, payload := range []string{
+		`{"version":3,"action":"deposit","amount":1,"account":"a"}`,
+		`{"version":0,"action":"deposit","amount":1,"account":"a"}`,
+		`{"version":"two","action":"deposit","amount":1,"account":"a"}`,
+	} {
+		var cmd BankAccountCommand
+		if err := json.Unmarshal([]byte(payload), &cmd); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("%s: %v, want ErrUnsupportedVersion", payload, err)
+		}
+	}
+}
+
+func TestJSONWritesCurrentVersion(t *testing.T) {
+	out, err := json.Marshal(NewBankAccountCommand(NewBankAccount(0, 0), Deposit, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields struct{ Version int }
+	if err := json.Unmarshal(out, &fields); err != nil || fields.Version != CommandSchemaVersion {
+		t.Fatalf("%s: version %d, want %d", out, fields.Version, CommandSchemaVersion)
+	}
+}
+
+func TestRegisterJSONMigration(t *testing.T) {
+	saved := jsonMigrations[1]
+	t.Cleanup(func() { RegisterJSONMigration(1, saved) })
+	RegisterJSONMigration(1, func(fields map[string]json.RawMessage) error {
+		fields["account"] = json.RawMessage(`"migrated"`)
+		return migrateJSONv1(fields)
+	})
+	var cmd BankAccountCommand
+	if err := json.Unmarshal([]byte(`{"action":0,"amount":1,"account":"old"}`), &cmd); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.accountID != "migrated" || cmd.action != Deposit {
+		t.Fatalf("decoded %s for %q, want deposit for the migrated account", cmd.action, cmd.accountID)
+	}
+}
+
+func TestGobRejectsNewerVersion(t *testing.T) {
+	newer := wireLeg(Deposit, 100, "a")
+	newer.Version = CommandSchemaVersion + 1
+	if _, err := LoadQueue(bytes.NewReader(encodeWire(t, newer)), queueRegistry(t)); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("LoadQueue = %v, want ErrUnsupportedVersion", err)
+	}
+	data, err := encodeGob(NewBankAccountCommand(NewBankAccount(0, 0), Deposit, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var current BankAccountCommand
+	if err := current.GobDecode(data); err != nil {
+		t.Fatalf("GobDecode of the current version: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(newer); err != nil {
+		t.Fatal(err)
+	}
+	var cmd BankAccountCommand
+	if err := cmd.GobDecode(buf.Bytes()); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("GobDecode = %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+// Version 0 gob payloads come from before the version field and load
+// unchanged.
+func TestGobLoadsVersion0(t *testing.T) {
+	old := wireLeg(Deposit, 100, "a")
+	old.Version = 0
+	reg := queueRegistry(t)
+	cmds, err := LoadQueue(bytes.NewReader(encodeWire(t, old)), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds[0].Call()
+	if account, _ := reg.Get("a"); account.Balance() != 101 {
+		t.Fatalf("balance %v, want 101", account.Balance())
+	}
+}
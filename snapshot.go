@@ -0,0 +1,84 @@
+package main
+
+import (
+	"maps"
+	"time"
+)
+
+// AccountSnapshot is a copy of an account's balance, holds, limits and
+// flags at one moment. It shares nothing with the account, so later changes
+// to either side don't affect the other.
+type AccountSnapshot struct {
+	Balance              Money
+	Held                 Money
+	OverdraftLimit       Money
+	MinimumBalance       Money
+	HasMinimumBalance    bool
+	Frozen               bool
+	Closed               bool
+	Version              int
+	DailyWithdrawLimit   Money
+	WithdrawnDay         time.Time
+	WithdrawnToday       Money
+	MaxTransactionAmount Money
+	ActionLimits         map[Action]Money
+
+	validators []Validator
+	history    int
+}
+
+func (account *BankAccount) Snapshot() AccountSnapshot {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	snapshot := AccountSnapshot{
+		Balance:              account.balance,
+		Held:                 account.held,
+		OverdraftLimit:       account.overdraftLimit,
+		MinimumBalance:       account.minimumBalance,
+		HasMinimumBalance:    account.hasMinimumBalance,
+		Frozen:               account.frozen,
+		Closed:               account.closed,
+		Version:              account.version,
+		DailyWithdrawLimit:   account.dailyWithdrawLimit,
+		WithdrawnDay:         account.withdrawnDay,
+		WithdrawnToday:       account.withdrawnToday,
+		MaxTransactionAmount: account.maxTransactionAmount,
+		history:              len(account.history),
+	}
+	if account.limits != nil {
+		snapshot.ActionLimits = maps.Clone(account.limits)
+	}
+	if account.validators != nil {
+		snapshot.validators = append([]Validator{}, account.validators...)
+	}
+	return snapshot
+}
+
+// Restore puts the account back into the state captured by snapshot. The
+// account's ID and currency are left alone; the version and balance history
+// go back to where they stood. Unlike undoing commands one by
+// one, restoring can't fail halfway, but it also discards anything else
+// that happened to the account since the snapshot was taken.
+func (account *BankAccount) Restore(snapshot AccountSnapshot) {
+	account.mu.Lock()
+	defer account.unlock()
+	account.balance = snapshot.Balance
+	account.held = snapshot.Held
+	account.overdraftLimit = snapshot.OverdraftLimit
+	account.minimumBalance = snapshot.MinimumBalance
+	account.hasMinimumBalance = snapshot.HasMinimumBalance
+	account.frozen = snapshot.Frozen
+	account.closed = snapshot.Closed
+	account.version = snapshot.Version
+	account.dailyWithdrawLimit = snapshot.DailyWithdrawLimit
+	account.withdrawnDay = snapshot.WithdrawnDay
+	account.withdrawnToday = snapshot.WithdrawnToday
+	account.maxTransactionAmount = snapshot.MaxTransactionAmount
+	account.limits = maps.Clone(snapshot.ActionLimits)
+	account.history = account.history[:min(snapshot.history, len(account.history))]
+	account.validators = nil
+	if snapshot.validators != nil {
+		account.validators = append([]Validator{}, snapshot.validators...)
+	}
+	account.checkThresholds()
+}
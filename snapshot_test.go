@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	account := NewBankAccount(100, -50)
+	account.SetActionLimit(Withdraw, 80)
+	account.Deposit(20)
+	snapshot := account.Snapshot()
+
+	account.Deposit(500)
+	account.Authorize(30)
+	account.Freeze()
+	account.SetMinimumBalance(10)
+	account.SetActionLimit(Withdraw, 0)
+	account.AddValidator(ValidatorFunc(func(*BankAccount, *BankAccountCommand) error { return ErrVetoed }))
+	account.Restore(snapshot)
+
+	if account.Balance() != 120 || account.Held() != 0 || account.Frozen() {
+		t.Fatalf("balance %v, held %v, frozen %v after Restore; want 120, 0, false", account.Balance(), account.Held(), account.Frozen())
+	}
+	if account.Version() != snapshot.Version || len(account.History()) != 1 {
+		t.Fatalf("version %d with %d history entries, want %d with 1", account.Version(), len(account.History()), snapshot.Version)
+	}
+	if limit, ok := account.ActionLimit(Withdraw); !ok || limit != 80 {
+		t.Fatalf("ActionLimit = %v, %v; want 80 restored", limit, ok)
+	}
+	// The minimum balance and the vetoing validator are gone, and the
+	// overdraft limit is back in force.
+	if err := call(NewBankAccountCommand(account, Withdraw, 80)); err != nil {
+		t.Fatalf("withdrawal after Restore: %v", err)
+	}
+	if err := call(NewBankAccountCommand(account, Withdraw, 80)); err != nil {
+		t.Fatalf("withdrawal into the overdraft: %v", err)
+	}
+	if err := call(NewBankAccountCommand(account, Withdraw, 20)); !errors.Is(err, ErrOverdraftExceeded) {
+		t.Fatalf("withdrawal past the restored overdraft limit = %v, want ErrOverdraftExceeded", err)
+	}
+}
+
+// A snapshot shares nothing with its account.
+func TestSnapshotIsIndependent(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	account.SetActionLimit(Deposit, 50)
+	snapshot := account.Snapshot()
+	snapshot.ActionLimits[Deposit] = NewMoney(1)
+	if limit, _ := account.ActionLimit(Deposit); limit != 50 {
+		t.Fatalf("account's limit %v after editing the snapshot, want 50", limit)
+	}
+	account.Restore(snapshot)
+	snapshot.ActionLimits[Deposit] = NewMoney(2)
+	if limit, _ := account.ActionLimit(Deposit); limit != 1 {
+		t.Fatalf("account's limit %v after editing the restored snapshot, want 1", limit)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidSpec = errors.New("invalid command spec")
+
+// TransferAction is the CommandSpec action that builds a MoneyTransferCommand
+// rather than a single-account command.
+const TransferAction = "transfer"
+
+// CommandSpec describes a command in plain data, as it arrives from JSON,
+// HTTP or the command line. Action is a single-account action name such as
+// "deposit" or "Withdraw", or TransferAction. To and IdempotencyKey are only
+// used by transfers; see Registry.Transfer.
+type CommandSpec struct {
+	Action         string `json:"action"`
+	Amount         Money  `json:"amount"`
+	From           string `json:"from"`
+	To             string `json:"to,omitempty"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func (spec CommandSpec) String() string {
+	if spec.To != "" {
+		return fmt.Sprintf("%s %s from %q to %q", spec.Action, spec.Amount, spec.From, spec.To)
+	}
+	return fmt.Sprintf("%s %s on %q", spec.Action, spec.Amount, spec.From)
+}
+
+// BuildCommand turns spec into a command on the accounts in reg, without
+// calling it. It returns a *BankAccountCommand or a *MoneyTransferCommand,
+// and fails with ErrUnknownAction, ErrMissingAccountID, ErrUnknownAccount,
+// ErrNegativeAmount or ErrInvalidSpec when the spec can't be built.
+func BuildCommand(spec CommandSpec, reg *Registry) (Command, error) {
+	if spec.Amount < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNegativeAmount, spec)
+	}
+	from, err := lookupSpecAccount(reg, "from", spec.From)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(spec.Action, TransferAction) {
+		if spec.To == "" {
+			return nil, fmt.Errorf("%w: %q", ErrMissingAccountID, "to")
+		}
+		// The registry resolves the destination, opening it if it creates
+		// payees.
+		return reg.Transfer(from.ID, spec.To, spec.Amount.Float64(), spec.IdempotencyKey)
+	}
+	action, err := ParseAction(spec.Action)
+	if err != nil {
+		return nil, err
+	}
+	if spec.To != "" {
+		return nil, fmt.Errorf("%w: %s takes no destination account", ErrInvalidSpec, action)
+	}
+	return newBankAccountCommand(from, action, spec.Amount), nil
+}
+
+func lookupSpecAccount(reg *Registry, field, id string) (*BankAccount, error) {
+	if id == "" {
+		return nil, fmt.Errorf("%w: %q", ErrMissingAccountID, field)
+	}
+	account, ok := reg.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownAccount, id)
+	}
+	return account, nil
+}
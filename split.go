@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrNoRecipients   = errors.New("split transfer needs at least one recipient")
+	ErrInvalidSplit   = errors.New("split amount must be positive")
+	ErrNoSources      = errors.New("collect transfer needs at least one source")
+	ErrInvalidCollect = errors.New("collect amount must be positive")
+)
+
+// NewSplitTransferCommand builds a composite that withdraws the sum of the
+// recipients' amounts from from in one leg and then deposits each amount.
+// The amounts are rounded to the cent and checked before anything is built.
+// The composite stops on failure, so if from can't cover the whole sum
+// nothing moves, and if a deposit fails the legs before it are undone.
+// Deposits run in a fixed order, whatever the map's iteration order. Listing
+// from among the recipients fails with ErrSelfTransfer.
+func NewSplitTransferCommand(from *BankAccount, recipients map[*BankAccount]float64) (*CompositeBankAccountCommand, error) {
+	if from == nil {
+		return nil, ErrNilAccount
+	}
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+	if _, ok := recipients[from]; ok {
+		return nil, fmt.Errorf("%w: %s is also a recipient", ErrSelfTransfer, accountLabel(from))
+	}
+	accounts, total, err := sortedShares(recipients, ErrInvalidSplit, "to")
+	if err != nil {
+		return nil, err
+	}
+	legs := []Command{newBankAccountCommand(from, Withdraw, total)}
+	for _, account := range accounts {
+		legs = append(legs, newBankAccountCommand(account, Deposit, NewMoney(recipients[account])))
+	}
+	return NewCompositeCommand(true, legs...), nil
+}
+
+// NewCollectCommand is the inbound mirror of NewSplitTransferCommand: it
+// withdraws each source's amount and then deposits the total into to. The
+// composite stops on failure, so if any source is overdrawn or frozen the
+// withdrawals before it are undone and to is never credited. Withdrawals
+// run in a fixed order, whatever the map's iteration order. Listing to among
+// the sources fails with ErrSelfTransfer.
+func NewCollectCommand(to *BankAccount, sources map[*BankAccount]float64) (*CompositeBankAccountCommand, error) {
+	if to == nil {
+		return nil, ErrNilAccount
+	}
+	if len(sources) == 0 {
+		return nil, ErrNoSources
+	}
+	if _, ok := sources[to]; ok {
+		return nil, fmt.Errorf("%w: %s is also a source", ErrSelfTransfer, accountLabel(to))
+	}
+	accounts, total, err := sortedShares(sources, ErrInvalidCollect, "from")
+	if err != nil {
+		return nil, err
+	}
+	legs := make([]Command, 0, len(accounts)+1)
+	for _, account := range accounts {
+		legs = append(legs, newBankAccountCommand(account, Withdraw, NewMoney(sources[account])))
+	}
+	legs = append(legs, newBankAccountCommand(to, Deposit, total))
+	return NewCompositeCommand(true, legs...), nil
+}
+
+// sortedShares checks that every amount in shares is a positive number of
+// cents and returns the accounts in lock order along with the total.
+func sortedShares(shares map[*BankAccount]float64, invalid error, direction string) ([]*BankAccount, Money, error) {
+	accounts := make([]*BankAccount, 0, len(shares))
+	var total Money
+	for account, amount := range shares {
+		if account == nil {
+			return nil, 0, ErrNilAccount
+		}
+		share := NewMoney(amount)
+		if share <= 0 {
+			return nil, 0, fmt.Errorf("%w: %s %s %s", invalid, share, direction, accountLabel(account))
+		}
+		total += share
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].lockOrder() < accounts[j].lockOrder()
+	})
+	return accounts, total, nil
+}
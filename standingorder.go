@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrInvalidDay = errors.New("day of month must be between 1 and 31")
+
+// ShortfallPolicy decides what a StandingOrder does when the source can't
+// cover a payment.
+type ShortfallPolicy int
+
+const (
+	// SkipPayment drops the payment and waits for the next month.
+	SkipPayment ShortfallPolicy = iota
+	// RetryNextDay tries again each day until the payment goes through or
+	// the next month's payment falls due, which drops it.
+	RetryNextDay
+)
+
+// StandingOrder pays the same amount from one account to another every
+// month on a given day, through a Scheduler. A day past the end of a short
+// month, such as the 31st in February, falls on that month's last day. Each
+// run is a ConditionalCommand around a fresh transfer that only goes ahead
+// if the source has the funds; see Runs. A payment that can't be scheduled,
+// for example because the scheduler's queue is full, is reported by Err.
+type StandingOrder struct {
+	scheduler *Scheduler
+	from, to  *BankAccount
+	amount    float64
+	day       int
+	policy    ShortfallPolicy
+
+	mu      sync.Mutex
+	pending map[int]bool
+	runs    []*ConditionalCommand
+	stopped bool
+	err     error
+}
+
+// NewStandingOrder schedules the first payment on the next occurrence of day
+// from now. It fails with ErrInvalidDay, or with any error
+// NewMoneyTransferCommand would return for the accounts and amount.
+func NewStandingOrder(scheduler *Scheduler, from, to *BankAccount, amount float64, day int, policy ShortfallPolicy) (*StandingOrder, error) {
+	if day < 1 || day > 31 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidDay, day)
+	}
+	if _, err := NewMoneyTransferCommand(from, to, amount); err != nil {
+		return nil, err
+	}
+	o := &StandingOrder{scheduler: scheduler, from: from, to: to, amount: amount, day: day, policy: policy, pending: make(map[int]bool)}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	first := o.nextDue(o.scheduler.now())
+	if err := o.scheduleLocked(first, o.nextDue(first), false); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// dueDate returns midnight on the order's day of the given month, or on the
+// month's last day if it is shorter.
+func (o *StandingOrder) dueDate(year int, month time.Month, loc *time.Location) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	return time.Date(year, month, min(o.day, last), 0, 0, 0, 0, loc)
+}
+
+// nextDue returns the first due date strictly after t.
+func (o *StandingOrder) nextDue(t time.Time) time.Time {
+	due := o.dueDate(t.Year(), t.Month(), t.Location())
+	if !due.After(t) {
+		first := time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		due = o.dueDate(first.Year(), first.Month(), t.Location())
+	}
+	return due
+}
+
+// scheduleLocked adds a payment attempt at at. deadline is when the next
+// month's payment falls due; a retry shares the deadline of the attempt it
+// follows and, unlike a regular attempt, doesn't schedule the next month.
+// The caller must hold o.mu.
+func (o *StandingOrder) scheduleLocked(at, deadline time.Time, retry bool) error {
+	var id int
+	id, err := o.scheduler.ScheduleFunc(func() Command { return o.attempt(&id, at, deadline, retry) }, at)
+	if err != nil {
+		return err
+	}
+	o.pending[id] = true
+	return nil
+}
+
+// attempt runs on the scheduler's goroutine when an entry fires. id is read
+// under o.mu, since scheduleLocked may still be storing it.
+func (o *StandingOrder) attempt(id *int, at, deadline time.Time, retry bool) Command {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.pending, *id)
+	if !retry && !o.stopped {
+		if err := o.scheduleLocked(deadline, o.nextDue(deadline), false); err != nil {
+			o.err = fmt.Errorf("scheduling the payment due %s: %w", deadline.Format("2006-01-02"), err)
+		}
+	}
+	transfer, err := NewMoneyTransferCommand(o.from, o.to, o.amount)
+	if err != nil {
+		// The accounts were fine at construction, but their currencies or
+		// RejectZeroAmounts may have changed since.
+		run := NewConditionalCommand(o.from, func(*BankAccount) bool { return true }, &rejectedCommand{account: o.from, what: "payment from " + accountLabel(o.from), err: err})
+		o.runs = append(o.runs, run)
+		return run
+	}
+	run := NewConditionalCommand(o.from, func(*BankAccount) bool {
+		if !shortOfFunds(transfer.Validate()) {
+			return true
+		}
+		retryAt := at.AddDate(0, 0, 1)
+		if o.policy == RetryNextDay && retryAt.Before(deadline) {
+			o.mu.Lock()
+			if !o.stopped {
+				if err := o.scheduleLocked(retryAt, deadline, true); err != nil {
+					o.err = fmt.Errorf("scheduling the retry on %s: %w", retryAt.Format("2006-01-02"), err)
+				}
+			}
+			o.mu.Unlock()
+		}
+		return false
+	}, transfer)
+	o.runs = append(o.runs, run)
+	return run
+}
+
+// shortOfFunds reports whether err means the source can't cover the
+// payment. Other failures, such as a frozen account, let the transfer run
+// and fail with its own error.
+func shortOfFunds(err error) bool {
+	return errors.Is(err, ErrOverdraftExceeded) || errors.Is(err, ErrBelowMinimumBalance) || errors.Is(err, ErrDailyLimitExceeded)
+}
+
+// Runs returns every payment attempt made so far, oldest first. An attempt
+// the source couldn't cover reports Skipped, and one whose transfer could no
+// longer be built fails with the error NewMoneyTransferCommand returned.
+func (o *StandingOrder) Runs() []*ConditionalCommand {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]*ConditionalCommand(nil), o.runs...)
+}
+
+// Err returns the error from the last payment or retry the order couldn't
+// schedule, such as ErrQueueFull or ErrSchedulerStopped, or nil. When it is
+// the next month's payment that couldn't be scheduled, the order makes no
+// further payments.
+func (o *StandingOrder) Err() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.err
+}
+
+// Stop cancels the order's pending payments and schedules no more.
+func (o *StandingOrder) Stop() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stopped = true
+	for id := range o.pending {
+		o.scheduler.Cancel(id)
+	}
+	o.pending = make(map[int]bool)
+}
+
+// rejectedCommand stands in for a command that can't be carried out, such
+// as a payment whose transfer couldn't be built: it changes nothing and
+// fails with err. what names the command for Describe.
+type rejectedCommand struct {
+	id         string
+	account    *BankAccount
+	what       string
+	err        error
+	executedAt time.Time
+}
+
+func (c *rejectedCommand) Call() {
+	c.executedAt = c.account.now()
+}
+
+func (c *rejectedCommand) Undo() error {
+	return nil
+}
+
+func (c *rejectedCommand) CanUndo() bool {
+	return false
+}
+
+func (c *rejectedCommand) Succeeded() bool {
+	return false
+}
+
+func (c *rejectedCommand) SetSucceeded(bool) {}
+
+func (c *rejectedCommand) Err() error {
+	return c.err
+}
+
+func (c *rejectedCommand) CreatedAt() time.Time {
+	return c.executedAt
+}
+
+func (c *rejectedCommand) ExecutedAt() time.Time {
+	return c.executedAt
+}
+
+func (c *rejectedCommand) Reverse() Command {
+	return NewBankAccountCommand(c.account, Deposit, 0)
+}
+
+func (c *rejectedCommand) Clone() Command {
+	return &rejectedCommand{account: c.account, what: c.what, err: c.err}
+}
+
+func (c *rejectedCommand) Name() string {
+	return "Rejected"
+}
+
+func (c *rejectedCommand) Describe() string {
+	return fmt.Sprintf("Rejected %s: %v", c.what, c.err)
+}
+
+func (c *rejectedCommand) CommandID() string {
+	if c.id == "" {
+		c.id = newCommandID()
+	}
+	return c.id
+}
+
+func (c *rejectedCommand) Validate() error {
+	return c.err
+}
+
+func (c *rejectedCommand) Prepare() error {
+	return c.err
+}
+
+func (c *rejectedCommand) Commit() {}
+
+func (c *rejectedCommand) Rollback() {}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestStandingOrderNextDue(t *testing.T) {
+	tests := []struct {
+		name string
+		day  int
+		from time.Time
+		want time.Time
+	}{
+		{"later this month", 31, date(2024, 1, 10), date(2024, 1, 31)},
+		{"leap February", 31, date(2024, 1, 31), date(2024, 2, 29)},
+		{"short February", 31, date(2023, 1, 31), date(2023, 2, 28)},
+		{"30-day month", 30, date(2024, 3, 30), date(2024, 4, 30)},
+		{"across the year", 31, date(2024, 12, 31), date(2025, 1, 31)},
+		{"on the due day", 1, date(2024, 1, 1), date(2024, 2, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &StandingOrder{day: tt.day}
+			if got := o.nextDue(tt.from); !got.Equal(tt.want) {
+				t.Fatalf("nextDue(%s) for day %d = %s, want %s", tt.from, tt.day, got, tt.want)
+			}
+		})
+	}
+}
+
+// orderScheduler returns a scheduler on a FakeClock set to 10 January 2024,
+// and the channel its results arrive on.
+func orderScheduler(t *testing.T) (*Scheduler, *FakeClock, chan ScheduleResult) {
+	t.Helper()
+	results := make(chan ScheduleResult, 16)
+	s := NewScheduler(func(r ScheduleResult) { results <- r })
+	t.Cleanup(s.Stop)
+	clock := NewFakeClock(date(2024, 1, 10))
+	s.SetClock(clock)
+	return s, clock, results
+}
+
+// advanceTo moves clock to at and waits for the run it sets off.
+func advanceTo(t *testing.T, clock *FakeClock, results chan ScheduleResult, at time.Time) *ConditionalCommand {
+	t.Helper()
+	clock.Set(at)
+	select {
+	case r := <-results:
+		if !r.At.Equal(at) {
+			t.Fatalf("run due %s fired at %s", r.At, at)
+		}
+		return r.Command.(*ConditionalCommand)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("nothing fired at %s", at)
+		return nil
+	}
+}
+
+func TestStandingOrderPays(t *testing.T) {
+	s, clock, results := orderScheduler(t)
+	from, to := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	order, err := NewStandingOrder(s, from, to, 30, 31, SkipPayment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, due := range []time.Time{date(2024, 1, 31), date(2024, 2, 29), date(2024, 3, 31)} {
+		if run := advanceTo(t, clock, results, due); !run.Succeeded() || run.Skipped() {
+			t.Fatalf("payment due %s: succeeded %v, skipped %v, err %v", due, run.Succeeded(), run.Skipped(), run.Err())
+		}
+	}
+	if from.Balance() != 10 || to.Balance() != 90 || len(order.Runs()) != 3 {
+		t.Fatalf("balances %v, %v after %d runs; want 10, 90 after 3", from.Balance(), to.Balance(), len(order.Runs()))
+	}
+	order.Stop()
+	if len(s.Pending()) != 0 || order.Err() != nil {
+		t.Fatalf("%d entries pending, err %v after Stop; want none", len(s.Pending()), order.Err())
+	}
+}
+
+func TestStandingOrderShortfall(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ShortfallPolicy
+		pending []time.Time
+	}{
+		{"skip", SkipPayment, []time.Time{date(2024, 2, 29)}},
+		{"retry", RetryNextDay, []time.Time{date(2024, 2, 1), date(2024, 2, 29)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clock, results := orderScheduler(t)
+			from, to := NewBankAccount(0, 0), NewBankAccount(0, 0)
+			if _, err := NewStandingOrder(s, from, to, 50, 31, tt.policy); err != nil {
+				t.Fatal(err)
+			}
+			if run := advanceTo(t, clock, results, date(2024, 1, 31)); !run.Skipped() {
+				t.Fatalf("uncovered payment not skipped: %v", run.Err())
+			}
+			pending := s.Pending()
+			if len(pending) != len(tt.pending) {
+				t.Fatalf("%d entries pending, want %d", len(pending), len(tt.pending))
+			}
+			for i, entry := range pending {
+				if !entry.At.Equal(tt.pending[i]) {
+					t.Fatalf("entry %d due %s, want %s", i, entry.At, tt.pending[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStandingOrderRetryGoesThrough(t *testing.T) {
+	s, clock, results := orderScheduler(t)
+	from, to := NewBankAccount(0, 0), NewBankAccount(0, 0)
+	order, err := NewStandingOrder(s, from, to, 50, 31, RetryNextDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	advanceTo(t, clock, results, date(2024, 1, 31))
+	from.Deposit(100)
+	if run := advanceTo(t, clock, results, date(2024, 2, 1)); !run.Succeeded() || run.Skipped() {
+		t.Fatalf("retry: succeeded %v, skipped %v, err %v", run.Succeeded(), run.Skipped(), run.Err())
+	}
+	if to.Balance() != 50 || len(order.Runs()) != 2 || len(s.Pending()) != 1 {
+		t.Fatalf("balance %v after %d runs with %d pending; want 50 after 2 with 1", to.Balance(), len(order.Runs()), len(s.Pending()))
+	}
+}
+
+// A next payment the full queue won't take is reported by Err rather than
+// dropped silently.
+func TestStandingOrderReportsQueueFull(t *testing.T) {
+	s, clock, results := orderScheduler(t)
+	from, to := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	order, err := NewStandingOrder(s, from, to, 10, 31, SkipPayment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Schedule(NewBankAccountCommand(to, Deposit, 1), date(2030, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	s.SetCapacity(1)
+	if run := advanceTo(t, clock, results, date(2024, 1, 31)); !run.Succeeded() {
+		t.Fatalf("payment failed: %v", run.Err())
+	}
+	if err := order.Err(); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Err = %v, want ErrQueueFull", err)
+	}
+}
+
+// A payment whose transfer can no longer be built fails with the reason
+// instead of bringing down the scheduler.
+func TestStandingOrderRejectedRun(t *testing.T) {
+	s, clock, results := orderScheduler(t)
+	from, to := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	order, err := NewStandingOrder(s, from, to, 10, 31, SkipPayment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to.Currency = "EUR"
+	run := advanceTo(t, clock, results, date(2024, 1, 31))
+	if run.Succeeded() || !errors.Is(run.Err(), ErrNoExchangeRate) {
+		t.Fatalf("succeeded %v, err %v; want ErrNoExchangeRate", run.Succeeded(), run.Err())
+	}
+	if from.Balance() != 100 || len(order.Runs()) != 1 || len(s.Pending()) != 1 {
+		t.Fatalf("balance %v after %d runs with %d pending; want 100 after 1 with 1", from.Balance(), len(order.Runs()), len(s.Pending()))
+	}
+}
+
+func TestStandingOrderRejectsBadDay(t *testing.T) {
+	s, _, _ := orderScheduler(t)
+	for _, day := range []int{0, 32} {
+		if _, err := NewStandingOrder(s, NewBankAccount(0, 0), NewBankAccount(0, 0), 1, day, SkipPayment); !errors.Is(err, ErrInvalidDay) {
+			t.Errorf("day %d: %v, want ErrInvalidDay", day, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+type StatementLine struct {
+	Time     time.Time
+	Action   Action
+	Debit    Money
+	Credit   Money
+	Balance  Money
+	Category string
+	Tags     []string
+	Actor    string
+	Reason   string
+}
+
+type Statement struct {
+	AccountID      string
+	Holder         *Holder
+	OpeningBalance Money
+	ClosingBalance Money
+	Lines          []StatementLine
+}
+
+// GenerateStatement lists, in execution order, every applied deposit,
+// withdrawal, capture and fee on the account found among cmds, including the
+// matching legs of composites. Authorizations and releases don't move money
+// and are left out. The opening balance is the balance just before the first
+// line; a statement without lines has zero opening and closing balances.
+// Holder is taken from the account, nil if it has none.
+func GenerateStatement(accountID string, cmds []Command) Statement {
+	var legs []*BankAccountCommand
+	for _, cmd := range cmds {
+		for _, leaf := range leaves(cmd) {
+			c, ok := leaf.(*BankAccountCommand)
+			if !ok || !c.executed || c.account == nil || c.account.ID != accountID {
+				continue
+			}
+			if c.action == Authorize || c.action == Release {
+				continue
+			}
+			legs = append(legs, c)
+		}
+	}
+	sort.SliceStable(legs, func(i, j int) bool {
+		return legs[i].executedAt.Before(legs[j].executedAt)
+	})
+
+	statement := Statement{AccountID: accountID}
+	if len(legs) > 0 {
+		statement.Holder = legs[0].account.Holder
+	}
+	for i, c := range legs {
+		line := StatementLine{Time: c.executedAt, Action: c.action, Category: c.category, Tags: c.tags, Actor: c.actor, Reason: c.reason}
+		delta := c.amount
+		switch c.action {
+		case Withdraw, WithdrawAll, WithdrawUpTo, Capture, Fee:
+			line.Debit = c.amount
+			delta = -c.amount
+		default:
+			line.Credit = c.amount
+		}
+		if i == 0 {
+			statement.OpeningBalance = c.balanceAfter - delta
+			statement.ClosingBalance = statement.OpeningBalance
+		}
+		statement.ClosingBalance += delta
+		line.Balance = statement.ClosingBalance
+		statement.Lines = append(statement.Lines, line)
+	}
+	return statement
+}
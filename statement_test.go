@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// statementAccounts opens "acct" with 100 and "other" empty, both on clock.
+func statementAccounts(clock *FakeClock) (acct, other *BankAccount) {
+	acct, other = NewBankAccount(100, 0), NewBankAccount(0, 0)
+	acct.ID, other.ID = "acct", "other"
+	acct.Clock, other.Clock = clock, clock
+	return acct, other
+}
+
+// runHourly calls each command an hour after the previous one.
+func runHourly(clock *FakeClock, cmds ...Command) {
+	for _, cmd := range cmds {
+		clock.Advance(time.Hour)
+		cmd.Call()
+	}
+}
+
+func TestGenerateStatement(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	acct, other := statementAccounts(clock)
+	transfer, err := NewMoneyTransferCommand(acct, other, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds := []Command{
+		NewBankAccountCommand(acct, Deposit, 50),
+		NewBankAccountCommand(acct, Authorize, 20),
+		transfer,
+		NewBankAccountCommand(acct, Withdraw, 1000),
+		NewBankAccountCommand(acct, Fee, 5),
+	}
+	runHourly(clock, cmds...)
+
+	statement := GenerateStatement("acct", cmds)
+	want := []StatementLine{
+		{Time: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), Action: Deposit, Credit: NewMoney(50), Balance: NewMoney(150)},
+		{Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Action: Withdraw, Debit: NewMoney(30), Balance: NewMoney(120)},
+		{Time: time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC), Action: Fee, Debit: NewMoney(5), Balance: NewMoney(115)},
+	}
+	if statement.OpeningBalance != NewMoney(100) || statement.ClosingBalance != NewMoney(115) {
+		t.Fatalf("opening %s, closing %s; want 100.00, 115.00", statement.OpeningBalance, statement.ClosingBalance)
+	}
+	if len(statement.Lines) != len(want) {
+		t.Fatalf("%d lines, want %d: %+v", len(statement.Lines), len(want), statement.Lines)
+	}
+	for i, line := range statement.Lines {
+		if !line.Time.Equal(want[i].Time) || line.Action != want[i].Action || line.Debit != want[i].Debit || line.Credit != want[i].Credit || line.Balance != want[i].Balance {
+			t.Errorf("line %d = %+v, want %+v", i, line, want[i])
+		}
+	}
+
+	payee := GenerateStatement("other", cmds)
+	if len(payee.Lines) != 1 || payee.Lines[0].Credit != NewMoney(30) || payee.OpeningBalance != 0 || payee.ClosingBalance != NewMoney(30) {
+		t.Fatalf("payee statement %+v, want the single 30.00 credit from 0.00", payee)
+	}
+}
+
+// Undone commands are no longer applied and drop out of the statement.
+func TestGenerateStatementSkipsUndone(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	acct, _ := statementAccounts(clock)
+	deposit, withdrawal := NewBankAccountCommand(acct, Deposit, 50), NewBankAccountCommand(acct, Withdraw, 10)
+	runHourly(clock, deposit, withdrawal)
+	if err := withdrawal.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	statement := GenerateStatement("acct", []Command{deposit, withdrawal})
+	if len(statement.Lines) != 1 || statement.ClosingBalance != NewMoney(150) {
+		t.Fatalf("%d lines closing at %s, want 1 closing at 150.00", len(statement.Lines), statement.ClosingBalance)
+	}
+	if empty := GenerateStatement("nobody", []Command{deposit}); len(empty.Lines) != 0 || empty.OpeningBalance != 0 || empty.ClosingBalance != 0 {
+		t.Fatalf("statement for an unknown account = %+v, want it empty", empty)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "time"
+
+// SuspenseEntry records a deposit that a rollback left with its recipient
+// and offset with a withdrawal from the suspense account instead, for
+// someone to resolve by hand.
+type SuspenseEntry struct {
+	Time time.Time
+	// Deposit is the stuck leg, still applied to its account.
+	Deposit *BankAccountCommand
+	// Sweep is the withdrawal from the suspense account that offsets it.
+	Sweep *BankAccountCommand
+}
+
+// SetSuspenseAccount makes rollbacks leave applied deposits where they are
+// rather than claw them back from their recipients, which in practice can't
+// always be done. The money stays with the recipient, and each such deposit
+// is offset by an ordinary withdrawal of the same amount from account, so
+// the books still balance and the suspense account goes negative by what is
+// waiting to be recovered. Open it with an overdraft limit sized to what may
+// be outstanding: a withdrawal the limit won't allow is refused like any
+// other, and the deposit is then undone after all, as it is when the
+// suspense account is frozen. Everything else rolls back as usual. It
+// applies to the composite's own deposit legs, such as those of
+// NewSplitTransferCommand; a MoneyTransferCommand child is atomic and rolls
+// back whole. A nil account, the default, means full rollback.
+func (c *CompositeBankAccountCommand) SetSuspenseAccount(account *BankAccount) {
+	c.suspense = account
+}
+
+func (c *CompositeBankAccountCommand) SuspenseAccount() *BankAccount {
+	return c.suspense
+}
+
+// Suspended returns the deposits the last rollback routed to the suspense
+// account, in the order it handled them.
+func (c *CompositeBankAccountCommand) Suspended() []SuspenseEntry {
+	return c.suspended
+}
+
+// suspend books cmd against the suspense account instead of undoing it, if
+// cmd is an applied deposit and the composite has a suspense account. It
+// reports whether it did.
+func (c *CompositeBankAccountCommand) suspend(cmd Command) bool {
+	deposit, ok := cmd.(*BankAccountCommand)
+	if c.suspense == nil || !ok || deposit.action != Deposit || !deposit.CanUndo() {
+		return false
+	}
+	sweep := newBankAccountCommand(c.suspense, Withdraw, deposit.amount)
+	sweep.actor = deposit.actor
+	sweep.reason = "suspense: " + deposit.describeAction()
+	sweep.Call()
+	if !sweep.Succeeded() {
+		return false
+	}
+	c.suspended = append(c.suspended, SuspenseEntry{Time: sweep.ExecutedAt(), Deposit: deposit, Sweep: sweep})
+	return true
+}
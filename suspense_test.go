@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// payout withdraws 30 from source, pays 30 to recipient and then fails on a
+// withdrawal from an empty account, so the composite rolls back.
+func payout(source, recipient *BankAccount) *CompositeBankAccountCommand {
+	return NewCompositeCommand(true,
+		NewBankAccountCommand(source, Withdraw, 30),
+		NewBankAccountCommand(recipient, Deposit, 30),
+		NewBankAccountCommand(NewBankAccount(0, 0), Withdraw, 1),
+	)
+}
+
+func TestSuspenseKeepsStuckDeposit(t *testing.T) {
+	source, recipient, suspense := NewBankAccount(100, 0), NewBankAccount(0, 0), NewBankAccount(0, -1000)
+	cmd := payout(source, recipient)
+	cmd.SetSuspenseAccount(suspense)
+	cmd.Call()
+	if cmd.Succeeded() || !errors.Is(cmd.Err(), ErrOverdraftExceeded) {
+		t.Fatalf("succeeded %v, err %v; want ErrOverdraftExceeded", cmd.Succeeded(), cmd.Err())
+	}
+	if source.Balance() != 100 || recipient.Balance() != 30 || suspense.Balance() != -30 {
+		t.Fatalf("balances %v, %v, %v; want 100, 30, -30", source.Balance(), recipient.Balance(), suspense.Balance())
+	}
+	entries := cmd.Suspended()
+	if len(entries) != 1 || entries[0].Deposit.account != recipient || entries[0].Sweep.account != suspense {
+		t.Fatalf("Suspended = %+v, want the one deposit to recipient", entries)
+	}
+	if reason := entries[0].Sweep.reason; !strings.HasPrefix(reason, "suspense: Deposit 30.00") {
+		t.Fatalf("sweep reason %q, want it to name the deposit", reason)
+	}
+}
+
+// Without headroom on the suspense account, or with it frozen, the stuck
+// deposit is clawed back as in a full rollback, and nothing is suspended.
+func TestSuspenseRefusedFallsBackToRollback(t *testing.T) {
+	tests := []struct {
+		name     string
+		suspense func() *BankAccount
+	}{
+		{"no overdraft", func() *BankAccount { return NewBankAccount(0, 0) }},
+		{"limit reached", func() *BankAccount { return NewBankAccount(-990, -1000) }},
+		{"frozen", func() *BankAccount {
+			account := NewBankAccount(0, -1000)
+			account.Freeze()
+			return account
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, recipient, suspense := NewBankAccount(100, 0), NewBankAccount(0, 0), tt.suspense()
+			before := suspense.Balance()
+			cmd := payout(source, recipient)
+			cmd.SetSuspenseAccount(suspense)
+			cmd.Call()
+			if source.Balance() != 100 || recipient.Balance() != 0 || suspense.Balance() != before {
+				t.Fatalf("balances %v, %v, %v; want 100, 0, %v", source.Balance(), recipient.Balance(), suspense.Balance(), before)
+			}
+			if len(cmd.Suspended()) != 0 {
+				t.Fatalf("Suspended = %+v, want none", cmd.Suspended())
+			}
+		})
+	}
+}
+
+func TestNoSuspenseAccountRollsBackFully(t *testing.T) {
+	source, recipient := NewBankAccount(100, 0), NewBankAccount(0, 0)
+	cmd := payout(source, recipient)
+	cmd.Call()
+	if source.Balance() != 100 || recipient.Balance() != 0 || len(cmd.Suspended()) != 0 {
+		t.Fatalf("balances %v, %v with %d suspended; want 100, 0 with none", source.Balance(), recipient.Balance(), len(cmd.Suspended()))
+	}
+}
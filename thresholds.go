@@ -0,0 +1,99 @@
+package main
+
+import "time"
+
+type CrossDirection int
+
+const (
+	// CrossedBelow means the balance fell from at or above the threshold to
+	// below it.
+	CrossedBelow CrossDirection = iota
+	// CrossedAbove means the balance rose from below the threshold to at or
+	// above it.
+	CrossedAbove
+)
+
+func (d CrossDirection) String() string {
+	if d == CrossedBelow {
+		return "below"
+	}
+	return "above"
+}
+
+// ThresholdCrossing is passed to a threshold callback.
+type ThresholdCrossing struct {
+	Account   *BankAccount
+	Threshold Money
+	Direction CrossDirection
+	Balance   Money
+	Time      time.Time
+}
+
+// thresholdWatch is one callback registered with OnThreshold. below is
+// which side of threshold the balance was last seen on.
+type thresholdWatch struct {
+	threshold Money
+	below     bool
+	fn        func(ThresholdCrossing)
+}
+
+// OnThreshold calls fn whenever the balance crosses threshold, in either
+// direction, for example to send a low-balance alert. It fires once per
+// crossing: further changes that leave the balance on the same side don't
+// call it again. fn runs after the change, once the account's lock has been
+// released, so it may read the account. A change made by a dry run or a
+// Restore counts as a crossing like any other.
+func (account *BankAccount) OnThreshold(threshold float64, fn func(ThresholdCrossing)) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	limit := NewMoney(threshold)
+	account.thresholds = append(account.thresholds, &thresholdWatch{threshold: limit, below: account.balance < limit, fn: fn})
+}
+
+// checkThresholds queues a crossing for every watch whose threshold the
+// balance has just crossed. The caller must hold account.mu and release it
+// with unlock, or through lockAccounts, so the queued callbacks run.
+func (account *BankAccount) checkThresholds() {
+	for _, w := range account.thresholds {
+		below := account.balance < w.threshold
+		if below == w.below {
+			continue
+		}
+		w.below = below
+		direction := CrossedAbove
+		if below {
+			direction = CrossedBelow
+		}
+		account.crossings = append(account.crossings, pendingCrossing{
+			fn:       w.fn,
+			crossing: ThresholdCrossing{Account: account, Threshold: w.threshold, Direction: direction, Balance: account.balance, Time: account.now()},
+		})
+	}
+}
+
+type pendingCrossing struct {
+	fn       func(ThresholdCrossing)
+	crossing ThresholdCrossing
+}
+
+// takeCrossings empties the account's queue of crossings. The caller must
+// hold account.mu.
+func (account *BankAccount) takeCrossings() []pendingCrossing {
+	crossings := account.crossings
+	account.crossings = nil
+	return crossings
+}
+
+// unlock releases account.mu and then runs the threshold callbacks queued
+// while it was held.
+func (account *BankAccount) unlock() {
+	crossings := account.takeCrossings()
+	account.mu.Unlock()
+	fireCrossings(crossings)
+}
+
+func fireCrossings(crossings []pendingCrossing) {
+	for _, p := range crossings {
+		p.fn(p.crossing)
+	}
+}
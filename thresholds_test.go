@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestOnThreshold(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	account, _ := statementAccounts(clock)
+	var crossings []ThresholdCrossing
+	account.OnThreshold(50, func(c ThresholdCrossing) {
+		// The callback runs unlocked, so it can read the account.
+		if c.Account.BalanceMoney() != c.Balance {
+			t.Errorf("account balance %s in the callback, crossing reports %s", c.Account.BalanceMoney(), c.Balance)
+		}
+		crossings = append(crossings, c)
+	})
+	runHourly(clock,
+		NewBankAccountCommand(account, Withdraw, 40),
+		NewBankAccountCommand(account, Withdraw, 20),
+		NewBankAccountCommand(account, Withdraw, 5),
+		NewBankAccountCommand(account, Deposit, 15),
+		NewBankAccountCommand(account, Deposit, 30),
+	)
+	want := []ThresholdCrossing{
+		{Direction: CrossedBelow, Balance: NewMoney(40), Time: time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{Direction: CrossedAbove, Balance: NewMoney(50), Time: time.Date(2026, 3, 1, 13, 0, 0, 0, time.UTC)},
+	}
+	if len(crossings) != len(want) {
+		t.Fatalf("%d crossings, want %d: %+v", len(crossings), len(want), crossings)
+	}
+	for i, c := range crossings {
+		if c.Account != account || c.Threshold != NewMoney(50) || c.Direction != want[i].Direction || c.Balance != want[i].Balance || !c.Time.Equal(want[i].Time) {
+			t.Errorf("crossing %d = %s at %s on %v, want %s at %s on %v", i, c.Direction, c.Balance, c.Time, want[i].Direction, want[i].Balance, want[i].Time)
+		}
+	}
+}
+
+// Transfers, undos and restores cross thresholds like any other change.
+func TestOnThresholdOtherChanges(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC))
+	from, to := statementAccounts(clock)
+	crossed := map[*BankAccount][]CrossDirection{}
+	record := func(c ThresholdCrossing) { crossed[c.Account] = append(crossed[c.Account], c.Direction) }
+	from.OnThreshold(80, record)
+	to.OnThreshold(10, record)
+	snapshot := from.Snapshot()
+
+	transfer, err := NewMoneyTransferCommand(from, to, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := call(transfer); err != nil {
+		t.Fatal(err)
+	}
+	if err := transfer.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	from.Withdraw(50)
+	from.Restore(snapshot)
+	for _, tt := range []struct {
+		account *BankAccount
+		want    []CrossDirection
+	}{
+		{from, []CrossDirection{CrossedBelow, CrossedAbove, CrossedBelow, CrossedAbove}},
+		{to, []CrossDirection{CrossedAbove, CrossedBelow}},
+	} {
+		if got := crossed[tt.account]; fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s crossed %v, want %v", tt.account.ID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrTransactionDone    = errors.New("transaction already committed or rolled back")
+	ErrTransactionAborted = errors.New("transaction aborted")
+	ErrInvalidSavepoint   = errors.New("invalid savepoint")
+)
+
+// Transaction collects commands and applies them all or none, with
+// database-like Begin, Add, Commit and Rollback:
+//
+//	tx := Begin()
+//	tx.Add(NewBankAccountCommand(a, Withdraw, 300))
+//	tx.Add(NewBankAccountCommand(b, Deposit, 300))
+//	err := tx.Commit()
+//
+// Nothing touches an account until Commit. A Transaction is safe for
+// concurrent use. It doesn't lock the accounts between commands, so a
+// commit that fails reverses its own commands rather than putting the
+// accounts back wholesale, and leaves whatever else happened to them in the
+// meantime alone.
+type Transaction struct {
+	mu       sync.Mutex
+	commands []Command
+	done     bool
+}
+
+func Begin() *Transaction {
+	return &Transaction{}
+}
+
+// Add queues cmd to run at Commit.
+func (tx *Transaction) Add(cmd Command) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.commands = append(tx.commands, cmd)
+	return nil
+}
+
+// Savepoint marks the commands added so far, for RollbackTo.
+func (tx *Transaction) Savepoint() int {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return len(tx.commands)
+}
+
+// RollbackTo discards the commands added since savepoint and keeps the
+// transaction open.
+func (tx *Transaction) RollbackTo(savepoint int) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTransactionDone
+	}
+	if savepoint < 0 || savepoint > len(tx.commands) {
+		return fmt.Errorf("%w %d: %d commands", ErrInvalidSavepoint, savepoint, len(tx.commands))
+	}
+	tx.commands = tx.commands[:savepoint]
+	return nil
+}
+
+// PartialRollbackError is joined into Commit's error when an aborted
+// transaction couldn't undo some of the commands it had applied, say because
+// a deposit has since been spent. Applied lists those commands in the order
+// they ran. They keep reporting success, so once whatever blocked them is
+// resolved they can still be undone.
+type PartialRollbackError struct {
+	Applied []Command
+	errs    []error
+}
+
+func (e *PartialRollbackError) Error() string {
+	return fmt.Sprintf("%d commands left applied: %v", len(e.Applied), errors.Join(e.errs...))
+}
+
+func (e *PartialRollbackError) Unwrap() []error {
+	return e.errs
+}
+
+// Commit calls the commands in the order they were added. If one fails,
+// it and those before it are undone in reverse order and the error wraps
+// ErrTransactionAborted and the command's own error. The commands it undid,
+// and those it never ran, are marked as failed. A command whose undo fails
+// is left applied and reported in a PartialRollbackError joined to the
+// error. Either way the transaction is finished.
+func (tx *Transaction) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+	for i, cmd := range tx.commands {
+		cmd.Call()
+		if cmd.Succeeded() {
+			continue
+		}
+		err := fmt.Errorf("%w: command %d: %s failed", ErrTransactionAborted, i, cmd.Describe())
+		if cmdErr := cmd.Err(); cmdErr != nil {
+			err = fmt.Errorf("%w: command %d: %w", ErrTransactionAborted, i, cmdErr)
+		}
+		partial := &PartialRollbackError{}
+		stuck := make(map[int]bool)
+		for j := i; j >= 0; j-- {
+			if undoErr := tx.commands[j].Undo(); undoErr != nil {
+				partial.Applied = append([]Command{tx.commands[j]}, partial.Applied...)
+				partial.errs = append(partial.errs, fmt.Errorf("undoing command %d: %w", j, undoErr))
+				stuck[j] = true
+			}
+		}
+		for j, cmd := range tx.commands {
+			if !stuck[j] {
+				cmd.SetSucceeded(false)
+			}
+		}
+		if len(partial.Applied) > 0 {
+			return errors.Join(err, partial)
+		}
+		return err
+	}
+	return nil
+}
+
+// Rollback discards the queued commands without running any of them.
+func (tx *Transaction) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+	tx.commands = nil
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransactionCommit(t *testing.T) {
+	a, b := NewBankAccount(1000, 0), NewBankAccount(500, 0)
+	tx := Begin()
+	tx.Add(NewBankAccountCommand(a, Withdraw, 300))
+	tx.Add(NewBankAccountCommand(b, Deposit, 300))
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Balance() != 700 || b.Balance() != 800 {
+		t.Fatalf("balances %v, %v; want 700, 800", a.Balance(), b.Balance())
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTransactionDone) {
+		t.Fatalf("second Commit = %v, want ErrTransactionDone", err)
+	}
+	if err := tx.Add(NewBankAccountCommand(a, Deposit, 1)); !errors.Is(err, ErrTransactionDone) {
+		t.Fatalf("Add after Commit = %v, want ErrTransactionDone", err)
+	}
+}
+
+func TestTransactionAbortUndoesInReverse(t *testing.T) {
+	a, b, c := NewBankAccount(1000, 0), NewBankAccount(500, 0), NewBankAccount(0, 0)
+	cmds := []Command{
+		NewBankAccountCommand(a, Withdraw, 300),
+		NewBankAccountCommand(b, Deposit, 300),
+		NewBankAccountCommand(c, Withdraw, 1),
+	}
+	tx := Begin()
+	for _, cmd := range cmds {
+		tx.Add(cmd)
+	}
+	err := tx.Commit()
+	if !errors.Is(err, ErrTransactionAborted) || !errors.Is(err, ErrOverdraftExceeded) {
+		t.Fatalf("Commit = %v, want ErrTransactionAborted and ErrOverdraftExceeded", err)
+	}
+	if a.Balance() != 1000 || b.Balance() != 500 || c.Balance() != 0 {
+		t.Fatalf("balances %v, %v, %v; want 1000, 500, 0", a.Balance(), b.Balance(), c.Balance())
+	}
+	for i, cmd := range cmds {
+		if cmd.Succeeded() {
+			t.Errorf("command %d still reports success", i)
+		}
+	}
+}
+
+// A change made to an account while the transaction runs survives the
+// transaction's rollback.
+func TestTransactionAbortKeepsConcurrentChanges(t *testing.T) {
+	a, b, c := NewBankAccount(1000, 0), NewBankAccount(500, 0), NewBankAccount(0, 0)
+	b.AfterExecute = append(b.AfterExecute, func(Command) {
+		a.Deposit(5)
+	})
+	tx := Begin()
+	tx.Add(NewBankAccountCommand(a, Withdraw, 300))
+	tx.Add(NewBankAccountCommand(b, Deposit, 300))
+	tx.Add(NewBankAccountCommand(c, Withdraw, 1))
+	if err := tx.Commit(); !errors.Is(err, ErrTransactionAborted) {
+		t.Fatalf("Commit = %v, want ErrTransactionAborted", err)
+	}
+	if a.Balance() != 1005 || b.Balance() != 500 {
+		t.Fatalf("balances %v, %v; want 1005 with the concurrent deposit kept, 500", a.Balance(), b.Balance())
+	}
+}
+
+// When money the transaction deposited has been spent in the meantime, the
+// undo that can't be applied is reported with the abort.
+func TestTransactionAbortReportsFailedUndo(t *testing.T) {
+	a, b, c := NewBankAccount(1000, 0), NewBankAccount(0, 0), NewBankAccount(0, 0)
+	c.Freeze()
+	spent := false
+	b.AfterExecute = append(b.AfterExecute, func(Command) {
+		if !spent {
+			spent = true
+			b.Withdraw(300)
+		}
+	})
+	tx := Begin()
+	tx.Add(NewBankAccountCommand(a, Withdraw, 300))
+	tx.Add(NewBankAccountCommand(b, Deposit, 300))
+	tx.Add(NewBankAccountCommand(c, Deposit, 1))
+	err := tx.Commit()
+	if !errors.Is(err, ErrTransactionAborted) || !errors.Is(err, ErrAccountFrozen) || !errors.Is(err, ErrOverdraftExceeded) {
+		t.Fatalf("Commit = %v, want the abort, ErrAccountFrozen and the failed undo's ErrOverdraftExceeded", err)
+	}
+	if a.Balance() != 1000 || b.Balance() != 0 {
+		t.Fatalf("balances %v, %v; want 1000, 0", a.Balance(), b.Balance())
+	}
+}
+
+// A command whose undo fails stays applied and is handed back, so it can be
+// undone once the money is back; the rest are marked as failed.
+func TestTransactionAbortLeavesFailedUndoApplied(t *testing.T) {
+	a, b, c := NewBankAccount(1000, 0), NewBankAccount(0, 0), NewBankAccount(0, 0)
+	c.Freeze()
+	withdrawal := NewBankAccountCommand(a, Withdraw, 300)
+	deposit := NewBankAccountCommand(b, Deposit, 300)
+	deposit.OnSuccess(func(Command) { b.Withdraw(300) })
+	tx := Begin()
+	tx.Add(withdrawal)
+	tx.Add(deposit)
+	tx.Add(NewBankAccountCommand(c, Deposit, 1))
+	err := tx.Commit()
+	var partial *PartialRollbackError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Commit = %v, want a PartialRollbackError", err)
+	}
+	if len(partial.Applied) != 1 || partial.Applied[0] != deposit {
+		t.Fatalf("Applied = %v, want just the deposit", partial.Applied)
+	}
+	if withdrawal.Succeeded() || !deposit.Succeeded() || !deposit.CanUndo() {
+		t.Fatalf("withdrawal succeeded %v, deposit succeeded %v and undoable %v; want false, true, true", withdrawal.Succeeded(), deposit.Succeeded(), deposit.CanUndo())
+	}
+	b.Deposit(300)
+	if err := deposit.Undo(); err != nil {
+		t.Fatalf("undoing the deposit once the money is back: %v", err)
+	}
+	if a.Balance() != 1000 || b.Balance() != 0 {
+		t.Fatalf("balances %v, %v; want 1000, 0", a.Balance(), b.Balance())
+	}
+}
+
+func TestTransactionSavepoints(t *testing.T) {
+	a := NewBankAccount(100, 0)
+	tx := Begin()
+	tx.Add(NewBankAccountCommand(a, Deposit, 10))
+	savepoint := tx.Savepoint()
+	tx.Add(NewBankAccountCommand(a, Deposit, 20))
+	if err := tx.RollbackTo(savepoint); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.RollbackTo(5); !errors.Is(err, ErrInvalidSavepoint) {
+		t.Fatalf("RollbackTo(5) = %v, want ErrInvalidSavepoint", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Balance() != 110 {
+		t.Fatalf("balance %v, want 110", a.Balance())
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	a := NewBankAccount(100, 0)
+	tx := Begin()
+	tx.Add(NewBankAccountCommand(a, Deposit, 10))
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTransactionDone) {
+		t.Fatalf("Commit after Rollback = %v, want ErrTransactionDone", err)
+	}
+	if a.Balance() != 100 {
+		t.Fatalf("balance %v, want 100", a.Balance())
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import "strings"
+
+// Tree renders cmd and everything under it as an indented tree, one line per
+// node with its description and state, for example:
+//
+//	Transfer 50.00 from acct-1 to acct-2 [FAILED: account is frozen]
+//	├── Withdraw 50.00 from acct-1 [not applied]
+//	└── Deposit 50.00 to acct-2 [FAILED: account is frozen]
+//
+// An overdraft fee shows up as the child of the withdrawal that triggered
+// it. Commands that haven't run, or were rolled back, are marked as not
+// applied.
+func Tree(cmd Command) string {
+	var b strings.Builder
+	b.WriteString(treeLine(cmd))
+	b.WriteByte('\n')
+	writeSubtree(&b, cmd, "")
+	return b.String()
+}
+
+func writeSubtree(b *strings.Builder, cmd Command, indent string) {
+	nodes := treeChildren(cmd)
+	for i, child := range nodes {
+		branch, next := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, next = "└── ", "    "
+		}
+		b.WriteString(indent + branch + treeLine(child) + "\n")
+		writeSubtree(b, child, indent+next)
+	}
+}
+
+func treeChildren(cmd Command) []Command {
+	if c, ok := cmd.(*BankAccountCommand); ok && c.fee != nil {
+		return []Command{c.fee}
+	}
+	if p, ok := cmd.(parent); ok {
+		return p.children()
+	}
+	return nil
+}
+
+func treeLine(cmd Command) string {
+	switch {
+	case cmd.Err() != nil:
+		return cmd.Describe() + " [FAILED: " + cmd.Err().Error() + "]"
+	case cmd.Succeeded():
+		return cmd.Describe() + " [ok]"
+	default:
+		return cmd.Describe() + " [not applied]"
+	}
+}
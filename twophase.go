@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrPrepareUnsupported = errors.New("action does not support two-phase execution")
+
+// Two-phase execution splits Call into Prepare, which runs every check and
+// reserves what the command needs without applying it, and Commit, which
+// applies it and can no longer fail. Rollback releases a prepared command
+// that won't be committed. A composite prepares all of its children before
+// committing any, so a multi-leg operation either happens completely or not
+// at all without relying on Undo.
+//
+// For a withdrawal, Prepare puts the amount on hold and counts it against
+// the daily limit, so neither can be taken by anything else before Commit.
+// A deposit or balance inquiry reserves nothing. Commit on a command that
+// isn't prepared does nothing, and so does Rollback.
+
+func (c *BankAccountCommand) Prepare() error {
+	if c.account == nil {
+		c.err, c.succeeded = ErrNilAccount, false
+		return c.err
+	}
+	unlock := lockAccounts(c.account)
+	defer unlock()
+	return c.prepareLocked()
+}
+
+func (c *BankAccountCommand) Commit() {
+	if c.account == nil {
+		return
+	}
+	unlock := lockAccounts(c.account)
+	defer unlock()
+	c.commitLocked()
+}
+
+func (c *BankAccountCommand) Rollback() {
+	if c.account == nil {
+		return
+	}
+	unlock := lockAccounts(c.account)
+	defer unlock()
+	c.rollbackLocked()
+}
+
+// prepareLocked, commitLocked and rollbackLocked expect the caller to hold
+// the account lock. The daily limit counts a prepared withdrawal on the day
+// it was prepared, which is also what ExecutedAt reports after Commit.
+func (c *BankAccountCommand) prepareLocked() error {
+	if c.executed {
+		return ErrAlreadyExecuted
+	}
+	if c.prepared {
+		return nil
+	}
+	c.executedAt = c.account.now()
+	c.fee = nil
+	c.overdraftExceptionUsed = false
+	c.succeeded = false
+	c.restoreUpTo()
+	c.err = c.checkArgs()
+	if c.err == nil && c.account.closed {
+		c.err = ErrAccountClosed
+	}
+	if c.err == nil {
+		c.err = c.checkVersion()
+	}
+	if c.err == nil {
+		c.err = c.account.reserve(c)
+	}
+	c.prepared = c.err == nil
+	return c.err
+}
+
+func (c *BankAccountCommand) commitLocked() {
+	if !c.prepared {
+		return
+	}
+	c.prepared = false
+	account := c.account
+	switch c.action {
+	case Deposit:
+		account.adjust(c.amount)
+	case Withdraw, WithdrawAll, WithdrawUpTo, Fee:
+		account.held -= c.amount
+		account.adjust(-c.amount)
+	case BalanceInquiry:
+		c.result = account.balance
+	}
+	c.err = nil
+	c.succeeded = true
+	c.executed = c.action != BalanceInquiry
+	c.balanceAfter = account.balance
+	if c.executed {
+		c.chargeOverdraftFee()
+	}
+}
+
+func (c *BankAccountCommand) rollbackLocked() {
+	if !c.prepared {
+		return
+	}
+	c.prepared = false
+	c.succeeded = false
+	switch c.action {
+	case Withdraw, WithdrawAll, WithdrawUpTo:
+		c.account.held -= c.amount
+		c.account.creditDailyWithdrawal(c.amount, c.executedAt)
+	case Fee, Authorize:
+		c.account.held -= c.amount
+	}
+}
+
+// reserve runs the checks for cmd and sets aside what Commit will need.
+// The caller must hold account.mu.
+func (account *BankAccount) reserve(cmd *BankAccountCommand) error {
+	switch cmd.action {
+	case Deposit:
+		if err := account.usable(); err != nil {
+			return err
+		}
+		return account.validate(cmd)
+	case Withdraw, WithdrawAll, WithdrawUpTo:
+		switch cmd.action {
+		case WithdrawAll:
+			cmd.amount = account.withdrawable(cmd.executedAt)
+		case WithdrawUpTo:
+			cmd.amount = account.withdrawableUpTo(cmd.upTo, cmd.executedAt)
+		}
+		if err := account.checkWithdrawal(cmd, cmd.executedAt); err != nil {
+			return err
+		}
+		account.held += cmd.amount
+		account.withdrawnToday += cmd.amount
+		return nil
+	case Fee:
+		if err := account.validate(cmd); err != nil {
+			return err
+		}
+		account.held += cmd.amount
+		return nil
+	case Authorize:
+		// The hold an authorization places is its whole effect, so
+		// preparing one places it and committing only confirms it.
+		return account.authorizeFor(cmd)
+	case BalanceInquiry:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPrepareUnsupported, cmd.action)
+}
+
+// Prepare prepares the children in the order set with SetOrder. If one
+// fails, those prepared before it are rolled back in reverse order and its
+// error is returned.
+func (c *CompositeBankAccountCommand) Prepare() error {
+	if err := checkCommandLimits(c); err != nil {
+		return err
+	}
+	c.arrange()
+	for i := range c.commands {
+		if err := c.child(i).Prepare(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				c.child(j).Rollback()
+			}
+			return fmt.Errorf("child %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *CompositeBankAccountCommand) Commit() {
+	for i := range c.commands {
+		c.child(i).Commit()
+	}
+}
+
+func (c *CompositeBankAccountCommand) Rollback() {
+	for i := len(c.commands) - 1; i >= 0; i-- {
+		c.child(i).Rollback()
+	}
+}
+
+// Prepare works out the interest from the balance at this moment and
+// prepares the leg that will apply it.
+func (c *InterestCommand) Prepare() error {
+	if c.account == nil {
+		return ErrNilAccount
+	}
+	unlock := lockAccounts(c.account)
+	defer unlock()
+	if c.leg != nil && c.leg.executed {
+		return ErrAlreadyExecuted
+	}
+	if c.leg != nil && c.leg.prepared {
+		return nil
+	}
+	c.accrueLocked()
+	if c.leg == nil {
+		return nil
+	}
+	return c.leg.prepareLocked()
+}
+
+func (c *InterestCommand) Commit() {
+	if c.account == nil {
+		return
+	}
+	unlock := lockAccounts(c.account)
+	defer unlock()
+	if c.leg == nil {
+		c.succeeded = true
+		return
+	}
+	c.leg.commitLocked()
+	c.succeeded = c.leg.succeeded
+}
+
+func (c *InterestCommand) Rollback() {
+	if c.account == nil {
+		c.succeeded = false
+		return
+	}
+	unlock := lockAccounts(c.account)
+	defer unlock()
+	if c.leg != nil {
+		c.leg.rollbackLocked()
+	}
+	c.succeeded = false
+}
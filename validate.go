@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Validate checks the amount, the account and, for withdrawals, fees and
+// authorizations, that the balance and the account's validators would allow
+// the command right now. An already applied command validates, since
+// calling it again does nothing.
+func (c *BankAccountCommand) Validate() error {
+	if err := c.checkArgs(); err != nil {
+		return err
+	}
+	if c.account == nil {
+		return ErrNilAccount
+	}
+	unlock := lockAccounts(c.account)
+	defer unlock()
+	if c.executed {
+		return nil
+	}
+	if c.account.closed {
+		return ErrAccountClosed
+	}
+	if err := c.checkVersion(); err != nil {
+		return err
+	}
+	// OverdraftLimit marks the one-time exception used; a pre-flight check
+	// mustn't.
+	used := c.overdraftExceptionUsed
+	defer func() { c.overdraftExceptionUsed = used }()
+	return c.account.precheck(c, c.account.now())
+}
+
+// precheck runs the checks Call would for cmd at time at, without moving
+// money or rolling the daily limit over. The caller must hold account.mu.
+func (account *BankAccount) precheck(cmd *BankAccountCommand, at time.Time) error {
+	switch cmd.action {
+	case Deposit, Authorize:
+		if err := account.usable(); err != nil {
+			return err
+		}
+		return account.validate(cmd)
+	case Withdraw:
+		if err := account.usable(); err != nil {
+			return err
+		}
+		if err := account.validate(cmd); err != nil {
+			return err
+		}
+		withdrawn := cmd.amount
+		if startOfDay(at).Equal(account.withdrawnDay) {
+			withdrawn += account.withdrawnToday
+		}
+		if account.dailyWithdrawLimit > 0 && withdrawn > account.dailyWithdrawLimit {
+			return ErrDailyLimitExceeded
+		}
+		return nil
+	case WithdrawAll, WithdrawUpTo:
+		// Withdrawing no more than is withdrawable can't overdraw.
+		return account.usable()
+	case Capture, Release:
+		if cmd.action == Capture {
+			if err := account.usable(); err != nil {
+				return err
+			}
+		}
+		if cmd.amount > account.held {
+			return ErrInsufficientHold
+		}
+		return nil
+	case Fee:
+		return account.validate(cmd)
+	}
+	return nil
+}
+
+// Validate checks every child and returns all their problems joined, so a
+// complex operation can be pre-flighted in one go. Each child is checked
+// against the balances as they are now, not as the children before it would
+// leave them.
+func (c *CompositeBankAccountCommand) Validate() error {
+	if err := checkCommandLimits(c); err != nil {
+		return err
+	}
+	var errs []error
+	for i, cmd := range c.commands {
+		if err := cmd.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("child %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the transfer's legs like a composite's, once it has both
+// its accounts.
+func (c *MoneyTransferCommand) Validate() error {
+	if err := c.checkAccounts(); err != nil {
+		return err
+	}
+	return c.CompositeBankAccountCommand.Validate()
+}
+
+// Validate only checks the account: the interest depends on the balance
+// when the command runs.
+func (c *InterestCommand) Validate() error {
+	if c.account == nil {
+		return ErrNilAccount
+	}
+	if c.account.Closed() {
+		return ErrAccountClosed
+	}
+	return nil
+}
+
+// Validate evaluates the predicate and, if it holds, validates inner.
+func (c *ConditionalCommand) Validate() error {
+	if c.account == nil {
+		return ErrNilAccount
+	}
+	if !c.predicate(c.account) {
+		return nil
+	}
+	return c.inner.Validate()
+}
+
+func (c *CloseAccountCommand) Validate() error {
+	if c.account == nil {
+		return ErrNilAccount
+	}
+	unlock := lockAccounts(c.lockSet()...)
+	defer unlock()
+	if c.executed {
+		return nil
+	}
+	_, err := c.checkLocked()
+	return err
+}
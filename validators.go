@@ -0,0 +1,151 @@
+package main
+
+import "fmt"
+
+// Validator vets a command before it is applied to account. Validators run
+// with the account lock held, so they can read the account's fields
+// directly but must not call its locking methods.
+type Validator interface {
+	Validate(account *BankAccount, cmd *BankAccountCommand) error
+}
+
+// ValidatorFunc adapts a plain function to the Validator interface.
+type ValidatorFunc func(account *BankAccount, cmd *BankAccountCommand) error
+
+func (f ValidatorFunc) Validate(account *BankAccount, cmd *BankAccountCommand) error {
+	return f(account, cmd)
+}
+
+// Reversal reports whether cmd is Undo taking back a command that was
+// applied, such as the deposit returning an undone withdrawal, rather than
+// an operation in its own right. A validator enforcing a policy on new
+// business can let reversals through.
+func (c *BankAccountCommand) Reversal() bool {
+	return c.reversal
+}
+
+// DefaultValidators is the chain used by accounts that haven't been given
+// their own. The minimum balance is checked before the overdraft limit, so
+// an account with both reports the stricter one.
+var DefaultValidators = []Validator{NonNegativeAmount{}, MaxTransactionAmount{}, ActionLimits{}, MinimumBalance{}, OverdraftLimit{}}
+
+// NonNegativeAmount rejects negative amounts with ErrNegativeAmount, and
+// amounts that came from a NaN or infinite float64 with ErrInvalidAmount.
+type NonNegativeAmount struct{}
+
+func (NonNegativeAmount) Validate(account *BankAccount, cmd *BankAccountCommand) error {
+	if cmd.amount == invalidMoney {
+		return ErrInvalidAmount
+	}
+	if cmd.amount < 0 {
+		return ErrNegativeAmount
+	}
+	return nil
+}
+
+// MaxTransactionAmount rejects deposits and withdrawals above the account's
+// transaction cap with ErrAmountTooLarge, if it has one, or else above the
+// cap for its holder's type. It runs before the
+// balance checks, so an oversized withdrawal is reported as too large even
+// when it would also overdraw the account. Undoing a command that went
+// through is never refused, even if the cap has been lowered since.
+type MaxTransactionAmount struct{}
+
+func (MaxTransactionAmount) Validate(account *BankAccount, cmd *BankAccountCommand) error {
+	if cmd.reversal {
+		return nil
+	}
+	switch cmd.action {
+	case Deposit, Withdraw, WithdrawAll, WithdrawUpTo:
+		if limit := account.transactionCap(); limit > 0 && cmd.amount > limit {
+			return fmt.Errorf("%w: %s over %s", ErrAmountTooLarge, cmd.amount, limit)
+		}
+	}
+	return nil
+}
+
+// MinimumBalance rejects withdrawals and authorizations that would take the
+// available balance below the account's minimum balance, if it has one.
+type MinimumBalance struct{}
+
+func (MinimumBalance) Validate(account *BankAccount, cmd *BankAccountCommand) error {
+	if available, ok := availableAfter(account, cmd); ok && account.hasMinimumBalance && available < account.minimumBalance {
+		return ErrBelowMinimumBalance
+	}
+	return nil
+}
+
+// OverdraftLimit rejects withdrawals and authorizations that would take the
+// available balance below the account's overdraft limit, unless the command
+// allows a one-time overdraft, in which case it marks the exception as used.
+type OverdraftLimit struct{}
+
+func (OverdraftLimit) Validate(account *BankAccount, cmd *BankAccountCommand) error {
+	if available, ok := availableAfter(account, cmd); ok && available < account.overdraftLimit {
+		if cmd.allowOneTimeOverdraft {
+			cmd.overdraftExceptionUsed = true
+			return nil
+		}
+		return ErrOverdraftExceeded
+	}
+	return nil
+}
+
+// availableAfter returns the available balance cmd would leave, and false
+// for actions that don't reduce it.
+func availableAfter(account *BankAccount, cmd *BankAccountCommand) (Money, bool) {
+	switch cmd.action {
+	case Withdraw, WithdrawAll, WithdrawUpTo, Authorize:
+		return account.balance - account.held - cmd.amount, true
+	}
+	return 0, false
+}
+
+// SetValidators replaces the account's validation chain. Calling it with no
+// validators turns every check off, including the overdraft limit.
+func (account *BankAccount) SetValidators(validators ...Validator) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	account.validators = append([]Validator{}, validators...)
+}
+
+// AddValidator appends a rule to the end of the account's chain.
+func (account *BankAccount) AddValidator(v Validator) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	account.validators = append(account.chain(), v)
+}
+
+func (account *BankAccount) chain() []Validator {
+	if account.validators == nil {
+		return append([]Validator{}, DefaultValidators...)
+	}
+	return account.validators
+}
+
+// validate runs the chain in order and returns the first error. The caller
+// must hold account.mu.
+func (account *BankAccount) validate(cmd *BankAccountCommand) error {
+	for _, v := range account.chain() {
+		if err := v.Validate(account, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// request describes a direct call such as Withdraw as the equivalent
+// command, so validators see every operation the same way.
+func (account *BankAccount) request(action Action, amount Money) *BankAccountCommand {
+	return &BankAccountCommand{account: account, accountID: account.ID, action: action, amount: amount}
+}
+
+// reversal is request for the movement Undo makes to take back an applied
+// command, such as the deposit returning a withdrawal. Validators that cap
+// amounts let reversals through, so a cap lowered after a command ran can't
+// stop it being undone; those guarding the balance still apply.
+func (account *BankAccount) reversal(action Action, amount Money) *BankAccountCommand {
+	cmd := account.request(action, amount)
+	cmd.reversal = true
+	return cmd
+}
@@ -0,0 +1,33 @@
+package main
+
+import "fmt"
+
+// ErrVersionConflict is temporary: the command may succeed once rebuilt from
+// a fresh read of the account.
+var ErrVersionConflict error = &temporaryError{"account version conflict"}
+
+// Version returns the account's version, which goes up by one with every
+// change to its balance or holds. A command built from a read of the
+// account can carry the version it saw with ExpectVersion, and then fails
+// instead of acting on a stale view.
+func (account *BankAccount) Version() int {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	return account.version
+}
+
+// ExpectVersion makes the command fail with ErrVersionConflict unless the
+// account is still at version when it is called or prepared, turning it into
+// a compare-and-swap. Undo doesn't check the version.
+func (c *BankAccountCommand) ExpectVersion(version int) {
+	c.expectedVersion = version
+	c.hasExpectedVersion = true
+}
+
+// checkVersion expects the caller to hold the account lock.
+func (c *BankAccountCommand) checkVersion() error {
+	if !c.hasExpectedVersion || c.account.version == c.expectedVersion {
+		return nil
+	}
+	return fmt.Errorf("%w: %s expected version %d, found %d", ErrVersionConflict, accountLabel(c.account), c.expectedVersion, c.account.version)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestVersionCountsChanges(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	if v := account.Version(); v != 0 {
+		t.Fatalf("new account at version %d, want 0", v)
+	}
+	account.Deposit(10)
+	account.Withdraw(10)
+	if v := account.Version(); v != 2 {
+		t.Fatalf("version %d after two changes, want 2", v)
+	}
+	if err := account.Withdraw(1000); err == nil {
+		t.Fatal("overdrawing withdrawal succeeded")
+	}
+	if v := account.Version(); v != 2 {
+		t.Fatalf("version %d after a failed withdrawal, want 2", v)
+	}
+}
+
+// A withdrawal built from a read of the account fails once another update
+// has moved the account on, and leaves the balance alone.
+func TestStaleReadConflicts(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	seen := account.Version()
+	stale := NewBankAccountCommand(account, Withdraw, 80)
+	stale.ExpectVersion(seen)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		account.Withdraw(50)
+	}()
+	<-done
+
+	stale.Call()
+	if stale.Succeeded() || !errors.Is(stale.Err(), ErrVersionConflict) {
+		t.Fatalf("succeeded %v, err %v; want ErrVersionConflict", stale.Succeeded(), stale.Err())
+	}
+	if !IsTemporary(stale.Err()) {
+		t.Fatal("ErrVersionConflict isn't temporary")
+	}
+	if account.Balance() != 50 {
+		t.Fatalf("balance %v, want 50", account.Balance())
+	}
+
+	fresh := NewBankAccountCommand(account, Withdraw, 20)
+	fresh.ExpectVersion(account.Version())
+	fresh.Call()
+	if !fresh.Succeeded() {
+		t.Fatalf("withdrawal at the current version failed: %v", fresh.Err())
+	}
+}
+
+// Of many commands racing from the same read, exactly one wins.
+func TestConcurrentCompareAndSwap(t *testing.T) {
+	account := NewBankAccount(1000, 0)
+	seen := account.Version()
+	cmds := make([]*BankAccountCommand, 20)
+	var wg sync.WaitGroup
+	for i := range cmds {
+		cmds[i] = NewBankAccountCommand(account, Withdraw, 10)
+		cmds[i].ExpectVersion(seen)
+		wg.Add(1)
+		go func(cmd *BankAccountCommand) {
+			defer wg.Done()
+			cmd.Call()
+		}(cmds[i])
+	}
+	wg.Wait()
+	won, conflicts := 0, 0
+	for _, cmd := range cmds {
+		switch {
+		case cmd.Succeeded():
+			won++
+		case errors.Is(cmd.Err(), ErrVersionConflict):
+			conflicts++
+		}
+	}
+	if won != 1 || conflicts != len(cmds)-1 || account.Balance() != 990 {
+		t.Fatalf("%d won, %d conflicted, balance %v; want 1, %d, 990", won, conflicts, account.Balance(), len(cmds)-1)
+	}
+}
+
+func TestValidateChecksVersion(t *testing.T) {
+	account := NewBankAccount(100, 0)
+	cmd := NewBankAccountCommand(account, Deposit, 10)
+	cmd.ExpectVersion(account.Version())
+	account.Deposit(1)
+	if err := cmd.Validate(); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("Validate = %v, want ErrVersionConflict", err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import "time"
+
+// WithdrawUpTo is for "pay what you can" collections where a partial payment
+// is acceptable. Unlike Withdraw it never fails for lack of funds: it takes
+// amount, or as much of it as the account can spare without crossing its
+// minimum balance, overdraft limit, daily withdrawal limit or per-transaction
+// maximum, and returns what it took. A frozen or closed account, or an amount
+// that isn't positive, yields 0.
+func (account *BankAccount) WithdrawUpTo(amount float64) float64 {
+	cmd := NewBankAccountCommand(account, WithdrawUpTo, amount)
+	account.mu.Lock()
+	defer account.unlock()
+	cmd.callLocked()
+	if !cmd.succeeded {
+		return 0
+	}
+	return cmd.amount.Float64()
+}
+
+// withdrawableUpTo returns the most of limit a withdrawal at time at could
+// take. The caller must hold account.mu.
+func (account *BankAccount) withdrawableUpTo(limit Money, at time.Time) Money {
+	amount := min(limit, account.withdrawable(at))
+	if limit := account.transactionCap(); limit > 0 {
+		amount = min(amount, limit)
+	}
+	if limit, ok := account.limits[WithdrawUpTo]; ok {
+		amount = min(amount, limit)
+	}
+	return amount
+}
+
+// restoreUpTo puts a WithdrawUpTo command's amount back to the most it may
+// take before each run, since the last run replaced it with what was taken.
+// A command that hasn't run yet takes its limit from the amount it was
+// given.
+func (c *BankAccountCommand) restoreUpTo() {
+	if c.action != WithdrawUpTo {
+		return
+	}
+	if c.upTo == 0 {
+		c.upTo = c.amount
+	}
+	c.amount = c.upTo
+}